@@ -1,7 +1,7 @@
 package okaydns
 
 import (
-	"net"
+	"fmt"
 
 	"github.com/miekg/dns"
 )
@@ -33,37 +33,123 @@ func (d *defaultChecker) Check(config *Check, fqdn string, nameservers []Nameser
 	}
 
 	check := &CheckResult{
-		Name:        config.Name,
-		Nameservers: nameservers,
-		Question:    config.Question(fqdn),
+		Name:           config.Name,
+		Nameservers:    nameservers,
+		TolerateErrors: config.TolerateErrors,
 	}
 
-	check.Answers, check.Errors = queryAll(check.Question, check.Nameservers)
+	var previous []*CheckResult
+	if config.Question != nil || config.NameserverQuestion != nil {
+		check.Questions = config.questions(fqdn, nameservers)
+		d.ask(check, config.Attempts, config.validators())
 
-	for _, validator := range config.Validators {
-		check.Failures = append(check.Failures, validator(check.Question, check.Answers)...)
+		q := check.question()
+		for _, observer := range config.Observers {
+			forEachQuestion(q, check.Exchanges, func(q *dns.Msg, replies map[Nameserver]*dns.Msg) {
+				check.Observations = append(check.Observations, observer(q, replies)...)
+			})
+		}
+		for _, observer := range config.ExchangeObservers {
+			check.Observations = append(check.Observations, observer(q, check.Exchanges)...)
+		}
+		previous = []*CheckResult{check}
+	}
+
+	for _, step := range config.Steps {
+		stepNameservers := nameservers
+		if step.Nameservers != nil {
+			stepNameservers = step.Nameservers(nameservers, previous)
+		}
+
+		stepResult := StepResult{Name: step.Name}
+		for _, q := range step.Questions(fqdn, previous) {
+			result := &CheckResult{
+				Name:           stepQuestionName(step.Name, q),
+				Nameservers:    stepNameservers,
+				Questions:      sameQuestion(q, stepNameservers),
+				TolerateErrors: config.TolerateErrors,
+			}
+			d.ask(result, config.Attempts, step.validators())
+			stepResult.Results = append(stepResult.Results, result)
+		}
+
+		for _, validator := range step.StepValidators {
+			check.Failures = append(check.Failures, validator(previous, stepResult.Results)...)
+		}
+
+		check.Steps = append(check.Steps, stepResult)
+		previous = stepResult.Results
 	}
 
 	return check
 }
 
-func queryAll(query *dns.Msg, nameservers []Nameserver) (map[Nameserver]*dns.Msg, map[Nameserver]error) {
-	replies := make(map[Nameserver]*dns.Msg)
-	errors := make(map[Nameserver]error)
+// ask sends a result's Questions to all of its Nameservers and validates the
+// replies.
+func (d *defaultChecker) ask(result *CheckResult, attempts int, validators []ExchangeValidator) {
+	result.Exchanges = queryAll(result.Questions, attempts)
+	result.Answers = make(map[Nameserver]*dns.Msg)
+	result.Errors = make(map[Nameserver]error)
+	for nameserver, exchange := range result.Exchanges {
+		if exchange.Err != nil {
+			result.Errors[nameserver] = exchange.Err
+			continue
+		}
+		result.Answers[nameserver] = exchange.Reply
+	}
+
+	for _, validator := range validators {
+		result.Failures = append(result.Failures, validator(result.question(), result.Exchanges)...)
+	}
+}
 
+// stepQuestionName names the result of a single question in a step after the
+// step and the question.
+func stepQuestionName(step string, q *dns.Msg) string {
+	if len(q.Question) == 0 {
+		return step
+	}
+	return fmt.Sprintf("%s (%s %s)", step, q.Question[0].Name, dns.Type(q.Question[0].Qtype))
+}
+
+// questions builds the question for every nameserver.
+func (c *Check) questions(fqdn string, nameservers []Nameserver) map[Nameserver]*dns.Msg {
+	if c.NameserverQuestion == nil {
+		return sameQuestion(c.Question(fqdn), nameservers)
+	}
+
+	questions := make(map[Nameserver]*dns.Msg, len(nameservers))
 	for _, nameserver := range nameservers {
-		client := dns.Client{
-			Net: nameserver.Proto.String(),
-		}
-		addr := net.JoinHostPort(nameserver.Hostname, nameserver.Port)
-		reply, _, err := client.Exchange(query, addr)
+		questions[nameserver] = c.NameserverQuestion(fqdn, nameserver)
+	}
+	return questions
+}
 
-		if err != nil {
-			errors[nameserver] = err
-			continue
+// sameQuestion asks every nameserver the same question.
+func sameQuestion(q *dns.Msg, nameservers []Nameserver) map[Nameserver]*dns.Msg {
+	questions := make(map[Nameserver]*dns.Msg, len(nameservers))
+	for _, nameserver := range nameservers {
+		questions[nameserver] = q
+	}
+	return questions
+}
+
+// question returns the question asked of every nameserver, or nil if the
+// nameservers were asked different questions.
+func (c *CheckResult) question() (q *dns.Msg) {
+	for _, question := range c.Questions {
+		if q != nil && q != question {
+			return nil
 		}
-		replies[nameserver] = reply
+		q = question
 	}
+	return q
+}
 
-	return replies, errors
+func queryAll(questions map[Nameserver]*dns.Msg, attempts int) map[Nameserver]*Exchange {
+	exchanges := make(map[Nameserver]*Exchange, len(questions))
+	for nameserver, q := range questions {
+		exchanges[nameserver] = ExchangeMsg(nameserver, q, attempts)
+	}
+	return exchanges
 }
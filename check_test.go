@@ -0,0 +1,297 @@
+package okaydns_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/okaycheck"
+	"github.com/blinsay/okaydns/okaytest"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+var checkA = okaydns.Check{
+	Name: "A record",
+	Question: func(fqdn string) *dns.Msg {
+		return okaydns.NonRecursiveQuestion(fqdn, dns.TypeA)
+	},
+	Validators: []okaydns.RequestResponseValidator{
+		okaycheck.EachNameserver(
+			okaycheck.AuthoritativeResponse,
+			okaycheck.ResponseCode(dns.RcodeSuccess),
+			okaycheck.AnswerContains(dns.TypeA),
+		),
+		okaycheck.EchoesQuery(),
+	},
+	ExchangeObservers: []okaydns.ExchangeObserver{
+		okaycheck.ResponseSizes,
+	},
+}
+
+func TestDoCheck(t *testing.T) {
+	s := okaytest.Start(t, okaytest.ExampleZone, "example.com.")
+	nameservers := s.Nameservers()
+
+	result := okaydns.DoCheck(&checkA, s.Origin(), nameservers)
+	assert.True(t, result.Success(), "expected success, got failures=%v errors=%v", result.Failures, result.Errors)
+	assert.Equal(t, "A record", result.Name)
+	assert.Equal(t, nameservers, result.Nameservers)
+	assert.Contains(t, result.Answers, nameservers[0])
+	assert.NotEmpty(t, result.Observations, "expected observers to run")
+	for _, o := range result.Observations {
+		if o.Name == "response_bytes" {
+			assert.Equal(t, strconv.Itoa(result.Exchanges[nameservers[0]].Size), o.Value, "response sizes should be the size on the wire")
+		}
+	}
+}
+
+func TestDoCheckFailures(t *testing.T) {
+	s := okaytest.Start(t, okaytest.ExampleZone, "example.com.")
+	s.Set(okaytest.Lame)
+
+	result := okaydns.DoCheck(&checkA, s.Origin(), s.Nameservers())
+	assert.False(t, result.Success())
+	assert.Empty(t, result.Errors)
+	for _, failure := range result.Failures {
+		assert.Equal(t, s.Nameservers()[0], failure.Nameserver, "failures should be attributed to the nameserver")
+	}
+}
+
+func TestDoCheckErrors(t *testing.T) {
+	s := okaytest.Start(t, okaytest.ExampleZone, "example.com.")
+	s.Set(okaytest.DropTCP)
+
+	tcp := checkA
+	tcp.ConfigureNameservers = func(_ []okaydns.Nameserver) []okaydns.Nameserver {
+		return []okaydns.Nameserver{s.Nameserver(okaydns.ProtoTCP)}
+	}
+
+	result := okaydns.DoCheck(&tcp, s.Origin(), s.Nameservers())
+	assert.False(t, result.Success())
+	assert.Contains(t, result.Errors, s.Nameserver(okaydns.ProtoTCP))
+	assert.IsType(t, &okaydns.ExchangeError{}, result.Errors[s.Nameserver(okaydns.ProtoTCP)])
+	assert.Empty(t, result.Answers)
+}
+
+func TestDoCheckTolerateErrors(t *testing.T) {
+	var nameservers []okaydns.Nameserver
+	for i := 0; i < 3; i++ {
+		s := okaytest.Start(t, okaytest.ExampleZone, "example.com.")
+		nameservers = append(nameservers, s.Nameserver(okaydns.ProtoUDP))
+		if i == 0 {
+			s.Close()
+		}
+	}
+
+	check := func(v okaydns.ExchangeValidator, tolerate bool) *okaydns.CheckResult {
+		return okaydns.DoCheck(&okaydns.Check{
+			Name: "quorum",
+			Question: func(fqdn string) *dns.Msg {
+				return okaydns.NonRecursiveQuestion(fqdn, dns.TypeSOA)
+			},
+			ExchangeValidators: []okaydns.ExchangeValidator{v},
+			TolerateErrors:     tolerate,
+		}, "example.com.", nameservers)
+	}
+
+	result := check(okaycheck.AtLeast(2, okaycheck.ResponseCode(dns.RcodeSuccess)), true)
+	assert.True(t, result.Success(), "expected success, got %v", result.Failures)
+	assert.Len(t, result.Errors, 1, "the stopped nameserver's error should still be recorded")
+	if assert.Len(t, result.Failures, 1) {
+		assert.Equal(t, okaycheck.CodeNoReply, result.Failures[0].Code)
+		assert.Equal(t, nameservers[0], result.Failures[0].Nameserver)
+		assert.Equal(t, okaydns.SeverityWarning, result.Failures[0].Severity)
+	}
+	assert.Equal(t, okaydns.StatusWarning, result.Status())
+
+	result = check(okaycheck.Majority(okaycheck.ResponseCode(dns.RcodeSuccess)), true)
+	assert.True(t, result.Success(), "expected success, got %v", result.Failures)
+
+	result = check(okaycheck.AtLeast(3, okaycheck.ResponseCode(dns.RcodeSuccess)), true)
+	assert.False(t, result.Success(), "a stopped nameserver should count against the quorum")
+
+	result = check(okaycheck.AtLeast(2, okaycheck.ResponseCode(dns.RcodeSuccess)), false)
+	assert.False(t, result.Success(), "errors should fail a check that doesn't tolerate them")
+}
+
+func TestDoCheckTruncated(t *testing.T) {
+	s := okaytest.Start(t, okaytest.ExampleZone, "example.com.")
+	s.Set(okaytest.Truncate)
+
+	result := okaydns.DoCheck(&checkA, s.Origin(), s.Nameservers())
+	assert.Empty(t, result.Errors, "a truncated reply is not an error")
+	if assert.Contains(t, result.Answers, s.Nameservers()[0]) {
+		assert.True(t, result.Answers[s.Nameservers()[0]].Truncated)
+	}
+}
+
+func TestDoCheckExchanges(t *testing.T) {
+	s := okaytest.Start(t, okaytest.ExampleZone, "example.com.")
+	s.Set(okaytest.DropTCP)
+
+	udp, tcp := s.Nameserver(okaydns.ProtoUDP), s.Nameserver(okaydns.ProtoTCP)
+
+	var seen map[okaydns.Nameserver]*okaydns.Exchange
+	check := checkA
+	check.ConfigureNameservers = func(_ []okaydns.Nameserver) []okaydns.Nameserver {
+		return []okaydns.Nameserver{udp, tcp}
+	}
+	check.ExchangeValidators = []okaydns.ExchangeValidator{
+		func(_ *dns.Msg, exchanges map[okaydns.Nameserver]*okaydns.Exchange) []okaydns.Failure {
+			seen = exchanges
+			return nil
+		},
+	}
+
+	result := okaydns.DoCheck(&check, s.Origin(), nil)
+	assert.Equal(t, result.Exchanges, seen)
+
+	if exchange, ok := seen[udp]; assert.True(t, ok) {
+		assert.NoError(t, exchange.Err)
+		assert.Equal(t, result.Answers[udp], exchange.Reply)
+		assert.Equal(t, okaydns.ProtoUDP, exchange.Proto)
+		assert.Equal(t, 1, exchange.Attempts)
+		assert.True(t, exchange.RTT > 0)
+
+		wire, err := exchange.Reply.Pack()
+		if assert.NoError(t, err) {
+			assert.Equal(t, len(wire), exchange.Size)
+		}
+	}
+
+	if exchange, ok := seen[tcp]; assert.True(t, ok) {
+		assert.Error(t, exchange.Err)
+		assert.Nil(t, exchange.Reply)
+		assert.Equal(t, result.Errors[tcp], exchange.Err)
+	}
+}
+
+const testMXZone = okaytest.ExampleZone + `
+@             IN MX    10 mail
+@             IN MX    20 backup
+mail          IN A     192.0.2.25
+backup        IN TXT   "no address here"
+`
+
+func TestDoCheckSteps(t *testing.T) {
+	s := okaytest.Start(t, testMXZone, "example.com.")
+
+	var stepNameservers []okaydns.Nameserver
+	check := okaydns.Check{
+		Name: "MX targets",
+		Question: func(fqdn string) *dns.Msg {
+			return okaydns.NonRecursiveQuestion(fqdn, dns.TypeMX)
+		},
+		Steps: []okaydns.Step{
+			{
+				Name: "target",
+				Questions: func(_ string, previous []*okaydns.CheckResult) (questions []*dns.Msg) {
+					for _, rr := range okaycheck.Records(previous, dns.TypeMX) {
+						questions = append(questions, okaydns.NonRecursiveQuestion(rr.(*dns.MX).Mx, dns.TypeA))
+					}
+					return questions
+				},
+				Nameservers: func(nameservers []okaydns.Nameserver, previous []*okaydns.CheckResult) []okaydns.Nameserver {
+					stepNameservers = nameservers
+					return nameservers
+				},
+				Validators: []okaydns.RequestResponseValidator{
+					okaycheck.EachNameserver(okaycheck.ResponseCode(dns.RcodeSuccess)),
+				},
+				StepValidators: []okaydns.StepValidator{
+					okaycheck.EveryNameHasAddress,
+				},
+			},
+		},
+	}
+
+	result := okaydns.DoCheck(&check, s.Origin(), s.Nameservers())
+	assert.Equal(t, s.Nameservers(), stepNameservers)
+	assert.False(t, result.Success(), "expected backup.example.com. to fail")
+
+	if assert.Len(t, result.Steps, 1) && assert.Len(t, result.Steps[0].Results, 2) {
+		for _, stepResult := range result.Steps[0].Results {
+			assert.True(t, stepResult.Success(), "%s: %v", stepResult.Name, stepResult.Failures)
+			assert.Len(t, stepResult.Exchanges, 1)
+		}
+	}
+	if assert.Len(t, result.Failures, 1) {
+		assert.Equal(t, okaycheck.CodeNoAddress, result.Failures[0].Code)
+		assert.Contains(t, result.Failures[0].Message, "backup.example.com.")
+	}
+}
+
+func TestDoCheckStepsWithoutQuestion(t *testing.T) {
+	s := okaytest.Start(t, okaytest.ExampleZone, "example.com.")
+
+	check := okaydns.Check{
+		Name: "NXDOMAIN",
+		Steps: []okaydns.Step{
+			{
+				Name: "SOA",
+				Questions: func(fqdn string, previous []*okaydns.CheckResult) []*dns.Msg {
+					assert.Nil(t, previous)
+					return []*dns.Msg{okaydns.NonRecursiveQuestion(fqdn, dns.TypeSOA)}
+				},
+			},
+			{
+				Name: "NXDOMAIN",
+				Questions: func(fqdn string, _ []*okaydns.CheckResult) []*dns.Msg {
+					return []*dns.Msg{okaydns.NonRecursiveQuestion("nope."+fqdn, dns.TypeA)}
+				},
+				StepValidators: []okaydns.StepValidator{
+					okaycheck.NegativeTTLMatchesSOA,
+				},
+			},
+		},
+	}
+
+	result := okaydns.DoCheck(&check, s.Origin(), s.Nameservers())
+	assert.True(t, result.Success(), "%v", result.Failures)
+	assert.Empty(t, result.Questions)
+	assert.Len(t, result.Steps, 2)
+}
+
+func TestDoCheckNameserverQuestion(t *testing.T) {
+	s := okaytest.Start(t, okaytest.ExampleZone, "example.com.")
+	udp, tcp := s.Nameserver(okaydns.ProtoUDP), s.Nameserver(okaydns.ProtoTCP)
+
+	var shared []*dns.Msg
+	check := okaydns.Check{
+		Name: "Per-nameserver questions",
+		ConfigureNameservers: func(_ []okaydns.Nameserver) []okaydns.Nameserver {
+			return []okaydns.Nameserver{udp, tcp}
+		},
+		NameserverQuestion: func(fqdn string, nameserver okaydns.Nameserver) *dns.Msg {
+			q := okaydns.NonRecursiveQuestion(fqdn, dns.TypeA)
+			if nameserver.Proto == okaydns.ProtoUDP {
+				q.SetEdns0(1232, false)
+			}
+			return q
+		},
+		Validators: []okaydns.RequestResponseValidator{
+			okaycheck.EchoesQuery(),
+			func(q *dns.Msg, replies map[okaydns.Nameserver]*dns.Msg) []okaydns.Failure {
+				assert.Len(t, replies, 1, "expected validators to run once per nameserver")
+				return nil
+			},
+		},
+		ExchangeValidators: []okaydns.ExchangeValidator{
+			func(q *dns.Msg, exchanges map[okaydns.Nameserver]*okaydns.Exchange) []okaydns.Failure {
+				shared = append(shared, q)
+				return nil
+			},
+		},
+	}
+
+	result := okaydns.DoCheck(&check, s.Origin(), nil)
+	assert.True(t, result.Success(), "%v %v", result.Failures, result.Errors)
+	assert.Equal(t, []*dns.Msg{nil}, shared, "expected no shared question")
+
+	if assert.Len(t, result.Questions, 2) {
+		assert.NotNil(t, result.Questions[udp].IsEdns0())
+		assert.Nil(t, result.Questions[tcp].IsEdns0())
+		assert.Equal(t, result.Questions[udp], result.Exchanges[udp].Query)
+	}
+}
@@ -0,0 +1,333 @@
+package checkdef
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/okaycheck"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// Codes for the failures returned by defined checks. Unexpected response codes
+// are reported with okaycheck.CodeResponseCode, missing records with
+// okaycheck.CodeMissingRecord, and TTLs out of range with
+// okaycheck.CodeTTLOutOfRange.
+const (
+	CodeFlag            = "unexpected-flag"
+	CodeSectionNotEmpty = "section-not-empty"
+	CodeMissingType     = "missing-type"
+	CodeUnexpectedType  = "unexpected-type"
+)
+
+// headerFlags maps flag names to the header bit they control.
+var headerFlags = map[string]func(h *dns.MsgHdr) *bool{
+	"qr": func(h *dns.MsgHdr) *bool { return &h.Response },
+	"aa": func(h *dns.MsgHdr) *bool { return &h.Authoritative },
+	"tc": func(h *dns.MsgHdr) *bool { return &h.Truncated },
+	"rd": func(h *dns.MsgHdr) *bool { return &h.RecursionDesired },
+	"ra": func(h *dns.MsgHdr) *bool { return &h.RecursionAvailable },
+	"z":  func(h *dns.MsgHdr) *bool { return &h.Zero },
+	"ad": func(h *dns.MsgHdr) *bool { return &h.AuthenticatedData },
+	"cd": func(h *dns.MsgHdr) *bool { return &h.CheckingDisabled },
+}
+
+// Check builds the Check described by a Definition. Any problem with the
+// Definition is returned as an error.
+func (d *Definition) Check() (okaydns.Check, error) {
+	template, err := d.Query.template()
+	if err != nil {
+		return okaydns.Check{}, errors.Wrapf(err, "check %s: query", d.ID)
+	}
+	if _, err := d.Query.Question("example.com."); err != nil {
+		return okaydns.Check{}, errors.Wrapf(err, "check %s: query", d.ID)
+	}
+	configure, err := d.Query.configureNameservers()
+	if err != nil {
+		return okaydns.Check{}, errors.Wrapf(err, "check %s: query", d.ID)
+	}
+	if _, err := d.Expect.validators("example.com."); err != nil {
+		return okaydns.Check{}, errors.Wrapf(err, "check %s: expect", d.ID)
+	}
+
+	name := d.Name
+	if name == "" {
+		name = d.ID
+	}
+
+	query, expect, severity := d.Query, d.Expect, d.Severity
+	return okaydns.Check{
+		ID:                   d.ID,
+		Name:                 name,
+		Requires:             d.Requires,
+		ConfigureNameservers: configure,
+		Question: func(fqdn string) *dns.Msg {
+			// the query was validated above and can't fail
+			q, _ := query.Question(fqdn)
+			return q
+		},
+		Validators: []okaydns.RequestResponseValidator{
+			func(q *dns.Msg, replies map[okaydns.Nameserver]*dns.Msg) []okaydns.Failure {
+				return expect.validate(severity, domainOf(template, q.Question[0].Name), replies)
+			},
+		},
+	}, nil
+}
+
+// validate checks every reply against an Expect. Every failure has the given
+// severity.
+func (e *Expect) validate(severity okaydns.Severity, domain string, replies map[okaydns.Nameserver]*dns.Msg) []okaydns.Failure {
+	validators, err := e.validators(domain)
+	if err != nil {
+		return []okaydns.Failure{{Message: err.Error(), Severity: severity}}
+	}
+
+	failures := okaycheck.EachNameserver(validators...)(nil, replies)
+	for i := range failures {
+		failures[i].Severity = severity
+	}
+	return failures
+}
+
+// Question builds the query described by a Query for the given fqdn.
+func (q *Query) Question(fqdn string) (*dns.Msg, error) {
+	template, err := q.template()
+	if err != nil {
+		return nil, err
+	}
+	qtype, err := okaydns.ParseType(q.Type)
+	if err != nil {
+		return nil, err
+	}
+	qclass := uint16(dns.ClassINET)
+	if q.Class != "" {
+		if qclass, err = okaydns.ParseClass(q.Class); err != nil {
+			return nil, err
+		}
+	}
+
+	m := okaydns.NonRecursiveClassQuestion(queryName(template, fqdn), qtype, qclass)
+	for _, flag := range q.Flags {
+		bit, ok := headerFlags[strings.ToLower(flag)]
+		if !ok {
+			return nil, errors.Errorf("unknown flag: %q", flag)
+		}
+		*bit(&m.MsgHdr) = true
+	}
+
+	if q.EDNS != nil {
+		udpSize := q.EDNS.UDPSize
+		if udpSize == 0 {
+			udpSize = dns.DefaultMsgSize
+		}
+		m.SetEdns0(udpSize, q.EDNS.DO)
+
+		opt := m.IsEdns0()
+		opt.SetVersion(q.EDNS.Version)
+		for _, code := range q.EDNS.Options {
+			opt.Option = append(opt.Option, &dns.EDNS0_LOCAL{Code: code, Data: []byte{}})
+		}
+	}
+
+	return m, nil
+}
+
+func (q *Query) configureNameservers() (func([]okaydns.Nameserver) []okaydns.Nameserver, error) {
+	var proto okaydns.Proto
+	switch strings.ToLower(q.Protocol) {
+	case "":
+		return nil, nil
+	case "udp":
+		proto = okaydns.ProtoUDP
+	case "tcp":
+		proto = okaydns.ProtoTCP
+	default:
+		return nil, errors.Errorf("unknown protocol: %q", q.Protocol)
+	}
+
+	return func(nameservers []okaydns.Nameserver) []okaydns.Nameserver {
+		configured := make([]okaydns.Nameserver, len(nameservers))
+		for i, ns := range nameservers {
+			configured[i] = ns
+			configured[i].Proto = proto
+		}
+		return configured
+	}, nil
+}
+
+// validators builds a MessageValidator for every expectation. Names in an
+// Expect are relative to domain.
+func (e *Expect) validators(domain string) ([]okaydns.MessageValidator, error) {
+	var validators []okaydns.MessageValidator
+
+	if e.Rcode != "" {
+		rcode, ok := dns.StringToRcode[strings.ToUpper(e.Rcode)]
+		if !ok {
+			return nil, errors.Errorf("unknown rcode: %q", e.Rcode)
+		}
+		validators = append(validators, okaycheck.ResponseCode(rcode))
+	}
+
+	for _, flag := range e.Flags {
+		name := strings.ToLower(strings.TrimPrefix(flag, "!"))
+		if _, ok := headerFlags[name]; !ok {
+			return nil, errors.Errorf("unknown flag: %q", flag)
+		}
+		validators = append(validators, flagIs(name, !strings.HasPrefix(flag, "!")))
+	}
+
+	sections := []struct {
+		section  okaycheck.Section
+		expected *Section
+	}{
+		{okaycheck.AnswerSection, e.Answer},
+		{okaycheck.AuthoritySection, e.Authority},
+		{okaycheck.AdditionalSection, e.Additional},
+	}
+	for _, s := range sections {
+		if s.expected == nil {
+			continue
+		}
+		sectionValidators, err := s.expected.validators(s.section, domain)
+		if err != nil {
+			return nil, errors.Wrap(err, s.section.String())
+		}
+		validators = append(validators, sectionValidators...)
+	}
+
+	return validators, nil
+}
+
+func flagIs(name string, set bool) okaydns.MessageValidator {
+	return func(m *dns.Msg) []okaydns.Failure {
+		if *headerFlags[name](&m.MsgHdr) == set {
+			return nil
+		}
+		state := "not set"
+		if !set {
+			state = "set"
+		}
+		return []okaydns.Failure{{
+			Message: fmt.Sprintf("the %s flag is %s", name, state),
+			Code:    CodeFlag,
+		}}
+	}
+}
+
+func (s *Section) validators(section okaycheck.Section, domain string) ([]okaydns.MessageValidator, error) {
+	var validators []okaydns.MessageValidator
+
+	if s.Empty {
+		validators = append(validators, func(m *dns.Msg) []okaydns.Failure {
+			if rrs := section.Records(m); len(rrs) > 0 {
+				return []okaydns.Failure{{
+					Message: fmt.Sprintf("%s section has %d records", section, len(rrs)),
+					Code:    CodeSectionNotEmpty,
+				}}
+			}
+			return nil
+		})
+	}
+
+	for _, name := range s.Contains {
+		rrtype, err := okaydns.ParseType(name)
+		if err != nil {
+			return nil, errors.Wrap(err, "contains")
+		}
+		validators = append(validators, func(m *dns.Msg) []okaydns.Failure {
+			if len(ofType(section.Records(m), rrtype)) == 0 {
+				return []okaydns.Failure{{
+					Message: fmt.Sprintf("%s section has no %s records", section, dns.Type(rrtype)),
+					Code:    CodeMissingType,
+				}}
+			}
+			return nil
+		})
+	}
+
+	for _, name := range s.Excludes {
+		rrtype, err := okaydns.ParseType(name)
+		if err != nil {
+			return nil, errors.Wrap(err, "excludes")
+		}
+		validators = append(validators, func(m *dns.Msg) []okaydns.Failure {
+			if len(ofType(section.Records(m), rrtype)) > 0 {
+				return []okaydns.Failure{{
+					Message: fmt.Sprintf("%s section has %s records", section, dns.Type(rrtype)),
+					Code:    CodeUnexpectedType,
+				}}
+			}
+			return nil
+		})
+	}
+
+	for i := range s.Records {
+		recordValidators, err := s.Records[i].validators(section, domain)
+		if err != nil {
+			return nil, errors.Wrapf(err, "record %d", i)
+		}
+		validators = append(validators, recordValidators...)
+	}
+
+	return validators, nil
+}
+
+func ofType(records []dns.RR, rrtype uint16) (found []dns.RR) {
+	for _, rr := range records {
+		if rr.Header().Rrtype == rrtype {
+			found = append(found, rr)
+		}
+	}
+	return found
+}
+
+// validators builds a MessageValidator that checks that a section contains at
+// least one match for a Record, and one that checks that every match has a TTL
+// in range.
+func (r *Record) validators(section okaycheck.Section, domain string) ([]okaydns.MessageValidator, error) {
+	rrtype, err := okaydns.ParseType(r.Type)
+	if err != nil {
+		return nil, err
+	}
+	if r.MinTTL != nil && r.MaxTTL != nil && *r.MinTTL > *r.MaxTTL {
+		return nil, errors.Errorf("min_ttl %d is greater than max_ttl %d", *r.MinTTL, *r.MaxTTL)
+	}
+
+	description := fmt.Sprintf("%s record", dns.Type(rrtype))
+	filters := []okaycheck.RecordFilter{okaycheck.OfType(rrtype)}
+	if r.Name != "" {
+		owner := okaydns.RelativeName(r.Name, domain)
+		description += " for " + owner
+		filters = append(filters, okaycheck.Owner(owner))
+	}
+	if r.Value != "" {
+		want, err := dns.NewRR(fmt.Sprintf("$ORIGIN %s\n@ 0 %s %s", domain, dns.Type(rrtype), r.Value))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s value %q", r.Type, r.Value)
+		}
+		description += " with value " + strings.TrimPrefix(want.String(), want.Header().String())
+		filters = append(filters, okaycheck.SameData(want))
+	}
+
+	min, max := uint32(0), uint32(math.MaxUint32)
+	if r.MinTTL != nil {
+		min = *r.MinTTL
+	}
+	if r.MaxTTL != nil {
+		max = *r.MaxTTL
+	}
+
+	contains := okaycheck.Contains(section, filters...)
+	return []okaydns.MessageValidator{
+		func(m *dns.Msg) []okaydns.Failure {
+			failures := contains(m)
+			for i := range failures {
+				failures[i].Message = fmt.Sprintf("%s section has no %s", section, description)
+			}
+			return failures
+		},
+		okaycheck.TTLInRange(section, min, max, filters...),
+	}, nil
+}
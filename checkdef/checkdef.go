@@ -0,0 +1,312 @@
+// Package checkdef loads checks from declarative definition files, so that
+// domain-specific checks can be written without any Go.
+//
+// A definition file is a JSON or YAML object with a list of checks. Every
+// check describes a single query to send to every nameserver and what every
+// nameserver's reply is expected to look like:
+//
+//	{
+//	  "checks": [
+//	    {
+//	      "id": "www-cdn",
+//	      "name": "www is on the CDN",
+//	      "query": {"name": "www", "type": "A"},
+//	      "expect": {
+//	        "rcode": "NOERROR",
+//	        "flags": ["aa", "!tc"],
+//	        "answer": {
+//	          "records": [
+//	            {"name": "www", "type": "CNAME", "value": "cdn.example.net.", "max_ttl": 300}
+//	          ]
+//	        }
+//	      }
+//	    }
+//	  ]
+//	}
+//
+// The same check in YAML:
+//
+//	checks:
+//	  - id: www-cdn
+//	    name: www is on the CDN
+//	    query: {name: www, type: A}
+//	    expect:
+//	      rcode: NOERROR
+//	      flags: [aa, "!tc"]
+//	      answer:
+//	        records:
+//	          - {name: www, type: CNAME, value: cdn.example.net., max_ttl: 300}
+package checkdef
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// A File is a set of check definitions.
+type File struct {
+	Checks []Definition `json:"checks"`
+}
+
+// A Definition describes a check. Name defaults to the ID. Description and Tags
+// aren't used by the check itself, but describe it in a registry. Every failure
+// of the check has the given Severity.
+type Definition struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Tags        []string         `json:"tags,omitempty"`
+	Requires    []string         `json:"requires,omitempty"`
+	Severity    okaydns.Severity `json:"severity,omitempty"`
+	Query       Query            `json:"query"`
+	Expect      Expect           `json:"expect"`
+}
+
+// A Query describes the question to send to every nameserver.
+//
+// Name is a template relative to the domain being checked. An empty Name or "@"
+// is the domain itself, and a Name ending in a "." is used as-is. A Name may end
+// in "{domain}" to be explicit about where the domain being checked goes. Any
+// "{random}" is replaced with a random label, so "{random}" is a name that very
+// likely doesn't exist.
+//
+// Type and Class are mnemonics like "A" and "CH", or a number written as
+// "TYPE1234" or "CLASS1234". Protocol is "udp" or "tcp" and defaults to the
+// protocol of each nameserver. Flags are set in the query and are any of "qr",
+// "aa", "tc", "rd", "ra", "z", "ad" and "cd". Queries are not recursive unless
+// the "rd" flag is given.
+type Query struct {
+	Name     string   `json:"name,omitempty"`
+	Type     string   `json:"type"`
+	Class    string   `json:"class,omitempty"`
+	Protocol string   `json:"protocol,omitempty"`
+	Flags    []string `json:"flags,omitempty"`
+	EDNS     *EDNS    `json:"edns,omitempty"`
+}
+
+// EDNS describes the OPT record to include with a Query. Options is a list of
+// EDNS option codes to include with empty data, like the NSID option (3).
+type EDNS struct {
+	Version uint8    `json:"version,omitempty"`
+	UDPSize uint16   `json:"udp_size,omitempty"`
+	DO      bool     `json:"do,omitempty"`
+	Options []uint16 `json:"options,omitempty"`
+}
+
+// Expect describes what every nameserver's reply must look like. Anything left
+// out isn't checked.
+//
+// Rcode is a response code mnemonic like "NOERROR" or "NXDOMAIN". Flags are
+// header flags that must be set, or must be clear if they start with a "!".
+type Expect struct {
+	Rcode      string   `json:"rcode,omitempty"`
+	Flags      []string `json:"flags,omitempty"`
+	Answer     *Section `json:"answer,omitempty"`
+	Authority  *Section `json:"authority,omitempty"`
+	Additional *Section `json:"additional,omitempty"`
+}
+
+// A Section describes one section of a reply. If Empty is set the section must
+// have no records. Contains and Excludes are record types the section must and
+// must not have records of. Every one of Records must match at least one record
+// in the section.
+//
+// The OPT pseudo-record is never part of the Additional section.
+type Section struct {
+	Empty    bool     `json:"empty,omitempty"`
+	Contains []string `json:"contains,omitempty"`
+	Excludes []string `json:"excludes,omitempty"`
+	Records  []Record `json:"records,omitempty"`
+}
+
+// A Record describes records a section must contain.
+//
+// Name is relative to the domain being checked, and matches records with any
+// owner name if it's empty. Value is the record data in zone file syntax, with
+// names relative to the domain being checked. A record with any data matches if
+// Value is empty. Names, and names in values, are compared case-insensitively.
+// Every matching record must have a TTL between MinTTL and MaxTTL.
+//
+// When the query name is absolute, names in a Record are relative to the query
+// name instead of the domain being checked.
+type Record struct {
+	Name   string  `json:"name,omitempty"`
+	Type   string  `json:"type"`
+	Value  string  `json:"value,omitempty"`
+	MinTTL *uint32 `json:"min_ttl,omitempty"`
+	MaxTTL *uint32 `json:"max_ttl,omitempty"`
+}
+
+// Load reads a JSON or YAML encoded File from r and validates every definition
+// in it. YAML files use the same field names as JSON files.
+func Load(r io.Reader) (*File, error) {
+	var f File
+	if err := Decode(r, &f); err != nil {
+		return nil, errors.Wrap(err, "invalid check definitions")
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Decode reads a single JSON or YAML document from r and decodes it into v
+// with encoding/json, so that YAML documents use the same field names as JSON
+// documents. Fields that v doesn't have are an error.
+func Decode(r io.Reader, v interface{}) error {
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if bs, err = yamlToJSON(bs); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(bs))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// yamlToJSON converts a YAML document to JSON, so that YAML files can be
+// decoded as strictly as JSON files. Documents that look like JSON are
+// returned unchanged.
+func yamlToJSON(bs []byte) ([]byte, error) {
+	if trimmed := bytes.TrimSpace(bs); len(trimmed) > 0 && trimmed[0] == '{' {
+		return bs, nil
+	}
+
+	var doc interface{}
+	if err := yaml.Unmarshal(bs, &doc); err != nil {
+		return nil, err
+	}
+	doc, err := jsonValue(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// jsonValue converts a value decoded from YAML to one that can be encoded as
+// JSON. YAML maps may have keys of any type, but JSON objects only have string
+// keys.
+func jsonValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			k, ok := key.(string)
+			if !ok {
+				return nil, errors.Errorf("non-string key: %v", key)
+			}
+			converted, err := jsonValue(value)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = converted
+		}
+		return m, nil
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			converted, err := jsonValue(value)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = converted
+		}
+		return s, nil
+	default:
+		return v, nil
+	}
+}
+
+// Validate checks that every definition has a unique ID and can be turned into
+// a Check.
+func (f *File) Validate() error {
+	_, err := f.Build()
+	return err
+}
+
+// Build returns a Check for every definition in a File, in order.
+func (f *File) Build() ([]okaydns.Check, error) {
+	ids := make(map[string]struct{}, len(f.Checks))
+	checks := make([]okaydns.Check, 0, len(f.Checks))
+
+	for i := range f.Checks {
+		def := &f.Checks[i]
+		if def.ID == "" {
+			return nil, errors.Errorf("check %d: missing an id", i)
+		}
+		if _, ok := ids[def.ID]; ok {
+			return nil, errors.Errorf("check %s: duplicate id", def.ID)
+		}
+		ids[def.ID] = struct{}{}
+
+		check, err := def.Check()
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+
+	return checks, nil
+}
+
+// template returns a Query's name template with the domain being checked made
+// explicit as a "{domain}" suffix. Absolute names have no "{domain}".
+func (q *Query) template() (string, error) {
+	name := q.Name
+	switch {
+	case name == "" || name == "@":
+		name = domainPlaceholder
+	case dns.IsFqdn(name) || strings.HasSuffix(name, domainPlaceholder):
+	default:
+		name += "." + domainPlaceholder
+	}
+
+	if i := strings.Index(name, domainPlaceholder); i >= 0 {
+		if i != len(name)-len(domainPlaceholder) || (i > 0 && name[i-1] != '.') {
+			return "", errors.Errorf("%s can only be the last labels of a name: %q", domainPlaceholder, q.Name)
+		}
+	}
+	return name, nil
+}
+
+const (
+	domainPlaceholder = "{domain}"
+	randomPlaceholder = "{random}"
+)
+
+// queryName expands a name template for the given fqdn.
+func queryName(template, fqdn string) string {
+	name := strings.Replace(template, domainPlaceholder, fqdn, -1)
+	return strings.Replace(name, randomPlaceholder, okaydns.RandomLabel(), -1)
+}
+
+// domainOf finds the domain being checked in a query name built from template.
+// Random labels are always a single label, so the domain is everything after
+// the labels in the template before "{domain}". Returns the query name itself
+// for templates without a "{domain}".
+func domainOf(template, qname string) string {
+	if !strings.HasSuffix(template, domainPlaceholder) {
+		return qname
+	}
+
+	prefix := 0
+	if p := strings.TrimSuffix(template, domainPlaceholder); p != "" {
+		prefix = dns.CountLabel(p)
+	}
+	labels := dns.Split(qname)
+	if prefix >= len(labels) {
+		return "."
+	}
+	return qname[labels[prefix]:]
+}
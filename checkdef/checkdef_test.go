@@ -0,0 +1,190 @@
+package checkdef_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/checkdef"
+	"github.com/blinsay/okaydns/okaycheck"
+	"github.com/blinsay/okaydns/okaytest"
+	"github.com/stretchr/testify/assert"
+)
+
+const testZone = okaytest.ExampleZone + `
+@             IN MX    10 mail
+mail          IN A     192.0.2.25
+www    3600   IN CNAME cdn.example.net.
+`
+
+func TestLoadErrors(t *testing.T) {
+	tcs := []struct {
+		name       string
+		definition string
+		err        string
+	}{
+		{"not json", `{"checks": [}`, "invalid check definitions"},
+		{"not yaml", "checks: [\n", "invalid check definitions"},
+		{"unknown yaml field", "checks:\n  - id: a\n    query: {type: A}\n    expct: {}\n", "unknown field"},
+		{"unknown field", `{"checks": [{"id": "a", "query": {"type": "A"}, "expct": {}}]}`, "unknown field"},
+		{"missing id", `{"checks": [{"query": {"type": "A"}}]}`, "check 0: missing an id"},
+		{"duplicate id", `{"checks": [{"id": "a", "query": {"type": "A"}}, {"id": "a", "query": {"type": "A"}}]}`, "check a: duplicate id"},
+		{"bad severity", `{"checks": [{"id": "a", "severity": "fatal", "query": {"type": "A"}}]}`, "unknown severity"},
+		{"bad qtype", `{"checks": [{"id": "a", "query": {"type": "AAAAA"}}]}`, `check a: query: unknown type: "AAAAA"`},
+		{"bad class", `{"checks": [{"id": "a", "query": {"type": "A", "class": "XX"}}]}`, `check a: query: unknown class: "XX"`},
+		{"bad query flag", `{"checks": [{"id": "a", "query": {"type": "A", "flags": ["xx"]}}]}`, `check a: query: unknown flag: "xx"`},
+		{"bad protocol", `{"checks": [{"id": "a", "query": {"type": "A", "protocol": "quic"}}]}`, `check a: query: unknown protocol: "quic"`},
+		{"domain in the middle", `{"checks": [{"id": "a", "query": {"name": "{domain}.www", "type": "A"}}]}`, "check a: query: {domain} can only be the last labels of a name"},
+		{"bad rcode", `{"checks": [{"id": "a", "query": {"type": "A"}, "expect": {"rcode": "NOPE"}}]}`, `check a: expect: unknown rcode: "NOPE"`},
+		{"bad expected flag", `{"checks": [{"id": "a", "query": {"type": "A"}, "expect": {"flags": ["!xx"]}}]}`, `check a: expect: unknown flag: "!xx"`},
+		{
+			"bad contains type",
+			`{"checks": [{"id": "a", "query": {"type": "A"}, "expect": {"authority": {"contains": ["NOPE"]}}}]}`,
+			`check a: expect: authority: contains: unknown type: "NOPE"`,
+		},
+		{
+			"bad record value",
+			`{"checks": [{"id": "a", "query": {"type": "A"}, "expect": {"answer": {"records": [{"type": "A", "value": "not-an-ip"}]}}}]}`,
+			`check a: expect: answer: record 0: invalid A value "not-an-ip"`,
+		},
+		{
+			"bad ttl range",
+			`{"checks": [{"id": "a", "query": {"type": "A"}, "expect": {"answer": {"records": [{"type": "A", "min_ttl": 300, "max_ttl": 60}]}}}]}`,
+			"check a: expect: answer: record 0: min_ttl 300 is greater than max_ttl 60",
+		},
+	}
+
+	for _, tc := range tcs {
+		_, err := checkdef.Load(strings.NewReader(tc.definition))
+		if assert.Error(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.err, tc.name)
+		}
+	}
+}
+
+func TestLoadYAML(t *testing.T) {
+	fromJSON, err := checkdef.Load(strings.NewReader(`{
+		"checks": [
+			{
+				"id": "www-cdn",
+				"name": "www is on the CDN",
+				"query": {"name": "www", "type": "A"},
+				"expect": {
+					"rcode": "NOERROR",
+					"flags": ["aa", "!tc"],
+					"answer": {
+						"records": [{"name": "www", "type": "CNAME", "value": "cdn.example.net.", "max_ttl": 300}]
+					}
+				}
+			}
+		]
+	}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	fromYAML, err := checkdef.Load(strings.NewReader(`
+checks:
+  - id: www-cdn
+    name: www is on the CDN
+    query: {name: www, type: A}
+    expect:
+      rcode: NOERROR
+      flags: [aa, "!tc"]
+      answer:
+        records:
+          - {name: www, type: CNAME, value: cdn.example.net., max_ttl: 300}
+`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, fromJSON, fromYAML)
+	}
+}
+
+func TestDefinedChecks(t *testing.T) {
+	s := okaytest.Start(t, testZone, "example.com.")
+
+	f, err := checkdef.Load(strings.NewReader(`{
+		"checks": [
+			{
+				"id": "www-cdn",
+				"name": "www is on the CDN",
+				"query": {"name": "www", "type": "A", "protocol": "tcp"},
+				"expect": {
+					"rcode": "NOERROR",
+					"flags": ["aa", "!tc", "!ra"],
+					"answer": {
+						"contains": ["CNAME"],
+						"records": [{"name": "www", "type": "CNAME", "value": "cdn.example.net.", "min_ttl": 60}]
+					}
+				}
+			},
+			{
+				"id": "www-ttl",
+				"severity": "warning",
+				"query": {"name": "www.{domain}", "type": "A"},
+				"expect": {
+					"answer": {"records": [{"type": "CNAME", "max_ttl": 300}]}
+				}
+			},
+			{
+				"id": "mx",
+				"query": {"type": "MX"},
+				"expect": {
+					"answer": {"records": [{"type": "MX", "value": "10 mail", "max_ttl": 300}]},
+					"additional": {"empty": true}
+				}
+			},
+			{
+				"id": "mx-wrong",
+				"query": {"name": "@", "type": "MX"},
+				"expect": {
+					"answer": {"records": [{"type": "MX", "value": "20 mail"}]}
+				}
+			},
+			{
+				"id": "nxdomain",
+				"requires": ["mx"],
+				"query": {"name": "{random}", "type": "A", "edns": {"udp_size": 1232, "options": [3]}},
+				"expect": {
+					"rcode": "NXDOMAIN",
+					"answer": {"empty": true},
+					"authority": {"contains": ["SOA"], "excludes": ["NS"], "records": [{"name": "@", "type": "SOA"}]}
+				}
+			}
+		]
+	}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	checks, err := f.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	results := okaydns.DoChecks(checks, s.Origin(), s.Nameservers())
+	if !assert.Len(t, results, 5) {
+		return
+	}
+
+	assert.Equal(t, "www is on the CDN", results[0].Name)
+	assert.Equal(t, okaydns.StatusOK, results[0].Status(), "%v %v", results[0].Failures, results[0].Errors)
+	for ns := range results[0].Questions {
+		assert.Equal(t, okaydns.ProtoTCP, ns.Proto)
+	}
+
+	assert.Equal(t, "www-ttl", results[1].Name, "name should default to the id")
+	assert.Equal(t, okaydns.StatusWarning, results[1].Status())
+	if assert.Len(t, results[1].Failures, 1) {
+		assert.Equal(t, okaycheck.CodeTTLOutOfRange, results[1].Failures[0].Code)
+	}
+
+	assert.Equal(t, okaydns.StatusOK, results[2].Status(), "%v %v", results[2].Failures, results[2].Errors)
+
+	assert.Equal(t, okaydns.StatusFailed, results[3].Status())
+	if assert.Len(t, results[3].Failures, 1) {
+		assert.Equal(t, okaycheck.CodeMissingRecord, results[3].Failures[0].Code)
+		assert.Contains(t, results[3].Failures[0].Message, "20 mail.example.com.")
+	}
+
+	assert.Equal(t, okaydns.StatusOK, results[4].Status(), "%v %v", results[4].Failures, results[4].Errors)
+}
@@ -1,162 +1,97 @@
 package main
 
 import (
-	"github.com/blinsay/okaydns"
-	"github.com/blinsay/okaydns/okaycheck"
-	"github.com/miekg/dns"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/blinsay/okaydns/checkdef"
+	"github.com/blinsay/okaydns/policy"
+	"github.com/blinsay/okaydns/stdchecks"
 )
 
-var defaultChecks = []okaydns.Check{
-	checkA,
-	checkAOverTCP,
-	checkNoCNAMEAtRoot,
-	check0x20,
-	checkUnknownQuestion,
-	checkSOA,
-}
+// loadCheckDefinitions loads the checks defined in a file as registry entries.
+// Defined checks are always run by default.
+func loadCheckDefinitions(filename string) ([]stdchecks.Entry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
 
-// Checks that there is an A record and no CNAME at the given domain. This is a
-// basic sanity check.
-var checkA = okaydns.Check{
-	Name: "A record",
-	Question: func(fqdn string) *dns.Msg {
-		return okaydns.NonRecursiveQuestion(fqdn, dns.TypeA)
-	},
-	Validators: []okaydns.RequestResponseValidator{
-		okaycheck.EachNameserver(
-			okaycheck.AuthoritativeResponse,
-			okaycheck.ResponseCode(dns.RcodeSuccess),
-			okaycheck.AnswerContains(dns.TypeA),
-		),
-	},
-}
+	definitions, err := checkdef.Load(f)
+	if err != nil {
+		return nil, err
+	}
+	checks, err := definitions.Build()
+	if err != nil {
+		return nil, err
+	}
 
-// Validates that an A query succeeds over TCP. Uses the same validations as
-// the CheckA check.
-var checkAOverTCP = okaydns.Check{
-	Name: "A record (TCP)",
-	Question: func(fqdn string) *dns.Msg {
-		return okaydns.NonRecursiveQuestion(fqdn, dns.TypeA)
-	},
-	ConfigureNameservers: func(nameservers []okaydns.Nameserver) []okaydns.Nameserver {
-		tcpns := make([]okaydns.Nameserver, len(nameservers))
-		for i, ns := range nameservers {
-			tcpns[i] = ns
-			tcpns[i].Proto = okaydns.ProtoTCP
+	entries := make([]stdchecks.Entry, len(checks))
+	for i, check := range checks {
+		entries[i] = stdchecks.Entry{
+			Check:       check,
+			Description: definitions.Checks[i].Description,
+			Tags:        definitions.Checks[i].Tags,
+			Default:     true,
 		}
-		return tcpns
-	},
-	Validators: []okaydns.RequestResponseValidator{
-		okaycheck.EachNameserver(
-			okaycheck.AuthoritativeResponse,
-			okaycheck.ResponseCode(dns.RcodeSuccess),
-		),
-	},
-}
-
-var checkNoCNAMEAtRoot = okaydns.Check{
-	Name: "Not a CNAME",
-	Question: func(fqdn string) *dns.Msg {
-		return okaydns.NonRecursiveQuestion(fqdn, dns.TypeCNAME)
-	},
-	Validators: []okaydns.RequestResponseValidator{
-		okaycheck.EachNameserver(
-			okaycheck.AuthoritativeResponse,
-			okaycheck.ResponseCode(dns.RcodeSuccess),
-			okaycheck.AnswerIsEmpty,
-		),
-	},
-}
-
-// Checks that nameservers respond to queries with the same capitalization of
-// domains as the question.
-//
-// See:
-// - https://tools.ietf.org/html/draft-vixie-dnsext-dns0x20-00
-var check0x20 = okaydns.Check{
-	Name: "Handles 0x20 randomization",
-	Question: func(fqdn string) *dns.Msg {
-		return okaydns.NonRecursiveQuestion(okaydns.RandomizeCase(fqdn), dns.TypeA)
-	},
-	Validators: []okaydns.RequestResponseValidator{
-		okaycheck.EachNameserver(
-			okaycheck.AuthoritativeResponse,
-			okaycheck.ResponseCode(dns.RcodeSuccess),
-			okaycheck.AnswerContains(dns.TypeA),
-		),
-		validateCaseMatches,
-	},
+	}
+	return entries, nil
 }
 
-func validateCaseMatches(q *dns.Msg, replies map[okaydns.Nameserver]*dns.Msg) (failures []okaydns.Failure) {
-	if len(q.Question) != 1 {
-		return []okaydns.Failure{{Message: "missing a question"}}
+// loadPolicy loads a domain policy from a file. Policies without a name are
+// named after the file they're loaded from, so that the checks from different
+// policy files have different IDs.
+func loadPolicy(filename string) (*policy.Policy, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
 	}
+	defer f.Close()
 
-	target := q.Question[0].Name
-
-	for nameserver, reply := range replies {
-		for _, answer := range reply.Answer {
-			if answer.Header().Name != target {
-				failures = append(failures, okaydns.Failure{
-					Nameserver: nameserver,
-					Message:    "case does not match",
-				})
-			}
-		}
+	p, err := policy.Load(f)
+	if err != nil {
+		return nil, err
 	}
-
-	return
-}
-
-// Validates that nameservers respond to a question with an unknown query syntax
-// by returning an empty answer with an okay response code.
-var checkUnknownQuestion = okaydns.Check{
-	Name: "Handles unknown question types",
-	Question: func(fqdn string) *dns.Msg {
-		return okaydns.NonRecursiveQuestion(fqdn, typeUnknownQuestion)
-	},
-	Validators: []okaydns.RequestResponseValidator{
-		okaycheck.EachNameserver(
-			okaycheck.AuthoritativeResponse,
-			okaycheck.ResponseCode(dns.RcodeSuccess),
-			okaycheck.AnswerIsEmpty,
-		),
-	},
+	if p.Name == "" {
+		base := filepath.Base(filename)
+		p.Name = strings.ToLower(strings.Replace(strings.TrimSuffix(base, filepath.Ext(base)), ".", "-", -1))
+	}
+	return p, nil
 }
 
-const typeUnknownQuestion uint16 = 666
-
-// Validates that nameservers all return authoritative SOA records for this
-// domain and that their serials match.
-var checkSOA = okaydns.Check{
-	Name: "SOA serials match",
-	Question: func(fqdn string) *dns.Msg {
-		return okaydns.NonRecursiveQuestion(fqdn, dns.TypeSOA)
-	},
-	Validators: []okaydns.RequestResponseValidator{
-		okaycheck.EachNameserver(
-			okaycheck.AuthoritativeResponse,
-			okaycheck.ResponseCode(dns.RcodeSuccess),
-			okaycheck.AnswerContains(dns.TypeSOA),
-		),
-		validateSerialsMatch,
-	},
-}
+// checksMain lists every standard and defined check with its ID, tags, and
+// description. Checks that would be selected by the -tag and -check flags are
+// marked.
+func checksMain(args []string) int {
+	flags := flag.NewFlagSet("checks", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s [-tag tag] [-check pattern] checks\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "checks lists the standard checks and any checks loaded with -checkfile.\n")
+		fmt.Fprintf(flags.Output(), "checks marked with a * are run by default, or would be run with the\n")
+		fmt.Fprintf(flags.Output(), "given -tag and -check options.\n")
+	}
+	flags.Parse(args)
 
-func validateSerialsMatch(_ *dns.Msg, replies map[okaydns.Nameserver]*dns.Msg) (failures []okaydns.Failure) {
-	serials := make(map[uint32]struct{})
+	selected := make(map[string]bool)
+	for _, check := range selectedChecks() {
+		selected[check.ID] = true
+	}
 
-	for _, reply := range replies {
-		for _, answer := range reply.Answer {
-			if soa, ok := answer.(*dns.SOA); ok {
-				serials[soa.Serial] = struct{}{}
-			}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "\tID\tTAGS\tDESCRIPTION\n")
+	for _, entry := range append(stdchecks.Standard().Entries(), definedChecks...) {
+		mark := ""
+		if selected[entry.Check.ID] {
+			mark = "*"
 		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", mark, entry.Check.ID, strings.Join(entry.Tags, ","), entry.Description)
 	}
+	w.Flush()
 
-	if len(serials) > 1 {
-		failures = append(failures, okaydns.Failure{Message: "SOA queries return more than one serial"})
-	}
-	return
+	return 0
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/zone"
+)
+
+// driftMain compares every RRset in a zone file with the answers from the
+// zone's authoritative nameservers, or the nameservers given with -ns. Returns
+// a non-zero exit code if any nameserver doesn't match the zone.
+func driftMain(args []string) int {
+	flags := flag.NewFlagSet("drift", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s [output flags] drift [options] [zone file]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "drift queries every RRset in a zone file against every authoritative\n")
+		fmt.Fprintf(flags.Output(), "nameserver for the zone and reports records that are missing, extra or\n")
+		fmt.Fprintf(flags.Output(), "have a different TTL.\n\n")
+		fmt.Fprintf(flags.Output(), "available options:\n")
+		flags.PrintDefaults()
+	}
+	origin := flags.String("origin", "", "the `zone` name. defaults to the owner of the SOA record in the file")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	z, err := loadZone(flags.Arg(0), *origin)
+	if err != nil {
+		log.Fatalln("error:", err)
+	}
+
+	seedns, err := configuredNameserver("/etc/resolv.conf")
+	if err != nil {
+		log.Fatalln("error loading local nameserver info from /etc/resolv.conf:", err)
+	}
+	nameservers, err := findNameservers(seedns, z.Origin, targetNameservers)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	checks := zone.DriftChecks(z)
+	bs, err := formatter.FormatHeader(z.Origin, checks, nameservers)
+	if err != nil {
+		panic(err)
+	}
+	if bs != nil {
+		log.Print(string(bs))
+	}
+
+	exitCode := 0
+	for _, check := range checks {
+		result := okaydns.DoCheck(&check, z.Origin, nameservers)
+		if !result.Success() {
+			exitCode = 1
+		}
+
+		bs, err := formatter.FormatCheck(result)
+		if err != nil {
+			panic(err)
+		}
+		log.Print(string(bs))
+	}
+
+	return exitCode
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"sort"
+
+	"github.com/blinsay/okaydns"
+	okfingerprint "github.com/blinsay/okaydns/fingerprint"
+)
+
+// loadFingerprints loads a fingerprint database from filename and merges it
+// into db.
+func loadFingerprints(db *okfingerprint.Database, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	extra, err := okfingerprint.Load(f)
+	if err != nil {
+		return err
+	}
+	return db.Merge(extra)
+}
+
+// fingerprintResult identifies every nameserver and reports the results as a
+// CheckResult. In verbose mode the signature and banner of every probe are
+// included, so that they can be copied into a fingerprint database.
+func fingerprintResult(fqdn string, nameservers []okaydns.Nameserver) *okaydns.CheckResult {
+	cr := &okaydns.CheckResult{
+		Name:        "Fingerprint",
+		Nameservers: nameservers,
+	}
+
+	for _, result := range okfingerprint.Identify(fingerprints, fqdn, nameservers) {
+		cr.Observations = append(cr.Observations, okaydns.Observation{
+			Name:       "implementation",
+			Value:      result.Implementation(),
+			Nameserver: result.Nameserver,
+		})
+
+		if !verbose {
+			continue
+		}
+
+		probes := make([]string, 0, len(result.Signatures))
+		for probe := range result.Signatures {
+			probes = append(probes, probe)
+		}
+		sort.Strings(probes)
+		for _, probe := range probes {
+			cr.Observations = append(cr.Observations, okaydns.Observation{
+				Name:       probe,
+				Value:      result.Signatures[probe],
+				Nameserver: result.Nameserver,
+			})
+			if banner, ok := result.Banners[probe]; ok {
+				cr.Observations = append(cr.Observations, okaydns.Observation{
+					Name:       probe + " banner",
+					Value:      banner,
+					Nameserver: result.Nameserver,
+				})
+			}
+		}
+	}
+
+	return cr
+}
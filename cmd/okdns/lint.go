@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/zone"
+)
+
+// lintMain parses and lints zone files without talking to any nameservers.
+// Returns a non-zero exit code if any zone can't be parsed or has errors.
+func lintMain(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s lint [options] [zone files]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "lint checks BIND-style zone files for common mistakes without querying\n")
+		fmt.Fprintf(flags.Output(), "any nameservers.\n\n")
+		fmt.Fprintf(flags.Output(), "available options:\n")
+		flags.PrintDefaults()
+	}
+	origin := flags.String("origin", "", "the `zone` name. defaults to the owner of the SOA record in each file")
+	flags.Parse(args)
+
+	exitCode := 0
+	for _, filename := range flags.Args() {
+		z, err := loadZone(filename, *origin)
+		if err != nil {
+			log.Printf("error: %s", err)
+			exitCode = 1
+			continue
+		}
+
+		result := &okaydns.CheckResult{
+			Name:     "Lint " + filename,
+			Failures: zone.Lint(z),
+		}
+		if !result.Success() {
+			exitCode = 1
+		}
+
+		bs, err := formatter.FormatCheck(result)
+		if err != nil {
+			panic(err)
+		}
+		log.Print(string(bs))
+	}
+
+	return exitCode
+}
+
+// loadZone parses the zone file at filename.
+func loadZone(filename, origin string) (*zone.Zone, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return zone.Parse(f, origin, filename)
+}
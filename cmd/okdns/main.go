@@ -10,29 +10,46 @@ import (
 	"strings"
 
 	"github.com/blinsay/okaydns"
+	okfingerprint "github.com/blinsay/okaydns/fingerprint"
+	okfuzz "github.com/blinsay/okaydns/fuzz"
+	"github.com/blinsay/okaydns/policy"
+	"github.com/blinsay/okaydns/stdchecks"
 	"github.com/fatih/color"
 	"github.com/miekg/dns"
 	"github.com/pkg/errors"
 )
 
 var (
-	verbose    = false
-	outputJSON = false
+	verbose     = false
+	outputJSON  = false
+	fingerprint = false
+	fuzz        = false
 )
 
-type nameserverList []string
+type stringList []string
 
-func (n *nameserverList) String() string     { return strings.Join(*n, ",") }
-func (n *nameserverList) Set(v string) error { *n = append(*n, v); return nil }
+func (s *stringList) String() string     { return strings.Join(*s, ",") }
+func (s *stringList) Set(v string) error { *s = append(*s, v); return nil }
 
 var (
 	filterPattern = ""
 	filterRe      *regexp.Regexp
 
-	targetNameservers nameserverList
+	checkTags         stringList
+	targetNameservers stringList
+	emptyNonTerminals stringList
+	fingerprintFiles  stringList
+	checkFiles        stringList
+	policyFiles       stringList
+
+	fingerprints  *okfingerprint.Database
+	definedChecks []stdchecks.Entry
+	policies      []*policy.Policy
 
 	text = textFormatter{
 		ok:      color.New(color.FgGreen).SprintFunc(),
+		warning: color.New(color.FgYellow).SprintFunc(),
+		skipped: color.New(color.FgCyan).SprintFunc(),
 		failure: color.New(color.FgRed).SprintFunc(),
 	}
 
@@ -45,19 +62,31 @@ func init() {
 
 	// cli flags
 	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [output flags] [domains]\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [output flags] [domains]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s [output flags] lint [options] [zone files]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s [output flags] drift [options] [zone file]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s [output flags] preflight [options] [zone file]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s [output flags] checks\n\n", os.Args[0])
 		fmt.Fprintf(flag.CommandLine.Output(), "okdns is a tool for checking to see if your dns is ok. checks are run\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "against every domain listed. unless otherwise specified with the -ns\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "option, the local resolver is queried for the authoritative nameservers\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "for the domains specified, and checks are run against those.\n\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "run '%s <command> -h' for help with a command.\n\n", os.Args[0])
 		fmt.Fprintf(flag.CommandLine.Output(), "available options:\n")
 		flag.PrintDefaults()
 	}
 
 	flag.BoolVar(&outputJSON, "json", false, "output check results as JSON")
 	flag.BoolVar(&verbose, "verbose", false, "include verbose check output")
-	flag.StringVar(&filterPattern, "check", "", "only run checks that match the given `pattern`")
+	flag.StringVar(&filterPattern, "check", "", "only run checks with an ID or name that matches the given `pattern`")
+	flag.Var(&checkTags, "tag", "only run checks with the given `tag`. may be specified multiple times.")
 	flag.Var(&targetNameservers, "ns", "a `nameserver` to check explicitly. may be specified multiple times.")
+	flag.BoolVar(&fingerprint, "fingerprint", false, "identify the software running on every nameserver")
+	flag.BoolVar(&fuzz, "fuzz", false, "send malformed packets to every nameserver. only use this on nameservers you operate.")
+	flag.Var(&fingerprintFiles, "fingerprints", "a fingerprint database `file` to use in addition to the built-in probes. may be specified multiple times.")
+	flag.Var(&checkFiles, "checkfile", "a check definition `file` with checks to run in addition to the standard checks. may be specified multiple times.")
+	flag.Var(&policyFiles, "policy", "a policy `file` describing the expected state of a domain. may be specified multiple times.")
+	flag.Var(&emptyNonTerminals, "ent", "a `name`, relative to each domain, that is an empty non-terminal. may be specified multiple times.")
 	flag.Parse()
 
 	if filterPattern != "" {
@@ -68,32 +97,66 @@ func init() {
 		filterRe = re
 	}
 
+	fingerprints = okfingerprint.Default()
+	for _, filename := range fingerprintFiles {
+		if err := loadFingerprints(fingerprints, filename); err != nil {
+			log.Fatalf("error: loading fingerprints from %s: %s", filename, err)
+		}
+	}
+
+	for _, filename := range checkFiles {
+		entries, err := loadCheckDefinitions(filename)
+		if err != nil {
+			log.Fatalf("error: loading checks from %s: %s", filename, err)
+		}
+		definedChecks = append(definedChecks, entries...)
+	}
+
+	policyNames := make(map[string]string)
+	for _, filename := range policyFiles {
+		p, err := loadPolicy(filename)
+		if err != nil {
+			log.Fatalf("error: loading policy from %s: %s", filename, err)
+		}
+		if other, ok := policyNames[p.Name]; ok {
+			log.Fatalf("error: policies in %s and %s are both named %q", other, filename, p.Name)
+		}
+		policyNames[p.Name] = filename
+		policies = append(policies, p)
+	}
+
 	if outputJSON {
 		formatter = &jsonFormatter{}
 	}
 	formatter.SetVerbose(verbose)
 }
 
-// TODO(benl): enable/disable checks with a flag. only run checks that match a pattern?
 // TODO(benl): search parent domains if there are no nameservers found for a target
 // TODO(benl): optionally configure the local resolver from the CLI
 // TODO(benl): include IPv6 support
 
+// commands are subcommands of okdns. each command gets the arguments after its
+// name and returns an exit code.
+var commands = map[string]func(args []string) int{
+	"lint":      lintMain,
+	"drift":     driftMain,
+	"preflight": preflightMain,
+	"checks":    checksMain,
+}
+
 func main() {
+	if command, ok := commands[flag.Arg(0)]; ok {
+		os.Exit(command(flag.Args()[1:]))
+	}
+
 	seedns, err := configuredNameserver("/etc/resolv.conf")
 	if err != nil {
 		log.Fatalln("error loading local nameserver info from /etc/resolv.conf:", err)
 	}
 
-	var checks []okaydns.Check
-	for _, check := range defaultChecks {
-		if filterRe == nil || filterRe.MatchString(check.Name) {
-			checks = append(checks, check)
-		}
-	}
-
 	for _, domain := range flag.Args() {
 		fqdn := dns.Fqdn(domain)
+		checks := domainChecks(fqdn)
 
 		nameservers, err := findNameservers(seedns, fqdn, targetNameservers)
 		if err != nil {
@@ -108,9 +171,12 @@ func main() {
 			log.Print(string(bs))
 		}
 
-		var results []*okaydns.CheckResult
-		for _, check := range checks {
-			results = append(results, okaydns.DoCheck(&check, fqdn, nameservers))
+		results := okaydns.DoChecks(checks, fqdn, nameservers)
+		if fingerprint {
+			results = append(results, fingerprintResult(fqdn, nameservers))
+		}
+		if fuzz {
+			results = append(results, new(okfuzz.Runner).Run(fqdn, nameservers)...)
 		}
 
 		for _, result := range results {
@@ -123,6 +189,52 @@ func main() {
 	}
 }
 
+// selectedChecks returns the standard checks and any checks configured with
+// flags or definition files, selected by the -tag and -check flags. The
+// prerequisites of every selected check are always selected.
+func selectedChecks() []okaydns.Check {
+	registry := stdchecks.Standard()
+	for _, name := range emptyNonTerminals {
+		err := registry.Register(stdchecks.Entry{
+			Check:       stdchecks.EmptyNonTerminal(strings.Trim(name, ".")),
+			Description: "the empty non-terminal gets an authoritative NODATA",
+			Tags:        []string{stdchecks.TagNegative},
+			Default:     true,
+		})
+		if err != nil {
+			log.Fatalf("error: %s", err)
+		}
+	}
+
+	for _, entry := range definedChecks {
+		if err := registry.Register(entry); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+	}
+
+	return registry.Select(stdchecks.Selector{
+		Tags:    checkTags,
+		Pattern: filterRe,
+	})
+}
+
+// domainChecks returns the selected checks and the checks for every policy
+// that applies to fqdn.
+func domainChecks(fqdn string) []okaydns.Check {
+	checks := selectedChecks()
+	for _, p := range policies {
+		if !p.Applies(fqdn) {
+			continue
+		}
+		policyChecks, err := p.Checks(fqdn)
+		if err != nil {
+			log.Fatalf("error: %s", err)
+		}
+		checks = append(checks, policyChecks...)
+	}
+	return checks
+}
+
 func findNameservers(seedns okaydns.Nameserver, fqdn string, configured []string) ([]okaydns.Nameserver, error) {
 	if len(configured) > 0 {
 		return explicitNameservers(seedns, configured)
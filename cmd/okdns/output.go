@@ -19,7 +19,9 @@ type checkFormatter interface {
 type textFormatter struct {
 	verbose bool
 	ok      func(...interface{}) string
+	warning func(...interface{}) string
 	failure func(...interface{}) string
+	skipped func(...interface{}) string
 }
 
 func (t *textFormatter) SetVerbose(v bool) {
@@ -41,33 +43,93 @@ func (t *textFormatter) FormatHeader(fqdn string, checks []okaydns.Check, namese
 func (t *textFormatter) FormatCheck(cr *okaydns.CheckResult) ([]byte, error) {
 	var bs bytes.Buffer
 
-	status := t.ok("ok")
-	if !cr.Success() {
-		status = t.failure("failed")
+	fmt.Fprintf(&bs, "%-40s %s\n", cr.Name+":", t.status(cr))
+	if cr.Skipped {
+		fmt.Fprintf(&bs, "\tskipped: %s\n", cr.SkipReason)
 	}
-	fmt.Fprintf(&bs, "%-40s %s\n", cr.Name+":", status)
+	for ns, reason := range cr.SkippedNameservers {
+		fmt.Fprintf(&bs, "\tskipped: %s (%s): %s\n", ns.Hostname, ns.String(), reason)
+	}
+	t.writeProblems(&bs, "\t", cr)
+
+	for _, step := range cr.Steps {
+		for _, result := range step.Results {
+			fmt.Fprintf(&bs, "\t%-32s %s\n", result.Name+":", t.status(result))
+			t.writeProblems(&bs, "\t\t", result)
+		}
+	}
+
+	for _, observation := range cr.Observations {
+		if observation.Nameserver.IsZero() {
+			fmt.Fprintf(&bs, "\t%s: %s\n", observation.Name, observation.Value)
+		} else {
+			fmt.Fprintf(&bs, "\t%s (%s): %s: %s\n", observation.Nameserver.Hostname, observation.Nameserver.String(), observation.Name, observation.Value)
+		}
+	}
+
+	if t.verbose {
+		t.writeExchanges(&bs, cr)
+		for _, step := range cr.Steps {
+			for _, result := range step.Results {
+				t.writeExchanges(&bs, result)
+			}
+		}
+	}
+
+	return bs.Bytes(), nil
+}
 
+func (t *textFormatter) status(cr *okaydns.CheckResult) string {
+	status := cr.Status()
+	switch status {
+	case okaydns.StatusFailed:
+		return t.failure(status)
+	case okaydns.StatusWarning:
+		return t.warning(status)
+	case okaydns.StatusSkipped:
+		return t.skipped(status)
+	default:
+		return t.ok(status)
+	}
+}
+
+// writeProblems writes the failures and errors of a single CheckResult, but not
+// any of its steps.
+func (t *textFormatter) writeProblems(bs *bytes.Buffer, indent string, cr *okaydns.CheckResult) {
 	for _, failure := range cr.Failures {
+		message := failure.Message
+		if failure.Code != "" {
+			message = fmt.Sprintf("%s [%s]", message, failure.Code)
+		}
 		if failure.Nameserver.IsZero() {
-			fmt.Fprintf(&bs, "\t%s\n", failure.Message)
+			fmt.Fprintf(bs, "%s%s: %s\n", indent, failure.Severity, message)
 		} else {
-			fmt.Fprintf(&bs, "\t%s (%s): %s\n", failure.Nameserver.Hostname, failure.Nameserver.String(), failure.Message)
+			fmt.Fprintf(bs, "%s%s: %s (%s): %s\n", indent, failure.Severity, failure.Nameserver.Hostname, failure.Nameserver.String(), message)
+		}
+		if t.verbose && failure.Reference != "" {
+			fmt.Fprintf(bs, "%s\tsee %s\n", indent, failure.Reference)
 		}
 	}
 
 	for ns, err := range cr.Errors {
-		fmt.Fprintf(&bs, "\terror: %s (%s): %s\n", ns.Hostname, ns.String(), err)
+		fmt.Fprintf(bs, "%serror: %s (%s): %s [%s]\n", indent, ns.Hostname, ns.String(), err, okaydns.KindOf(err))
 	}
+}
 
-	if t.verbose {
-		fmt.Fprintf(&bs, "<<>> Request <<>>\n%s\n", cr.Question)
+func (t *textFormatter) writeExchanges(bs *bytes.Buffer, cr *okaydns.CheckResult) {
+	for nameserver, question := range cr.Questions {
+		fmt.Fprintf(bs, "<<>> Request to (%s) %s <<>>\n%s\n", nameserver.Hostname, nameserver.String(), question)
 
-		for nameserver, response := range cr.Answers {
-			fmt.Fprintf(&bs, "<<>> Response from (%s) %s <<>>\n%s\n", nameserver.Hostname, nameserver.String(), response)
+		response, ok := cr.Answers[nameserver]
+		if !ok {
+			continue
 		}
+		fmt.Fprintf(bs, "<<>> Response from (%s) %s <<>>\n", nameserver.Hostname, nameserver.String())
+		if exchange, ok := cr.Exchanges[nameserver]; ok {
+			fmt.Fprintf(bs, ";; %d bytes in %s after %d attempts\n", exchange.Size, exchange.RTT, exchange.Attempts)
+		}
+		fmt.Fprintf(bs, "%s\n", response)
 	}
-
-	return bs.Bytes(), nil
 }
 
 // json output
@@ -87,8 +149,23 @@ func (j *jsonFormatter) FormatHeader(_ string, _ []okaydns.Check, _ []okaydns.Na
 }
 
 func (j *jsonFormatter) FormatCheck(cr *okaydns.CheckResult) ([]byte, error) {
+	return json.Marshal(j.output(cr))
+}
+
+func (j *jsonFormatter) output(cr *okaydns.CheckResult) *jsonOutput {
 	output := jsonOutput{
-		Name: cr.Name,
+		Name:       cr.Name,
+		Success:    cr.Success(),
+		Status:     cr.Status(),
+		SkipReason: cr.SkipReason,
+	}
+
+	// skipped nameservers
+	if len(cr.SkippedNameservers) > 0 {
+		output.SkippedNameservers = make(map[string]string, len(cr.SkippedNameservers))
+		for ns, reason := range cr.SkippedNameservers {
+			output.SkippedNameservers[ns.String()] = reason
+		}
 	}
 
 	// nameservers
@@ -101,24 +178,44 @@ func (j *jsonFormatter) FormatCheck(cr *okaydns.CheckResult) ([]byte, error) {
 	}
 
 	// errors
-	output.Errors = make(map[string]error)
+	output.Errors = make(map[string]errorInfo)
 	for ns, err := range cr.Errors {
-		output.Errors[ns.String()] = err
+		output.Errors[ns.String()] = errorInfo{
+			Kind:    okaydns.KindOf(err),
+			Message: err.Error(),
+		}
 	}
 
 	// ns failures
 	output.Failures = make([]failureInfo, len(cr.Failures))
 	for i, failure := range cr.Failures {
 		output.Failures[i].Message = failure.Message
+		output.Failures[i].Severity = failure.Severity
+		output.Failures[i].Code = failure.Code
+		output.Failures[i].Reference = failure.Reference
 		if failure.Nameserver.Hostname != "" || failure.Nameserver.IP != "" {
 			nsString := failure.Nameserver.String()
 			output.Failures[i].Nameserver = &nsString
 		}
 	}
 
+	// observations
+	output.Observations = make([]observationInfo, len(cr.Observations))
+	for i, observation := range cr.Observations {
+		output.Observations[i].Name = observation.Name
+		output.Observations[i].Value = observation.Value
+		if !observation.Nameserver.IsZero() {
+			nsString := observation.Nameserver.String()
+			output.Observations[i].Nameserver = &nsString
+		}
+	}
+
 	if j.verbose {
-		// question
-		output.Question = cr.Question.String()
+		// questions
+		output.Questions = make(map[string]string, len(cr.Questions))
+		for ns, question := range cr.Questions {
+			output.Questions[ns.String()] = question.String()
+		}
 
 		// answers
 		output.Answers = make(map[string]string, len(cr.Answers))
@@ -127,16 +224,36 @@ func (j *jsonFormatter) FormatCheck(cr *okaydns.CheckResult) ([]byte, error) {
 		}
 	}
 
-	return json.Marshal(&output)
+	// steps
+	for _, step := range cr.Steps {
+		info := stepInfo{Name: step.Name}
+		for _, result := range step.Results {
+			info.Results = append(info.Results, j.output(result))
+		}
+		output.Steps = append(output.Steps, info)
+	}
+
+	return &output
 }
 
 type jsonOutput struct {
-	Name        string            `json:"name"`
-	Nameservers []nameserverInfo  `json:"nameservers"`
-	Question    string            `json:"question,omitempty"`
-	Answers     map[string]string `json:"answers,omitempty"`
-	Errors      map[string]error  `json:"errors,omitempty"`
-	Failures    []failureInfo     `json:"check_failures,omitempty"`
+	Name               string               `json:"name"`
+	Success            bool                 `json:"success"`
+	Status             okaydns.Status       `json:"status"`
+	SkipReason         string               `json:"skip_reason,omitempty"`
+	SkippedNameservers map[string]string    `json:"skipped_nameservers,omitempty"`
+	Nameservers        []nameserverInfo     `json:"nameservers"`
+	Questions          map[string]string    `json:"questions,omitempty"`
+	Answers            map[string]string    `json:"answers,omitempty"`
+	Errors             map[string]errorInfo `json:"errors,omitempty"`
+	Failures           []failureInfo        `json:"check_failures,omitempty"`
+	Observations       []observationInfo    `json:"observations,omitempty"`
+	Steps              []stepInfo           `json:"steps,omitempty"`
+}
+
+type stepInfo struct {
+	Name    string        `json:"name"`
+	Results []*jsonOutput `json:"results"`
 }
 
 type nameserverInfo struct {
@@ -144,7 +261,21 @@ type nameserverInfo struct {
 	Address string `json:"address"`
 }
 
+type errorInfo struct {
+	Kind    okaydns.ErrorKind `json:"kind"`
+	Message string            `json:"message"`
+}
+
 type failureInfo struct {
+	Nameserver *string          `json:"nameserver,omitempty"`
+	Severity   okaydns.Severity `json:"severity"`
+	Code       string           `json:"code,omitempty"`
+	Reference  string           `json:"reference,omitempty"`
+	Message    string           `json:"message"`
+}
+
+type observationInfo struct {
 	Nameserver *string `json:"nameserver,omitempty"`
-	Message    string  `json:"message"`
+	Name       string  `json:"name"`
+	Value      string  `json:"value"`
 }
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/okaytest"
+	"github.com/blinsay/okaydns/zone"
+	"github.com/miekg/dns"
+)
+
+// the types checked for removal at every name in a proposed zone.
+var preflightRemovalTypes = []uint16{
+	dns.TypeA,
+	dns.TypeAAAA,
+	dns.TypeCNAME,
+	dns.TypeMX,
+	dns.TypeTXT,
+	dns.TypeNS,
+	dns.TypeSRV,
+	dns.TypeCAA,
+}
+
+// preflightMain serves a proposed zone file on loopback, runs every check
+// against it, and summarizes how the proposed zone differs from what the live
+// nameservers are serving. Returns a non-zero exit code if any check fails
+// against the proposed zone. Differences from the live nameservers are
+// expected, and are only reported.
+func preflightMain(args []string) int {
+	flags := flag.NewFlagSet("preflight", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s [output flags] preflight [options] [zone file]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "preflight serves a proposed zone file from a local nameserver, runs every\n")
+		fmt.Fprintf(flags.Output(), "check against it, and then summarizes which names would change compared to\n")
+		fmt.Fprintf(flags.Output(), "the zone's live nameservers.\n\n")
+		fmt.Fprintf(flags.Output(), "available options:\n")
+		flags.PrintDefaults()
+	}
+	origin := flags.String("origin", "", "the `zone` name. defaults to the owner of the SOA record in the file")
+	current := flags.String("current", "", "the current zone `file`. names in the current zone that aren't in the proposed zone are also checked for removal")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	zoneText, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		log.Fatalln("error:", err)
+	}
+	proposed, err := zone.ParseString(string(zoneText), *origin)
+	if err != nil {
+		log.Fatalf("error: %s: %s", flags.Arg(0), err)
+	}
+
+	names := proposed.Names()
+	if *current != "" {
+		currentZone, err := loadZone(*current, proposed.Origin)
+		if err != nil {
+			log.Fatalln("error:", err)
+		}
+		proposedNames := make(map[string]bool, len(names))
+		for _, name := range names {
+			proposedNames[strings.ToLower(name)] = true
+		}
+		for _, name := range currentZone.Names() {
+			if !proposedNames[strings.ToLower(name)] {
+				names = append(names, name)
+			}
+		}
+	}
+
+	server, err := okaytest.NewServer(string(zoneText), proposed.Origin)
+	if err != nil {
+		log.Fatalln("error: starting local nameserver:", err)
+	}
+	defer server.Close()
+
+	exitCode := 0
+
+	// run every check and policy against the proposed zone
+	checks := domainChecks(proposed.Origin)
+	local := server.Nameservers()
+	if err := printHeader(proposed.Origin, checks, local); err != nil {
+		panic(err)
+	}
+	for _, result := range okaydns.DoChecks(checks, proposed.Origin, local) {
+		if !result.Success() {
+			exitCode = 1
+		}
+		if err := printResult(result); err != nil {
+			panic(err)
+		}
+	}
+
+	// diff the proposed zone against the live nameservers
+	seedns, err := configuredNameserver("/etc/resolv.conf")
+	if err != nil {
+		log.Fatalln("error loading local nameserver info from /etc/resolv.conf:", err)
+	}
+	live, err := findNameservers(seedns, proposed.Origin, targetNameservers)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	summary := &okaydns.CheckResult{
+		Name:        "Changes from live nameservers",
+		Nameservers: live,
+	}
+	for _, check := range zone.DriftChecks(proposed) {
+		result := okaydns.DoCheck(&check, proposed.Origin, live)
+		summary.Observations = append(summary.Observations, changes(result, "added", "changed")...)
+	}
+	for _, check := range zone.AbsenceChecks(proposed, names, preflightRemovalTypes) {
+		result := okaydns.DoCheck(&check, proposed.Origin, live)
+		summary.Observations = append(summary.Observations, changes(result, "", "removed")...)
+	}
+	if err := printResult(summary); err != nil {
+		panic(err)
+	}
+
+	return exitCode
+}
+
+// changes summarizes a failed drift or absence check as an observation. A
+// check where none of the nameservers returned any records for the RRset is
+// summarized as missing, otherwise it's summarized as changed. Errors talking
+// to the live nameservers are reported as observations too.
+func changes(result *okaydns.CheckResult, missing, changed string) []okaydns.Observation {
+	var observations []okaydns.Observation
+	for nameserver, err := range result.Errors {
+		observations = append(observations, okaydns.Observation{
+			Name:       result.Name,
+			Value:      fmt.Sprintf("error: %s", err),
+			Nameserver: nameserver,
+		})
+	}
+	if len(result.Failures) == 0 {
+		return observations
+	}
+
+	value := changed
+	if missing != "" && !answered(result) {
+		value = missing
+	}
+	return append(observations, okaydns.Observation{Name: result.Name, Value: value})
+}
+
+// answered returns true if any nameserver answered the question in a
+// CheckResult with records of the type it asked for.
+func answered(result *okaydns.CheckResult) bool {
+	for nameserver, answer := range result.Answers {
+		q := result.Questions[nameserver].Question[0]
+		for _, rr := range answer.Answer {
+			if hdr := rr.Header(); hdr.Rrtype == q.Qtype && strings.EqualFold(hdr.Name, q.Name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func printHeader(fqdn string, checks []okaydns.Check, nameservers []okaydns.Nameserver) error {
+	bs, err := formatter.FormatHeader(fqdn, checks, nameservers)
+	if err != nil {
+		return err
+	}
+	if bs != nil {
+		log.Print(string(bs))
+	}
+	return nil
+}
+
+func printResult(result *okaydns.CheckResult) error {
+	bs, err := formatter.FormatCheck(result)
+	if err != nil {
+		return err
+	}
+	log.Print(string(bs))
+	return nil
+}
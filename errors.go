@@ -0,0 +1,175 @@
+package okaydns
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	stderrors "errors"
+	"net"
+	"syscall"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// An ErrorKind classifies why an exchange with a nameserver failed.
+type ErrorKind string
+
+// Kinds of exchange errors.
+const (
+	// ErrorTimeout means the nameserver didn't reply in time.
+	ErrorTimeout ErrorKind = "timeout"
+
+	// ErrorConnectionRefused means the nameserver actively refused a
+	// connection, or a UDP query was answered with an ICMP port unreachable.
+	ErrorConnectionRefused ErrorKind = "connection-refused"
+
+	// ErrorNetworkUnreachable means there was no route to the nameserver.
+	ErrorNetworkUnreachable ErrorKind = "network-unreachable"
+
+	// ErrorTLS means a TLS handshake or certificate validation failed.
+	ErrorTLS ErrorKind = "tls-failure"
+
+	// ErrorTruncated means the nameserver sent a truncated reply that couldn't
+	// be used.
+	ErrorTruncated ErrorKind = "truncated"
+
+	// ErrorMalformedReply means the nameserver sent a reply that couldn't be
+	// parsed.
+	ErrorMalformedReply ErrorKind = "malformed-reply"
+
+	// ErrorIDMismatch means the nameserver replied with a message ID that
+	// didn't match the query.
+	ErrorIDMismatch ErrorKind = "id-mismatch"
+
+	// ErrorOther is any error that doesn't fit another kind.
+	ErrorOther ErrorKind = "other"
+)
+
+// An ExchangeError is a classified error from an exchange with a nameserver.
+// ExchangeErrors keep the underlying error, and their message is the message
+// of the underlying error.
+//
+// Use KindOf to check what kind of error a CheckResult error is, or match an
+// ExchangeError with no underlying error using errors.Is:
+//
+//	errors.Is(err, &okaydns.ExchangeError{Kind: okaydns.ErrorTimeout})
+type ExchangeError struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *ExchangeError) Error() string {
+	if e.Err == nil {
+		return string(e.Kind)
+	}
+	return e.Err.Error()
+}
+
+// Cause returns the underlying error.
+func (e *ExchangeError) Cause() error { return e.Err }
+
+// Unwrap returns the underlying error.
+func (e *ExchangeError) Unwrap() error { return e.Err }
+
+// Is matches an ExchangeError of the same kind.
+func (e *ExchangeError) Is(target error) bool {
+	t, ok := target.(*ExchangeError)
+	return ok && t.Kind == e.Kind
+}
+
+// Timeout returns true if the error is an ErrorTimeout, so that an
+// ExchangeError can be checked like a net.Error.
+func (e *ExchangeError) Timeout() bool { return e.Kind == ErrorTimeout }
+
+// MarshalJSON encodes an ExchangeError as an object with its kind and message.
+func (e *ExchangeError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Kind    ErrorKind `json:"kind"`
+		Message string    `json:"message"`
+	}{e.Kind, e.Error()})
+}
+
+// KindOf returns the kind of an error returned from an exchange with a
+// nameserver. Errors that haven't been classified with ClassifyError are
+// classified before their kind is returned.
+func KindOf(err error) ErrorKind {
+	if err == nil {
+		return ""
+	}
+	return ClassifyError(err).(*ExchangeError).Kind
+}
+
+// ClassifyError wraps an error from an exchange with a nameserver in an
+// ExchangeError. Errors that are already ExchangeErrors are returned as-is and
+// nil errors are returned as nil.
+func ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var exchangeErr *ExchangeError
+	if stderrors.As(err, &exchangeErr) {
+		return exchangeErr
+	}
+
+	return &ExchangeError{Kind: classify(err), Err: err}
+}
+
+func classify(err error) ErrorKind {
+	cause := errors.Cause(err)
+
+	// miekg/dns errors are comparable values
+	switch cause {
+	case dns.ErrId:
+		return ErrorIDMismatch
+	case dns.ErrTruncated:
+		return ErrorTruncated
+	}
+	if _, ok := cause.(*dns.Error); ok {
+		return ErrorMalformedReply
+	}
+
+	var netErr net.Error
+	if stderrors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorTimeout
+	}
+
+	switch {
+	case stderrors.Is(err, syscall.ECONNREFUSED):
+		return ErrorConnectionRefused
+	case stderrors.Is(err, syscall.ENETUNREACH), stderrors.Is(err, syscall.EHOSTUNREACH):
+		return ErrorNetworkUnreachable
+	}
+
+	if isTLSError(cause) {
+		return ErrorTLS
+	}
+
+	return ErrorOther
+}
+
+// isTLSError returns true if err is a TLS handshake or certificate error. The
+// error may be wrapped in a *net.OpError. Alerts sent by the other end of a
+// connection are returned by crypto/tls as an unexported type wrapped in a
+// *net.OpError with the Op "remote error".
+func isTLSError(err error) bool {
+	var (
+		recordErr tls.RecordHeaderError
+		alertErr  tls.AlertError
+		certErr   x509.CertificateInvalidError
+		hostErr   x509.HostnameError
+		authErr   x509.UnknownAuthorityError
+		rootsErr  x509.SystemRootsError
+		opErr     *net.OpError
+	)
+	switch {
+	case stderrors.As(err, &recordErr), stderrors.As(err, &alertErr):
+		return true
+	case stderrors.As(err, &certErr), stderrors.As(err, &hostErr), stderrors.As(err, &authErr), stderrors.As(err, &rootsErr):
+		return true
+	case stderrors.As(err, &opErr) && opErr.Op == "remote error":
+		return true
+	}
+	return false
+}
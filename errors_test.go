@@ -0,0 +1,94 @@
+package okaydns
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	stderrors "errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyError(t *testing.T) {
+	opError := func(errno syscall.Errno) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", errno)}
+	}
+
+	tcs := []struct {
+		err  error
+		kind ErrorKind
+	}{
+		{&net.OpError{Op: "read", Net: "udp", Err: timeoutError{}}, ErrorTimeout},
+		{opError(syscall.ECONNREFUSED), ErrorConnectionRefused},
+		{opError(syscall.ENETUNREACH), ErrorNetworkUnreachable},
+		{opError(syscall.EHOSTUNREACH), ErrorNetworkUnreachable},
+		{tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}, ErrorTLS},
+		{&net.OpError{Op: "remote error", Net: "tcp", Err: tls.AlertError(40)}, ErrorTLS},
+		{&net.OpError{Op: "read", Net: "tcp", Err: x509.HostnameError{Certificate: &x509.Certificate{}, Host: "ns1.example.com"}}, ErrorTLS},
+		{errors.Wrap(x509.UnknownAuthorityError{}, "exchange"), ErrorTLS},
+		{stderrors.New("tls: looks like a tls error"), ErrorOther},
+		{dns.ErrTruncated, ErrorTruncated},
+		{dns.ErrShortRead, ErrorMalformedReply},
+		{errors.Wrap(dns.ErrId, "exchange"), ErrorIDMismatch},
+		{stderrors.New("something else"), ErrorOther},
+	}
+
+	for _, tc := range tcs {
+		err := ClassifyError(tc.err)
+		assert.Equal(t, tc.kind, KindOf(err), "%s", tc.err)
+		assert.Equal(t, tc.err.Error(), err.Error())
+		assert.True(t, stderrors.Is(err, &ExchangeError{Kind: tc.kind}), "%s", tc.err)
+
+		// classifying twice doesn't wrap twice
+		assert.Equal(t, err, ClassifyError(err))
+	}
+
+	assert.Nil(t, ClassifyError(nil))
+	assert.True(t, ClassifyError(&net.OpError{Op: "read", Err: timeoutError{}}).(interface{ Timeout() bool }).Timeout())
+}
+
+func TestClassifyHandshakeError(t *testing.T) {
+	// a server with a certificate the client doesn't trust, and a server that
+	// doesn't speak TLS at all.
+	quiet := log.New(ioutil.Discard, "", 0)
+	untrusted := httptest.NewUnstartedServer(nil)
+	untrusted.Config.ErrorLog = quiet
+	untrusted.StartTLS()
+	defer untrusted.Close()
+	plaintext := httptest.NewUnstartedServer(nil)
+	plaintext.Config.ErrorLog = quiet
+	plaintext.Start()
+	defer plaintext.Close()
+
+	for _, server := range []*httptest.Server{untrusted, plaintext} {
+		conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{ServerName: "ns1.example.com"})
+		if assert.Error(t, err, server.URL) {
+			assert.Equal(t, ErrorTLS, KindOf(err), "%s: %s", server.URL, err)
+		} else {
+			conn.Close()
+		}
+	}
+}
+
+func TestExchangeErrorJSON(t *testing.T) {
+	err := ClassifyError(dns.ErrId)
+
+	bs, jsonErr := json.Marshal(map[string]error{"ns1": err})
+	assert.NoError(t, jsonErr)
+	assert.JSONEq(t, `{"ns1": {"kind": "id-mismatch", "message": "dns: id mismatch"}}`, string(bs))
+}
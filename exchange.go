@@ -0,0 +1,166 @@
+package okaydns
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// ExchangeRaw sends an arbitrary wire-format message to a nameserver and
+// returns the raw bytes of the first message it sends back. The message isn't
+// validated before it's sent and the reply isn't parsed, so ExchangeRaw can be
+// used to send deliberately malformed packets.
+//
+// The timeout applies to the whole exchange. Over TCP, the message is sent
+// with a length prefix that matches its actual length.
+func ExchangeRaw(nameserver Nameserver, wire []byte, timeout time.Duration) ([]byte, error) {
+	conn, err := dns.DialTimeout(nameserver.Proto.String(), nameserver.Address(), timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	// write to the underlying conn so that messages shorter than a length prefix
+	// can still be sent over TCP.
+	out := wire
+	if nameserver.Proto != ProtoUDP {
+		out = make([]byte, 2, len(wire)+2)
+		binary.BigEndian.PutUint16(out, uint16(len(wire)))
+		out = append(out, wire...)
+	}
+	if _, err := conn.Conn.Write(out); err != nil {
+		return nil, errors.Wrap(err, "write failed")
+	}
+
+	buf := make([]byte, dns.MaxMsgSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// exchangeTimeout is the dial, read, and write timeout for a single attempt at
+// an exchange. It matches the miekg/dns Client default.
+const exchangeTimeout = 2 * time.Second
+
+// An Exchange is the record of sending a query to a single nameserver. Every
+// Exchange has either a Reply or an Err.
+type Exchange struct {
+	// Nameserver is the nameserver the query was sent to.
+	Nameserver Nameserver
+
+	// Query is the message sent to the nameserver.
+	Query *dns.Msg
+
+	// Reply is the parsed reply, if the nameserver sent one that could be
+	// parsed. Replies with TC set are still replies, unless they were cut off
+	// in the middle of a record.
+	Reply *dns.Msg
+
+	// Err is the classified error from the last attempt, if no attempt got a
+	// reply. See ClassifyError.
+	Err error
+
+	// RTT is the round trip time of the last attempt.
+	RTT time.Duration
+
+	// Size is the size of the reply on the wire in bytes, not including the
+	// length prefix used over TCP.
+	Size int
+
+	// Proto is the transport the query was sent over.
+	Proto Proto
+
+	// Attempts is the number of times the query was sent.
+	Attempts int
+}
+
+// ExchangeMsg sends a query to a nameserver and records the exchange. Queries
+// that time out are retried until they've been sent up to attempts times. Any
+// other error is not retried.
+func ExchangeMsg(nameserver Nameserver, query *dns.Msg, attempts int) *Exchange {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	e := &Exchange{Nameserver: nameserver, Query: query, Proto: nameserver.Proto}
+	for e.Attempts < attempts {
+		e.Attempts++
+		e.Reply, e.Size, e.RTT, e.Err = exchangeOnce(nameserver, query)
+		if e.Err == nil || KindOf(e.Err) != ErrorTimeout {
+			break
+		}
+	}
+	return e
+}
+
+// exchangeOnce works like dns.Client.Exchange, but also returns the size of the
+// reply on the wire.
+func exchangeOnce(nameserver Nameserver, query *dns.Msg) (*dns.Msg, int, time.Duration, error) {
+	client := dns.Client{
+		Net:     nameserver.Proto.String(),
+		Timeout: exchangeTimeout,
+	}
+	conn, err := client.Dial(nameserver.Address())
+	if err != nil {
+		return nil, 0, 0, ClassifyError(err)
+	}
+	defer conn.Close()
+
+	if opt := query.IsEdns0(); opt != nil && opt.UDPSize() >= dns.MinMsgSize {
+		conn.UDPSize = opt.UDPSize()
+	}
+
+	start := time.Now()
+	if err := conn.SetDeadline(start.Add(exchangeTimeout)); err != nil {
+		return nil, 0, 0, ClassifyError(err)
+	}
+	if err := conn.WriteMsg(query); err != nil {
+		return nil, 0, 0, ClassifyError(err)
+	}
+	wire, err := conn.ReadMsgHeader(nil)
+	rtt := time.Since(start)
+	if err != nil {
+		return nil, 0, rtt, ClassifyError(err)
+	}
+
+	reply, err := unpackReply(wire)
+	if err != nil {
+		return nil, len(wire), rtt, ClassifyError(err)
+	}
+	if reply.Id != query.Id {
+		return nil, len(wire), rtt, ClassifyError(dns.ErrId)
+	}
+	return reply, len(wire), rtt, nil
+}
+
+// unpackReply parses a reply. A reply with TC set is still a reply as long as
+// every record in it can be parsed: servers that answer ANY with a minimal
+// response set TC to ask for a retry over TCP (RFC 8482), and checks need to
+// see those replies. A reply with TC set that was cut off in the middle of a
+// record is dns.ErrTruncated.
+func unpackReply(wire []byte) (*dns.Msg, error) {
+	reply := new(dns.Msg)
+	err := reply.Unpack(wire)
+	if err == nil || !reply.Truncated {
+		return reply, err
+	}
+
+	// miekg/dns returns ErrTruncated for every reply with TC set, whether or
+	// not it parsed completely. parse a copy with TC cleared to find out.
+	clear := append([]byte(nil), wire...)
+	clear[2] &^= 0x02
+	reply = new(dns.Msg)
+	if err := reply.Unpack(clear); err != nil {
+		return nil, dns.ErrTruncated
+	}
+	reply.Truncated = true
+	return reply, nil
+}
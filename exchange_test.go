@@ -0,0 +1,61 @@
+package okaydns_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/okaycheck"
+	"github.com/blinsay/okaydns/okaytest"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExchangeMsgTruncated(t *testing.T) {
+	// a nameserver that sets TC on replies to names starting with "tc" and
+	// cuts off the last record of replies to names ending in "cut".
+	s := okaytest.StartHandler(t, dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		name := r.Question[0].Name
+		reply := new(dns.Msg).SetReply(r)
+		reply.Authoritative = true
+		reply.Truncated = strings.HasPrefix(name, "tc")
+		reply.Answer = okaycheck.MustParseRecords(name + " 300 IN A 192.0.2.1")
+
+		wire, err := reply.Pack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if strings.HasSuffix(name, "cut.example.com.") {
+			wire = wire[:len(wire)-2]
+		}
+		w.Write(wire)
+	}))
+
+	tcs := []struct {
+		name  string
+		qname string
+		kind  okaydns.ErrorKind
+	}{
+		{"complete", "complete.example.com.", ""},
+		{"complete with TC set", "tc.example.com.", ""},
+		{"cut off with TC set", "tc.cut.example.com.", okaydns.ErrorTruncated},
+		{"cut off without TC set", "cut.example.com.", okaydns.ErrorMalformedReply},
+	}
+
+	for _, tc := range tcs {
+		q := okaydns.NonRecursiveQuestion(tc.qname, dns.TypeA)
+		e := okaydns.ExchangeMsg(s.Nameserver(okaydns.ProtoUDP), q, 1)
+		if tc.kind != "" {
+			assert.Nil(t, e.Reply, tc.name)
+			assert.Equal(t, tc.kind, okaydns.KindOf(e.Err), tc.name)
+			continue
+		}
+
+		assert.NoError(t, e.Err, tc.name)
+		if assert.NotNil(t, e.Reply, tc.name) {
+			assert.Equal(t, strings.HasPrefix(tc.qname, "tc"), e.Reply.Truncated, tc.name)
+			assert.Len(t, e.Reply.Answer, 1, tc.name)
+		}
+	}
+}
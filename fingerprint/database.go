@@ -0,0 +1,298 @@
+package fingerprint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// A Database is a set of probes to send to a nameserver and a set of
+// fingerprints that match the responses to those probes.
+type Database struct {
+	Probes       []Probe       `json:"probes"`
+	Fingerprints []Fingerprint `json:"fingerprints"`
+}
+
+// A Probe describes a single question to send to a nameserver.
+//
+// Name is relative to the domain being checked. An empty Name or "@" is the
+// domain itself, and a Name ending in a "." is used as-is. Type and Class are
+// mnemonics like "A" and "CH", or a number written as "TYPE1234" or
+// "CLASS1234". Flags are any of "qr", "aa", "tc", "rd", "ra", "z", "ad" and
+// "cd".
+type Probe struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name,omitempty"`
+	Type   string   `json:"type"`
+	Class  string   `json:"class,omitempty"`
+	Opcode int      `json:"opcode,omitempty"`
+	Flags  []string `json:"flags,omitempty"`
+	EDNS   *EDNS    `json:"edns,omitempty"`
+}
+
+// EDNS describes the OPT record to include with a Probe. Options is a list of
+// EDNS option codes to include with empty data, which is useful for sending
+// options a nameserver doesn't understand.
+type EDNS struct {
+	Version uint8    `json:"version,omitempty"`
+	UDPSize uint16   `json:"udp_size,omitempty"`
+	DO      bool     `json:"do,omitempty"`
+	Options []uint16 `json:"options,omitempty"`
+}
+
+// A Fingerprint identifies a nameserver implementation. Responses maps probe
+// IDs to the signature pattern a nameserver must respond with to match.
+// Banners maps probe IDs to a regular expression that the text of the TXT
+// answer to that probe must match, like the version string most servers give
+// for version.bind. A nameserver matches a Fingerprint if it matches every
+// listed pattern and banner.
+//
+// Version is optional. A banner may capture the version of the implementation
+// in a group named "version", like "^NSD (?P<version>[0-9.]+)", which is used
+// when Version is empty.
+type Fingerprint struct {
+	Implementation string            `json:"implementation"`
+	Version        string            `json:"version,omitempty"`
+	Responses      map[string]string `json:"responses,omitempty"`
+	Banners        map[string]string `json:"banners,omitempty"`
+}
+
+func (f *Fingerprint) String() string {
+	if f.Version == "" {
+		return f.Implementation
+	}
+	return f.Implementation + " " + f.Version
+}
+
+// Load reads a JSON encoded Database from r and validates it. Fingerprints in
+// r may reference probes that aren't defined in r, so that a file can add
+// fingerprints for the probes in another Database. Those references are checked
+// when the Database is merged.
+func Load(r io.Reader) (*Database, error) {
+	var db Database
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&db); err != nil {
+		return nil, errors.Wrap(err, "invalid fingerprint database")
+	}
+	if err := db.validate(false); err != nil {
+		return nil, err
+	}
+	return &db, nil
+}
+
+// Default returns a copy of the built-in Database.
+func Default() *Database {
+	db, err := Load(strings.NewReader(defaultDatabase))
+	if err != nil {
+		panic(fmt.Sprintf("fingerprint: invalid default database: %s", err))
+	}
+	return db
+}
+
+// Merge adds all of the probes and fingerprints in other to db. Merge returns
+// an error and leaves db unmodified if the merged database isn't valid.
+func (db *Database) Merge(other *Database) error {
+	merged := Database{
+		Probes:       append(append([]Probe(nil), db.Probes...), other.Probes...),
+		Fingerprints: append(append([]Fingerprint(nil), db.Fingerprints...), other.Fingerprints...),
+	}
+	if err := merged.Validate(); err != nil {
+		return err
+	}
+
+	*db = merged
+	return nil
+}
+
+// Validate checks that every probe has a unique ID and can be turned into a
+// question, and that every fingerprint only references known probes with
+// well-formed patterns.
+func (db *Database) Validate() error {
+	return db.validate(true)
+}
+
+func (db *Database) validate(checkReferences bool) error {
+	probes := make(map[string]struct{}, len(db.Probes))
+	for i, probe := range db.Probes {
+		if probe.ID == "" {
+			return errors.Errorf("probe %d: missing an id", i)
+		}
+		if _, ok := probes[probe.ID]; ok {
+			return errors.Errorf("probe %s: duplicate id", probe.ID)
+		}
+		if _, err := probe.Question("example.com."); err != nil {
+			return errors.Wrapf(err, "probe %s", probe.ID)
+		}
+		probes[probe.ID] = struct{}{}
+	}
+
+	for i, fingerprint := range db.Fingerprints {
+		if fingerprint.Implementation == "" {
+			return errors.Errorf("fingerprint %d: missing an implementation", i)
+		}
+		if len(fingerprint.Responses) == 0 && len(fingerprint.Banners) == 0 {
+			return errors.Errorf("fingerprint %s: no responses or banners", fingerprint.String())
+		}
+		for _, id := range sortedKeys(fingerprint.Responses) {
+			if _, ok := probes[id]; checkReferences && !ok {
+				return errors.Errorf("fingerprint %s: unknown probe %s", fingerprint.String(), id)
+			}
+			if err := validPattern(fingerprint.Responses[id]); err != nil {
+				return errors.Wrapf(err, "fingerprint %s: probe %s", fingerprint.String(), id)
+			}
+		}
+		for _, id := range sortedKeys(fingerprint.Banners) {
+			if _, ok := probes[id]; checkReferences && !ok {
+				return errors.Errorf("fingerprint %s: unknown probe %s", fingerprint.String(), id)
+			}
+			if _, err := regexp.Compile(fingerprint.Banners[id]); err != nil {
+				return errors.Wrapf(err, "fingerprint %s: probe %s: invalid banner", fingerprint.String(), id)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Match returns the fingerprint that matches the given probe signatures and
+// banners. When more than one fingerprint matches, the one that lists the most
+// patterns and banners wins, and ties go to the fingerprint listed first. Match
+// returns nil if no fingerprints match.
+func (db *Database) Match(signatures, banners map[string]string) *Fingerprint {
+	var best *Fingerprint
+
+	for i := range db.Fingerprints {
+		fingerprint := &db.Fingerprints[i]
+		if !fingerprint.matches(signatures, banners) {
+			continue
+		}
+		if best == nil || fingerprint.size() > best.size() {
+			best = fingerprint
+		}
+	}
+	return best
+}
+
+// Version returns the version of the implementation that f identifies. It's
+// f.Version if that's set, and otherwise the "version" group of the first
+// banner that matches one of the fingerprints in db for the same
+// implementation, so that a fingerprint that only lists response patterns can
+// still report the version a nameserver gives. Version returns an empty string
+// if neither is available.
+func (db *Database) Version(f *Fingerprint, banners map[string]string) string {
+	if f.Version != "" {
+		return f.Version
+	}
+
+	candidates := append([]Fingerprint{*f}, db.Fingerprints...)
+	for _, candidate := range candidates {
+		if candidate.Implementation != f.Implementation {
+			continue
+		}
+		for _, id := range sortedKeys(candidate.Banners) {
+			banner, ok := banners[id]
+			if !ok {
+				continue
+			}
+			// banners are validated when they're loaded, so this can't fail.
+			re := regexp.MustCompile(candidate.Banners[id])
+			i := re.SubexpIndex("version")
+			if match := re.FindStringSubmatch(banner); match != nil && i >= 0 && match[i] != "" {
+				return match[i]
+			}
+		}
+	}
+	return ""
+}
+
+func (f *Fingerprint) matches(signatures, banners map[string]string) bool {
+	for id, pattern := range f.Responses {
+		signature, ok := signatures[id]
+		if !ok || !MatchSignature(pattern, signature) {
+			return false
+		}
+	}
+	for id, pattern := range f.Banners {
+		banner, ok := banners[id]
+		// banners are validated when they're loaded, so this can't fail.
+		if matched, _ := regexp.MatchString(pattern, banner); !ok || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// size is the number of patterns and banners a fingerprint lists.
+func (f *Fingerprint) size() int {
+	return len(f.Responses) + len(f.Banners)
+}
+
+// Question builds the query described by a Probe for the given fqdn.
+func (p *Probe) Question(fqdn string) (*dns.Msg, error) {
+	qtype, err := okaydns.ParseType(p.Type)
+	if err != nil {
+		return nil, err
+	}
+	qclass := uint16(dns.ClassINET)
+	if p.Class != "" {
+		if qclass, err = okaydns.ParseClass(p.Class); err != nil {
+			return nil, err
+		}
+	}
+	if p.Opcode < 0 || p.Opcode > 15 {
+		return nil, errors.Errorf("invalid opcode: %d", p.Opcode)
+	}
+
+	q := new(dns.Msg)
+	q.SetQuestion(okaydns.RelativeName(p.Name, fqdn), qtype)
+	q.Question[0].Qclass = qclass
+	q.Opcode = p.Opcode
+	q.RecursionDesired = false
+
+	for _, flag := range p.Flags {
+		switch strings.ToLower(flag) {
+		case "qr":
+			q.Response = true
+		case "aa":
+			q.Authoritative = true
+		case "tc":
+			q.Truncated = true
+		case "rd":
+			q.RecursionDesired = true
+		case "ra":
+			q.RecursionAvailable = true
+		case "z":
+			q.Zero = true
+		case "ad":
+			q.AuthenticatedData = true
+		case "cd":
+			q.CheckingDisabled = true
+		default:
+			return nil, errors.Errorf("unknown flag: %s", flag)
+		}
+	}
+
+	if p.EDNS != nil {
+		udpSize := p.EDNS.UDPSize
+		if udpSize == 0 {
+			udpSize = dns.DefaultMsgSize
+		}
+		q.SetEdns0(udpSize, p.EDNS.DO)
+
+		opt := q.IsEdns0()
+		opt.SetVersion(p.EDNS.Version)
+		for _, code := range p.EDNS.Options {
+			opt.Option = append(opt.Option, &dns.EDNS0_LOCAL{Code: code, Data: []byte{}})
+		}
+	}
+
+	return q, nil
+}
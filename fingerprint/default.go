@@ -0,0 +1,109 @@
+package fingerprint
+
+// defaultDatabase is the built-in set of probes and fingerprints.
+//
+// The response fingerprints describe how each implementation handles the
+// probes in its default configuration: whether it's authoritative or
+// recursive, whether an unknown opcode gets NOTIMP with or without the
+// question or no reply at all, and how it answers AXFR over UDP and SOA
+// queries in the CHAOS class. Only the fields
+// that don't depend on configuration are listed; the others are wildcards.
+// The banner fingerprints match the version string that each implementation
+// gives for version.bind by default, and capture its version.
+//
+// When both kinds of fingerprint match, the response fingerprint wins and the
+// version still comes from the banner. A nameserver that hides its banner is
+// still identified by its responses, and one that forges its banner is
+// identified by its responses rather than by the banner.
+//
+// More fingerprints can be recorded from real nameservers, by running okdns
+// -fingerprint -verbose against a known implementation and copying the
+// reported signatures into a database file.
+const defaultDatabase = `{
+  "probes": [
+    {"id": "query", "type": "SOA"},
+    {"id": "query-rd", "type": "SOA", "flags": ["rd"]},
+    {"id": "query-all-flags", "type": "SOA", "flags": ["aa", "tc", "rd", "ra", "z", "ad", "cd"]},
+    {"id": "query-qr", "type": "SOA", "flags": ["qr"]},
+    {"id": "iquery", "type": "A", "opcode": 1},
+    {"id": "status", "type": "A", "opcode": 2},
+    {"id": "notify", "type": "SOA", "opcode": 4},
+    {"id": "update", "type": "SOA", "opcode": 5},
+    {"id": "opcode-15", "type": "A", "opcode": 15},
+    {"id": "unknown-type", "type": "TYPE666"},
+    {"id": "axfr-udp", "type": "AXFR"},
+    {"id": "class-chaos", "type": "SOA", "class": "CH"},
+    {"id": "class-none", "type": "SOA", "class": "NONE"},
+    {"id": "class-any", "type": "SOA", "class": "ANY"},
+    {"id": "class-unknown", "type": "SOA", "class": "CLASS666"},
+    {"id": "version-bind", "name": "version.bind.", "type": "TXT", "class": "CH"},
+    {"id": "edns", "type": "SOA", "edns": {}},
+    {"id": "edns-do", "type": "SOA", "edns": {"do": true}},
+    {"id": "edns-version-1", "type": "SOA", "edns": {"version": 1}},
+    {"id": "edns-unknown-option", "type": "SOA", "edns": {"options": [65001]}},
+    {"id": "edns-tiny-buffer", "type": "SOA", "edns": {"udp_size": 1}}
+  ],
+  "fingerprints": [
+    {
+      "implementation": "BIND",
+      "responses": {
+        "opcode-15": "1,OPCODE15,0,0,0,*,0,0,0,NOTIMPL,1,0,0,0,none",
+        "axfr-udp": "1,QUERY,0,0,0,*,0,0,0,FORMERR,1,0,0,0,none",
+        "class-chaos": "1,QUERY,0,0,0,*,0,0,0,REFUSED,1,0,0,0,none"
+      }
+    },
+    {
+      "implementation": "Knot DNS",
+      "responses": {
+        "opcode-15": "1,OPCODE15,0,0,0,0,0,0,0,NOTIMPL,1,0,0,0,none",
+        "axfr-udp": "1,QUERY,0,0,0,0,0,0,0,NOTIMPL,1,0,0,0,none",
+        "class-chaos": "1,QUERY,*,0,0,0,0,0,0,REFUSED,1,0,0,0,none"
+      }
+    },
+    {
+      "implementation": "PowerDNS Authoritative Server",
+      "responses": {
+        "query": "1,QUERY,1,0,0,0,0,0,0,NOERROR,1,1,*,*,none",
+        "opcode-15": "1,OPCODE15,0,0,0,0,0,0,0,NOTIMPL,1,0,0,0,none",
+        "class-chaos": "1,QUERY,0,0,0,0,0,0,0,NOTIMPL,1,0,0,0,none"
+      }
+    },
+    {
+      "implementation": "NSD",
+      "responses": {
+        "query": "1,QUERY,1,0,0,0,0,0,0,NOERROR,1,1,*,*,none",
+        "opcode-15": "1,OPCODE15,0,0,0,0,0,0,0,NOTIMPL,0,0,0,0,none",
+        "class-chaos": "1,QUERY,0,0,0,0,0,0,0,REFUSED,1,0,0,0,none"
+      }
+    },
+    {
+      "implementation": "CoreDNS",
+      "responses": {
+        "query": "1,QUERY,1,0,0,0,0,0,0,NOERROR,1,1,*,*,none",
+        "opcode-15": "1,OPCODE15,0,0,0,0,0,0,0,NOTIMPL,0,0,0,0,none",
+        "class-chaos": "1,QUERY,0,0,0,0,0,0,0,SERVFAIL,1,0,0,0,none"
+      }
+    },
+    {
+      "implementation": "Unbound",
+      "responses": {
+        "query": "1,QUERY,0,0,0,1,0,0,0,*,1,*,*,*,none",
+        "opcode-15": "1,OPCODE15,0,0,0,0,0,0,0,NOTIMPL,1,0,0,0,none"
+      }
+    },
+    {
+      "implementation": "PowerDNS Recursor",
+      "responses": {
+        "query": "1,QUERY,0,0,0,1,0,0,0,*,1,*,*,*,none",
+        "opcode-15": "timeout"
+      }
+    },
+    {"implementation": "BIND", "banners": {"version-bind": "^(?P<version>9\\.[0-9]+\\.[0-9]+)"}},
+    {"implementation": "Unbound", "banners": {"version-bind": "^unbound (?P<version>[0-9][^ ]*)"}},
+    {"implementation": "NSD", "banners": {"version-bind": "^NSD (?P<version>[0-9][^ ]*)"}},
+    {"implementation": "Knot DNS", "banners": {"version-bind": "^Knot DNS (?P<version>[0-9][^ ]*)"}},
+    {"implementation": "PowerDNS Authoritative Server", "banners": {"version-bind": "^PowerDNS Authoritative Server (?P<version>[0-9][^ ]*)"}},
+    {"implementation": "PowerDNS Recursor", "banners": {"version-bind": "^PowerDNS Recursor (?P<version>[0-9][^ ]*)"}},
+    {"implementation": "CoreDNS", "banners": {"version-bind": "^CoreDNS-(?P<version>[0-9][^ ]*)"}}
+  ]
+}`
@@ -0,0 +1,225 @@
+// Package fingerprint identifies nameserver software by sending a battery of
+// unusual queries and matching the pattern of responses against a database of
+// known implementations, in the style of fpdns.
+//
+// Both the probes and the fingerprints are data. A Database is loaded from JSON
+// and can be extended with more probes and fingerprints without any code
+// changes.
+package fingerprint
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+)
+
+// A Result is the outcome of fingerprinting a single nameserver. Signatures
+// maps probe IDs to the signature of the response to that probe, and Banners
+// maps probe IDs to the text of a TXT answer to that probe, for the probes
+// that got one.
+//
+// Match is nil if no fingerprint in the database matched every signature and
+// banner it lists. Version is the version of the matching implementation, if
+// the fingerprint or the nameserver's banners say what it is.
+type Result struct {
+	Nameserver okaydns.Nameserver
+	Signatures map[string]string
+	Banners    map[string]string
+	Match      *Fingerprint
+	Version    string
+}
+
+// Implementation returns the name and version of the matching implementation,
+// or "unknown" if no fingerprint matched.
+func (r *Result) Implementation() string {
+	switch {
+	case r.Match == nil:
+		return "unknown"
+	case r.Version == "":
+		return r.Match.Implementation
+	default:
+		return r.Match.Implementation + " " + r.Version
+	}
+}
+
+// Identify sends every probe in the database to every nameserver and matches
+// the responses against the fingerprints in the database. Probes are sent with
+// the default Checker.
+func Identify(db *Database, fqdn string, nameservers []okaydns.Nameserver) []*Result {
+	results := make([]*Result, len(nameservers))
+	for i, nameserver := range nameservers {
+		results[i] = &Result{
+			Nameserver: nameserver,
+			Signatures: make(map[string]string, len(db.Probes)),
+			Banners:    make(map[string]string),
+		}
+	}
+
+	for _, probe := range db.Probes {
+		probe := probe
+		check := okaydns.Check{
+			Name: probe.ID,
+			Question: func(fqdn string) *dns.Msg {
+				// probes are validated when they're loaded, so this can't fail.
+				q, _ := probe.Question(fqdn)
+				return q
+			},
+		}
+		cr := okaydns.DoCheck(&check, fqdn, nameservers)
+
+		for _, result := range results {
+			if err, ok := cr.Errors[result.Nameserver]; ok {
+				result.Signatures[probe.ID] = errorSignature(err)
+				continue
+			}
+			if answer, ok := cr.Answers[result.Nameserver]; ok {
+				result.Signatures[probe.ID] = Signature(answer)
+				if banner, ok := Banner(answer); ok {
+					result.Banners[probe.ID] = banner
+				}
+			}
+		}
+	}
+
+	for _, result := range results {
+		result.Match = db.Match(result.Signatures, result.Banners)
+		if result.Match != nil {
+			result.Version = db.Version(result.Match, result.Banners)
+		}
+	}
+	return results
+}
+
+// Banner returns the text of the first TXT record in the answer section of m.
+// The strings in the record are joined without a separator.
+func Banner(m *dns.Msg) (string, bool) {
+	for _, rr := range m.Answer {
+		if txt, ok := rr.(*dns.TXT); ok {
+			return strings.Join(txt.Txt, ""), true
+		}
+	}
+	return "", false
+}
+
+// SignatureTimeout is the signature of a probe that got no response.
+const SignatureTimeout = "timeout"
+
+// SignatureError is the signature of a probe that failed for any reason other
+// than a timeout, including responses that couldn't be parsed.
+const SignatureError = "error"
+
+func errorSignature(err error) string {
+	if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+		return SignatureTimeout
+	}
+	return SignatureError
+}
+
+// signatureFields are the names of the fields in a Signature, in order.
+var signatureFields = []string{
+	"qr", "opcode", "aa", "tc", "rd", "ra", "z", "ad", "cd", "rcode", "qdcount", "ancount", "nscount", "arcount", "edns",
+}
+
+// Signature reduces a response to the header fields and section counts that
+// fingerprints match against. Signatures are comma-separated lists of:
+//
+//	qr,opcode,aa,tc,rd,ra,z,ad,cd,rcode,qdcount,ancount,nscount,arcount,edns
+//
+// Flags are 0 or 1. The opcode and rcode are their mnemonic names, where the
+// rcode includes any EDNS extended rcode bits. The edns field is the EDNS
+// version of the response, or "none" if the response has no OPT record.
+func Signature(m *dns.Msg) string {
+	rcode := m.Rcode
+	edns := "none"
+	if opt := m.IsEdns0(); opt != nil {
+		rcode |= opt.ExtendedRcode() << 4
+		edns = strconv.Itoa(int(opt.Version()))
+	}
+
+	fields := []string{
+		bit(m.Response),
+		opcodeString(m.Opcode),
+		bit(m.Authoritative),
+		bit(m.Truncated),
+		bit(m.RecursionDesired),
+		bit(m.RecursionAvailable),
+		bit(m.Zero),
+		bit(m.AuthenticatedData),
+		bit(m.CheckingDisabled),
+		rcodeString(rcode),
+		strconv.Itoa(len(m.Question)),
+		strconv.Itoa(len(m.Answer)),
+		strconv.Itoa(len(m.Ns)),
+		strconv.Itoa(len(m.Extra)),
+		edns,
+	}
+	return strings.Join(fields, ",")
+}
+
+// MatchSignature returns true if signature matches pattern. A pattern is either
+// one of the SignatureTimeout or SignatureError signatures, or a Signature
+// where any field may be replaced by a "*" that matches any value.
+func MatchSignature(pattern, signature string) bool {
+	patternFields := strings.Split(pattern, ",")
+	signatureFields := strings.Split(signature, ",")
+	if len(patternFields) != len(signatureFields) {
+		return false
+	}
+
+	for i, field := range patternFields {
+		if field != "*" && !strings.EqualFold(field, signatureFields[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func validPattern(pattern string) error {
+	if pattern == SignatureTimeout || pattern == SignatureError {
+		return nil
+	}
+	if fields := strings.Split(pattern, ","); len(fields) != len(signatureFields) {
+		return fmt.Errorf("pattern %q has %d fields, expected %d (%s)", pattern, len(fields), len(signatureFields), strings.Join(signatureFields, ","))
+	}
+	return nil
+}
+
+func bit(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func opcodeString(opcode int) string {
+	if s, ok := dns.OpcodeToString[opcode]; ok {
+		return s
+	}
+	return fmt.Sprintf("OPCODE%d", opcode)
+}
+
+func rcodeString(rcode int) string {
+	// BADSIG and BADVERS share a code. BADSIG only ever appears in a TSIG
+	// record, so an rcode in the header is always BADVERS.
+	if rcode == dns.RcodeBadVers {
+		return "BADVERS"
+	}
+	if s, ok := dns.RcodeToString[rcode]; ok {
+		return s
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// sortedKeys returns the keys of a map of signatures in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
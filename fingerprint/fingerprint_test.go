@@ -0,0 +1,287 @@
+package fingerprint
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/okaycheck"
+	"github.com/blinsay/okaydns/okaytest"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+const testDatabase = `{
+  "probes": [
+    {"id": "query", "type": "SOA"},
+    {"id": "opcode-15", "type": "A", "opcode": 15},
+    {"id": "edns-version-1", "type": "SOA", "edns": {"version": 1}}
+  ],
+  "fingerprints": [
+    {
+      "implementation": "Strict",
+      "responses": {
+        "query": "1,QUERY,1,0,0,0,0,0,0,NOERROR,1,1,0,0,none",
+        "opcode-15": "1,OPCODE15,0,0,0,0,0,0,0,NOTIMPL,*,0,0,0,none"
+      }
+    },
+    {
+      "implementation": "Strict",
+      "version": "with EDNS",
+      "responses": {
+        "query": "1,QUERY,1,0,0,0,0,0,0,NOERROR,1,1,0,0,none",
+        "opcode-15": "1,OPCODE15,0,0,0,0,0,0,0,NOTIMPL,*,0,0,0,none",
+        "edns-version-1": "1,QUERY,*,0,0,0,0,0,0,BADVERS,1,0,0,1,0"
+      }
+    },
+    {
+      "implementation": "Lame",
+      "responses": {
+        "query": "1,QUERY,0,0,0,0,0,0,0,REFUSED,1,0,0,0,none"
+      }
+    }
+  ]
+}`
+
+func TestIdentify(t *testing.T) {
+	db, err := Load(strings.NewReader(testDatabase))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := okaytest.StartHandler(t, dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		reply := new(dns.Msg).SetReply(r)
+
+		switch {
+		case r.Opcode != dns.OpcodeQuery:
+			reply.Rcode = dns.RcodeNotImplemented
+		case r.IsEdns0() != nil && r.IsEdns0().Version() != 0:
+			reply.SetEdns0(dns.DefaultMsgSize, false)
+			reply.Rcode = dns.RcodeBadVers
+		default:
+			reply.Authoritative = true
+			reply.Answer = append(reply.Answer, &dns.SOA{
+				Hdr:  dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 300},
+				Ns:   "ns1.example.com.",
+				Mbox: "hostmaster.example.com.",
+			})
+		}
+
+		w.WriteMsg(reply)
+	}))
+
+	nameserver := s.Nameserver(okaydns.ProtoUDP)
+	results := Identify(db, "example.com.", []okaydns.Nameserver{nameserver})
+	if !assert.Len(t, results, 1) {
+		t.FailNow()
+	}
+
+	result := results[0]
+	assert.Equal(t, nameserver, result.Nameserver)
+	assert.Equal(t, "1,QUERY,1,0,0,0,0,0,0,NOERROR,1,1,0,0,none", result.Signatures["query"])
+	assert.Equal(t, "1,QUERY,0,0,0,0,0,0,0,BADVERS,1,0,0,1,0", result.Signatures["edns-version-1"])
+	if assert.NotNil(t, result.Match, "expected a fingerprint to match") {
+		assert.Equal(t, "Strict with EDNS", result.Match.String())
+		assert.Equal(t, "with EDNS", result.Version)
+	}
+}
+
+func TestMatchSignature(t *testing.T) {
+	signature := "1,QUERY,1,0,0,0,0,0,0,NOERROR,1,1,0,0,none"
+
+	assert.True(t, MatchSignature(signature, signature))
+	assert.True(t, MatchSignature("1,query,1,0,0,0,0,0,0,noerror,1,1,0,0,none", signature))
+	assert.True(t, MatchSignature("1,*,1,0,0,0,0,0,0,*,1,*,0,0,none", signature))
+	assert.False(t, MatchSignature("1,QUERY,0,0,0,0,0,0,0,NOERROR,1,1,0,0,none", signature))
+	assert.False(t, MatchSignature("1,QUERY,1", signature))
+	assert.False(t, MatchSignature(SignatureTimeout, signature))
+	assert.True(t, MatchSignature(SignatureTimeout, SignatureTimeout))
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tcs := []struct {
+		name string
+		db   string
+	}{
+		{"not json", `probes:`},
+		{"unknown fields", `{"probes": [{"id": "query", "type": "SOA", "qtype": "A"}]}`},
+		{"missing id", `{"probes": [{"type": "SOA"}]}`},
+		{"duplicate id", `{"probes": [{"id": "query", "type": "SOA"}, {"id": "query", "type": "A"}]}`},
+		{"unknown type", `{"probes": [{"id": "query", "type": "NOPE"}]}`},
+		{"unknown class", `{"probes": [{"id": "query", "type": "SOA", "class": "NOPE"}]}`},
+		{"unknown flag", `{"probes": [{"id": "query", "type": "SOA", "flags": ["xx"]}]}`},
+		{"invalid opcode", `{"probes": [{"id": "query", "type": "SOA", "opcode": 16}]}`},
+		{"invalid pattern", `{"probes": [{"id": "query", "type": "SOA"}], "fingerprints": [{"implementation": "x", "responses": {"query": "1,QUERY"}}]}`},
+		{"missing implementation", `{"probes": [{"id": "query", "type": "SOA"}], "fingerprints": [{"responses": {"query": "timeout"}}]}`},
+		{"no responses or banners", `{"probes": [{"id": "query", "type": "SOA"}], "fingerprints": [{"implementation": "x"}]}`},
+		{"invalid banner", `{"probes": [{"id": "query", "type": "SOA"}], "fingerprints": [{"implementation": "x", "banners": {"query": "("}}]}`},
+	}
+
+	for _, tc := range tcs {
+		_, err := Load(strings.NewReader(tc.db))
+		assert.Error(t, err, tc.name)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	db := Default()
+	assert.NotEmpty(t, db.Probes)
+	assert.NotEmpty(t, db.Fingerprints)
+	builtin := len(db.Fingerprints)
+
+	extra, err := Load(strings.NewReader(`{
+	  "probes": [{"id": "query-aaaa", "type": "AAAA"}],
+	  "fingerprints": [{"implementation": "Example", "responses": {"query": "timeout", "query-aaaa": "timeout"}}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, db.Merge(extra))
+	assert.Len(t, db.Fingerprints, builtin+1)
+
+	assert.Error(t, db.Merge(extra), "merging duplicate probes should fail")
+	assert.Len(t, db.Fingerprints, builtin+1, "a failed merge should not modify the database")
+
+	unknown, err := Load(strings.NewReader(`{"fingerprints": [{"implementation": "x", "responses": {"nope": "timeout"}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, db.Merge(unknown), "merging fingerprints for unknown probes should fail")
+}
+
+// implementation describes how a nameserver answers the probes in the default
+// database, for testing the default fingerprints.
+type implementation struct {
+	// opcodes is the rcode for queries with an opcode other than QUERY. If
+	// truncate is set the question is stripped from those replies, and if
+	// drop is set queries with an unassigned opcode get no reply at all.
+	opcodes  int
+	truncate bool
+	drop     bool
+	axfr     int
+	chaos    int
+	// recursive servers set RA and refuse queries for names they don't
+	// serve.
+	recursive bool
+	banner    string
+}
+
+func (i implementation) reply(r *dns.Msg) *dns.Msg {
+	q := r.Question[0]
+	switch {
+	case r.Opcode > dns.OpcodeUpdate && i.drop:
+		return nil
+	case r.Opcode != dns.OpcodeQuery:
+		reply := new(dns.Msg).SetRcode(r, i.opcodes)
+		if i.truncate {
+			reply.Question = nil
+		}
+		return reply
+	case q.Name == "version.bind." && q.Qclass == dns.ClassCHAOS && i.banner != "":
+		reply := new(dns.Msg).SetReply(r)
+		reply.Authoritative = true
+		reply.Answer = append(reply.Answer, &dns.TXT{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassCHAOS},
+			Txt: []string{i.banner},
+		})
+		return reply
+	case q.Qclass == dns.ClassCHAOS:
+		return new(dns.Msg).SetRcode(r, i.chaos)
+	case i.recursive:
+		reply := new(dns.Msg).SetRcode(r, dns.RcodeRefused)
+		reply.RecursionAvailable = true
+		return reply
+	case q.Qtype == dns.TypeAXFR:
+		return new(dns.Msg).SetRcode(r, i.axfr)
+	default:
+		reply := new(dns.Msg).SetReply(r)
+		reply.Authoritative = true
+		reply.Answer = okaycheck.MustParseRecords(q.Name + " 300 IN SOA ns1.example.com. hostmaster.example.com. 1 3600 600 86400 60")
+		return reply
+	}
+}
+
+// serveUDP answers every query on a UDP socket with reply, including queries
+// with QR set that a dns.Server would drop before they reach a handler, so that
+// only the probes a test means to drop time out. Queries are dropped if reply
+// returns nil.
+func serveUDP(t *testing.T, reply func(r *dns.Msg) *dns.Msg) okaydns.Nameserver {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	go func() {
+		buf := make([]byte, dns.MaxMsgSize)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			// queries with TC set are unpacked in full, but still return
+			// ErrTruncated.
+			r := new(dns.Msg)
+			if err := r.Unpack(buf[:n]); (err != nil && err != dns.ErrTruncated) || len(r.Question) != 1 {
+				continue
+			}
+			if m := reply(r); m != nil {
+				wire, _ := m.Pack()
+				pc.WriteTo(wire, addr)
+			}
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(pc.LocalAddr().String())
+	return okaydns.Nameserver{Hostname: "127.0.0.1", IP: "127.0.0.1", Port: port, Proto: okaydns.ProtoUDP}
+}
+
+func TestIdentifyDefault(t *testing.T) {
+	var (
+		bind     = implementation{opcodes: dns.RcodeNotImplemented, axfr: dns.RcodeFormatError, chaos: dns.RcodeRefused}
+		knot     = implementation{opcodes: dns.RcodeNotImplemented, axfr: dns.RcodeNotImplemented, chaos: dns.RcodeRefused}
+		pdns     = implementation{opcodes: dns.RcodeNotImplemented, axfr: dns.RcodeNotImplemented, chaos: dns.RcodeNotImplemented}
+		nsd      = implementation{opcodes: dns.RcodeNotImplemented, truncate: true, axfr: dns.RcodeRefused, chaos: dns.RcodeRefused}
+		coredns  = implementation{opcodes: dns.RcodeNotImplemented, truncate: true, axfr: dns.RcodeRefused, chaos: dns.RcodeServerFailure}
+		unbound  = implementation{opcodes: dns.RcodeNotImplemented, recursive: true, chaos: dns.RcodeRefused}
+		recursor = implementation{opcodes: dns.RcodeNotImplemented, drop: true, recursive: true, chaos: dns.RcodeRefused}
+		unknown  = implementation{opcodes: dns.RcodeRefused, axfr: dns.RcodeRefused, chaos: dns.RcodeRefused}
+	)
+	withBanner := func(i implementation, banner string) implementation {
+		i.banner = banner
+		return i
+	}
+
+	tcs := []struct {
+		name           string
+		server         implementation
+		implementation string
+	}{
+		{"bind", withBanner(bind, "9.18.19-1~deb12u1-Debian"), "BIND 9.18.19"},
+		{"bind without a banner", bind, "BIND"},
+		{"knot", withBanner(knot, "Knot DNS 3.2.6"), "Knot DNS 3.2.6"},
+		{"powerdns", withBanner(pdns, "PowerDNS Authoritative Server 4.8.3 (built Oct 19 2023 16:40:55 by root@localhost)"), "PowerDNS Authoritative Server 4.8.3"},
+		{"nsd", withBanner(nsd, "NSD 4.6.1"), "NSD 4.6.1"},
+		{"nsd with another banner", withBanner(nsd, "9.18.19"), "NSD"},
+		{"coredns", withBanner(coredns, "CoreDNS-1.11.1"), "CoreDNS 1.11.1"},
+		{"unbound", withBanner(unbound, "unbound 1.17.1"), "Unbound 1.17.1"},
+		{"powerdns recursor", recursor, "PowerDNS Recursor"},
+		{"banner only", withBanner(unknown, "NSD 4.6.1"), "NSD 4.6.1"},
+		{"unknown", withBanner(unknown, "none of your business"), "unknown"},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			nameserver := serveUDP(t, tc.server.reply)
+			results := Identify(Default(), "example.com.", []okaydns.Nameserver{nameserver})
+			if assert.Len(t, results, 1) {
+				assert.Equal(t, tc.server.banner, results[0].Banners["version-bind"])
+				assert.Equal(t, tc.implementation, results[0].Implementation())
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package fuzz
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// A Case is a way to break a DNS message. Packet is given a well-formed query
+// for a single name in wire format and returns a malformed version of it.
+// Packet may modify the query.
+type Case struct {
+	Name   string
+	Packet func(query []byte) []byte
+}
+
+// offsets into a DNS header
+const (
+	headerSize    = 12
+	offsetQDCount = 4
+	offsetANCount = 6
+)
+
+// DefaultCases are the malformed packets sent to a nameserver when no other
+// cases are specified.
+var DefaultCases = []Case{
+	{
+		Name: "truncated header",
+		Packet: func(query []byte) []byte {
+			return query[:headerSize/2]
+		},
+	},
+	{
+		Name: "missing question",
+		Packet: func(query []byte) []byte {
+			return query[:headerSize]
+		},
+	},
+	{
+		Name: "truncated question",
+		Packet: func(query []byte) []byte {
+			return query[:len(query)-3]
+		},
+	},
+	{
+		Name: "compression pointer out of bounds",
+		Packet: func(query []byte) []byte {
+			return withQName(query, []byte{0xC0, 0xFF})
+		},
+	},
+	{
+		Name: "compression pointer loop",
+		Packet: func(query []byte) []byte {
+			return withQName(query, []byte{0xC0, headerSize})
+		},
+	},
+	{
+		Name: "oversized label",
+		Packet: func(query []byte) []byte {
+			label := append([]byte{64}, bytes.Repeat([]byte("a"), 64)...)
+			return withQName(query, append(label, 0))
+		},
+	},
+	{
+		Name: "oversized name",
+		Packet: func(query []byte) []byte {
+			var name []byte
+			for i := 0; i < 5; i++ {
+				name = append(name, 63)
+				name = append(name, bytes.Repeat([]byte("a"), 63)...)
+			}
+			return withQName(query, append(name, 0))
+		},
+	},
+	{
+		Name: "question count too large",
+		Packet: func(query []byte) []byte {
+			binary.BigEndian.PutUint16(query[offsetQDCount:], 2)
+			return query
+		},
+	},
+	{
+		Name: "answer count too large",
+		Packet: func(query []byte) []byte {
+			binary.BigEndian.PutUint16(query[offsetANCount:], 5)
+			return query
+		},
+	},
+	{
+		Name: "trailing garbage",
+		Packet: func(query []byte) []byte {
+			return append(query, bytes.Repeat([]byte{0xFF}, 16)...)
+		},
+	},
+}
+
+// withQName replaces the name in the first question of a query with the given
+// wire-format name. The query must have been packed without compression.
+func withQName(query []byte, name []byte) []byte {
+	end := headerSize
+	for end < len(query) && query[end] != 0 {
+		end += int(query[end]) + 1
+	}
+	end++ // the root label
+	if end > len(query) {
+		end = len(query)
+	}
+
+	packet := append([]byte(nil), query[:headerSize]...)
+	packet = append(packet, name...)
+	return append(packet, query[end:]...)
+}
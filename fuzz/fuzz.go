@@ -0,0 +1,187 @@
+// Package fuzz checks that authoritative nameservers survive deliberately
+// malformed packets.
+//
+// Every Case is sent to every nameserver. A nameserver passes a Case if it
+// drops the packet or answers it with FORMERR, and if it keeps answering
+// well-formed queries afterwards.
+//
+// Sending malformed packets to a nameserver you don't operate is rude at best.
+// Only use this package against your own nameservers.
+package fuzz
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+)
+
+// DefaultTimeout is how long a Runner waits for a reply to a malformed packet
+// before deciding it was dropped.
+const DefaultTimeout = 2 * time.Second
+
+// DefaultAttempts is how many times a Runner sends a well-formed query that
+// times out before deciding a nameserver stopped answering.
+const DefaultAttempts = 3
+
+// A Runner sends malformed packets to nameservers. The zero value is a Runner
+// that uses DefaultCases, DefaultTimeout and DefaultAttempts.
+type Runner struct {
+	Cases    []Case
+	Timeout  time.Duration
+	Attempts int
+}
+
+// Run sends every Case to every nameserver and returns a CheckResult for each
+// Case. The malformed packets are built from a non-recursive A query for fqdn,
+// and liveness is checked with a SOA query for fqdn.
+func (r *Runner) Run(fqdn string, nameservers []okaydns.Nameserver) []*okaydns.CheckResult {
+	cases := r.Cases
+	if len(cases) == 0 {
+		cases = DefaultCases
+	}
+
+	results := make([]*okaydns.CheckResult, len(cases))
+	for i, c := range cases {
+		results[i] = r.runCase(c, fqdn, nameservers)
+	}
+	return results
+}
+
+func (r *Runner) timeout() time.Duration {
+	if r.Timeout > 0 {
+		return r.Timeout
+	}
+	return DefaultTimeout
+}
+
+func (r *Runner) attempts() int {
+	if r.Attempts > 0 {
+		return r.Attempts
+	}
+	return DefaultAttempts
+}
+
+// Codes for the failures returned by a Runner.
+const (
+	CodeStoppedAnswering = "stopped-answering"
+	CodeMalformedReply   = "malformed-reply"
+	CodeNotFormerr       = "not-formerr"
+)
+
+func (r *Runner) runCase(c Case, fqdn string, nameservers []okaydns.Nameserver) *okaydns.CheckResult {
+	cr := &okaydns.CheckResult{
+		Name:        "Survives malformed packets: " + c.Name,
+		Nameservers: nameservers,
+		Errors:      make(map[okaydns.Nameserver]error),
+	}
+
+	query, err := okaydns.NonRecursiveQuestion(fqdn, dns.TypeA).Pack()
+	if err != nil {
+		cr.Failures = append(cr.Failures, okaydns.Failure{Message: fmt.Sprintf("invalid query: %s", err)})
+		return cr
+	}
+
+	for _, nameserver := range nameservers {
+		packet := c.Packet(append([]byte(nil), query...))
+
+		reply, err := okaydns.ExchangeRaw(nameserver, packet, r.timeout())
+		if err != nil && !dropped(nameserver.Proto, err) {
+			cr.Errors[nameserver] = okaydns.ClassifyError(err)
+		}
+		if err == nil {
+			if failure := validateReply(reply); failure != nil {
+				failure.Nameserver = nameserver
+				cr.Failures = append(cr.Failures, *failure)
+			}
+		}
+
+		if err := r.alive(fqdn, nameserver); err != nil {
+			cr.Failures = append(cr.Failures, okaydns.Failure{
+				Message:    fmt.Sprintf("stopped answering queries after a malformed packet: %s", err),
+				Nameserver: nameserver,
+				Severity:   okaydns.SeverityCritical,
+				Code:       CodeStoppedAnswering,
+			})
+		}
+	}
+
+	return cr
+}
+
+// validateReply checks that a reply to a malformed packet is a FORMERR.
+func validateReply(reply []byte) *okaydns.Failure {
+	m := new(dns.Msg)
+	if err := m.Unpack(reply); err != nil {
+		// some servers echo back the header of a broken packet with FORMERR set
+		// and no sections. that's fine, but can't always be unpacked.
+		if len(reply) >= headerSize && m.Rcode == dns.RcodeFormatError && m.Response {
+			return nil
+		}
+		return &okaydns.Failure{
+			Message: fmt.Sprintf("replied with a message that can't be parsed: %s", err),
+			Code:    CodeMalformedReply,
+		}
+	}
+
+	if m.Rcode != dns.RcodeFormatError {
+		return &okaydns.Failure{
+			Message:   fmt.Sprintf("replied with %s instead of FORMERR or no reply", dns.RcodeToString[m.Rcode]),
+			Code:      CodeNotFormerr,
+			Reference: "https://tools.ietf.org/html/rfc1035#section-4.1.1",
+		}
+	}
+	return nil
+}
+
+// alive checks that a nameserver still answers a well-formed query. Queries
+// that time out are retried, so that a single lost packet isn't mistaken for a
+// crash.
+func (r *Runner) alive(fqdn string, nameserver okaydns.Nameserver) error {
+	q := okaydns.NonRecursiveQuestion(fqdn, dns.TypeSOA)
+	wire, err := q.Pack()
+	if err != nil {
+		return err
+	}
+
+	var reply []byte
+	for attempt := 1; attempt <= r.attempts(); attempt++ {
+		reply, err = okaydns.ExchangeRaw(nameserver, wire, r.timeout())
+		if err == nil || okaydns.KindOf(err) != okaydns.ErrorTimeout {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	m := new(dns.Msg)
+	if err := m.Unpack(reply); err != nil {
+		return err
+	}
+	if m.Id != q.Id {
+		return dns.ErrId
+	}
+	return nil
+}
+
+// dropped returns true if an error means that a nameserver didn't reply to a
+// packet: it either timed out or, over TCP, closed the connection.
+func dropped(proto okaydns.Proto, err error) bool {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return true
+	}
+	if proto == okaydns.ProtoUDP {
+		return false
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	if opErr, ok := err.(*net.OpError); ok && opErr.Op == "read" {
+		return true
+	}
+	return false
+}
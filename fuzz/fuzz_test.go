@@ -0,0 +1,141 @@
+package fuzz
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/okaytest"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTimeout = 250 * time.Millisecond
+
+func TestRun(t *testing.T) {
+	// miekg/dns answers most malformed packets with FORMERR before they get to
+	// a handler, but happily parses packets with extra data or with section
+	// counts that are too large.
+	lenient := map[string]bool{
+		"answer count too large": true,
+		"trailing garbage":       true,
+	}
+
+	s := testServer(t)
+	for _, proto := range []okaydns.Proto{okaydns.ProtoUDP, okaydns.ProtoTCP} {
+		nameserver := s.Nameserver(proto)
+		runner := Runner{Timeout: testTimeout}
+
+		results := runner.Run("example.com.", []okaydns.Nameserver{nameserver})
+		assert.Len(t, results, len(DefaultCases))
+
+		for _, cr := range results {
+			assert.Empty(t, cr.Errors, "%s %s: expected no errors", proto, cr.Name)
+
+			if lenient[strings.TrimPrefix(cr.Name, "Survives malformed packets: ")] {
+				if assert.Len(t, cr.Failures, 1, "%s %s: expected a failure", proto, cr.Name) {
+					assert.Equal(t, nameserver, cr.Failures[0].Nameserver)
+				}
+			} else {
+				assert.True(t, cr.Success(), "%s %s: expected success, got %v", proto, cr.Name, cr.Failures)
+			}
+		}
+	}
+}
+
+func TestRunUnresponsive(t *testing.T) {
+	// a "nameserver" that reads a single packet and then goes away.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		pc.ReadFrom(make([]byte, dns.MaxMsgSize))
+		pc.Close()
+	}()
+
+	_, port, _ := net.SplitHostPort(pc.LocalAddr().String())
+	nameserver := okaydns.Nameserver{Hostname: "127.0.0.1", IP: "127.0.0.1", Port: port, Proto: okaydns.ProtoUDP}
+
+	runner := Runner{Cases: DefaultCases[:1], Timeout: testTimeout}
+	results := runner.Run("example.com.", []okaydns.Nameserver{nameserver})
+	if assert.Len(t, results, 1) {
+		assert.False(t, results[0].Success(), "expected a nameserver that stops answering to fail")
+	}
+}
+
+func TestRunLostLivenessQuery(t *testing.T) {
+	// a nameserver that drops the first well-formed query it gets after a
+	// malformed packet, like a lossy network would.
+	var mu sync.Mutex
+	dropped := false
+	server := okaytest.StartHandler(t, dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		if len(r.Question) != 1 {
+			w.WriteMsg(new(dns.Msg).SetRcodeFormatError(r))
+			return
+		}
+		mu.Lock()
+		drop := r.Question[0].Qtype == dns.TypeSOA && !dropped
+		dropped = dropped || drop
+		mu.Unlock()
+		if drop {
+			return
+		}
+		w.WriteMsg(new(dns.Msg).SetReply(r))
+	}))
+
+	runner := Runner{Cases: DefaultCases[:1], Timeout: testTimeout}
+	results := runner.Run("example.com.", []okaydns.Nameserver{server.Nameserver(okaydns.ProtoUDP)})
+	if assert.Len(t, results, 1) {
+		assert.True(t, results[0].Success(), "expected a lost liveness query to be retried, got %v", results[0].Failures)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.True(t, dropped, "expected a liveness query to be dropped")
+}
+
+func TestValidateReply(t *testing.T) {
+	q := new(dns.Msg).SetQuestion("example.com.", dns.TypeA)
+	pack := func(m *dns.Msg) []byte {
+		bs, err := m.Pack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bs
+	}
+
+	formerr := pack(new(dns.Msg).SetRcodeFormatError(q))
+	assert.Nil(t, validateReply(formerr), "FORMERR should be valid")
+	assert.Nil(t, validateReply(append(formerr[:headerSize:headerSize], 0xC0)), "a broken FORMERR should be valid")
+	assert.NotNil(t, validateReply(pack(new(dns.Msg).SetReply(q))), "NOERROR should be invalid")
+	assert.NotNil(t, validateReply(pack(new(dns.Msg).SetRcode(q, dns.RcodeServerFailure))), "SERVFAIL should be invalid")
+	assert.NotNil(t, validateReply([]byte{0xFF}), "garbage should be invalid")
+}
+
+func TestWithQName(t *testing.T) {
+	query, err := new(dns.Msg).SetQuestion("example.com.", dns.TypeA).Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet := withQName(query, []byte{3, 'f', 'o', 'o', 0})
+	m := new(dns.Msg)
+	if assert.NoError(t, m.Unpack(packet)) {
+		assert.Equal(t, dns.Question{Name: "foo.", Qtype: dns.TypeA, Qclass: dns.ClassINET}, m.Question[0])
+	}
+}
+
+func testServer(t *testing.T) *okaytest.Server {
+	return okaytest.StartHandler(t, dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		if len(r.Question) != 1 {
+			w.WriteMsg(new(dns.Msg).SetRcodeFormatError(r))
+			return
+		}
+		reply := new(dns.Msg).SetReply(r)
+		reply.Authoritative = true
+		w.WriteMsg(reply)
+	}))
+}
@@ -1,7 +1,11 @@
 package okaydns
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/miekg/dns"
+	"github.com/pkg/errors"
 )
 
 // A RequestResponseValidator is a function that checks the answers returned by
@@ -11,49 +15,408 @@ import (
 // nameservers and failures caused by considering the resopnses as a group.
 type RequestResponseValidator func(*dns.Msg, map[Nameserver]*dns.Msg) []Failure
 
+// An ExchangeValidator is a function that checks the record of every exchange
+// with a set of nameservers, given the original DNS request message as context.
+// Unlike a RequestResponseValidator, an ExchangeValidator also sees nameservers
+// that returned an error, and the timing and size of every reply.
+//
+// When a Check builds a different question for every nameserver, the question
+// passed to an ExchangeValidator is nil, and each nameserver's question is the
+// Query of its Exchange.
+type ExchangeValidator func(*dns.Msg, map[Nameserver]*Exchange) []Failure
+
+// AdaptValidator turns a RequestResponseValidator into an ExchangeValidator.
+// The RequestResponseValidator only sees the exchanges that got a reply.
+//
+// If there's no shared question, the RequestResponseValidator is run once for
+// every nameserver with that nameserver's question and reply.
+func AdaptValidator(v RequestResponseValidator) ExchangeValidator {
+	return func(q *dns.Msg, exchanges map[Nameserver]*Exchange) (failures []Failure) {
+		forEachQuestion(q, exchanges, func(q *dns.Msg, replies map[Nameserver]*dns.Msg) {
+			failures = append(failures, v(q, replies)...)
+		})
+		return failures
+	}
+}
+
+// forEachQuestion calls f with every successful reply in exchanges, grouped by
+// question. If q is non-nil, f is called once with q and every reply.
+// Otherwise f is called once for every nameserver with its own question.
+func forEachQuestion(q *dns.Msg, exchanges map[Nameserver]*Exchange, f func(*dns.Msg, map[Nameserver]*dns.Msg)) {
+	if q != nil {
+		replies := make(map[Nameserver]*dns.Msg, len(exchanges))
+		for nameserver, exchange := range exchanges {
+			if exchange.Err == nil {
+				replies[nameserver] = exchange.Reply
+			}
+		}
+		f(q, replies)
+		return
+	}
+
+	for nameserver, exchange := range exchanges {
+		if exchange.Err == nil {
+			f(exchange.Query, map[Nameserver]*dns.Msg{nameserver: exchange.Reply})
+		}
+	}
+}
+
 // A MessageValidator is a function that examines a single DNS message and
 // returns any problems it's configured to spot.
 type MessageValidator func(*dns.Msg) []Failure
 
+// An Observer is a function that records facts about the answers returned by a
+// set of nameservers that aren't a pass or a fail, like the size of a response
+// or the software version a nameserver reports.
+type Observer func(*dns.Msg, map[Nameserver]*dns.Msg) []Observation
+
+// An ExchangeObserver is an Observer that sees the record of every exchange
+// with a set of nameservers, like an ExchangeValidator does. Use an
+// ExchangeObserver to report facts about what was sent over the wire, like the
+// size of a reply.
+type ExchangeObserver func(*dns.Msg, map[Nameserver]*Exchange) []Observation
+
 // A Check is a check to run. Checks are responsible for building their own
 // DNS Request from an FQDN and validating the response.
 //
 // Checks may optionally alter the list of Nameservers that the check will be
-// performed on.
+// performed on, and may optionally include Observers that report on the
+// responses.
+//
+// Validators are run before ExchangeValidators, and Observers are run before
+// ExchangeObservers. Queries that time out are
+// retried until they've been sent Attempts times. A Check with zero Attempts
+// sends every query once.
+//
+// A Check may build a different question for every nameserver with
+// NameserverQuestion instead of Question. When it does, RequestResponseValidators
+// and Observers are run once for every nameserver with that nameserver's
+// question, so they can't compare answers across nameservers. Use an
+// ExchangeValidator to compare answers to different questions.
+//
+// A Check may have a short, stable ID that identifies it in configuration and
+// output. Checks without an ID are identified by their Name.
+//
+// A Check may require other checks to pass before it runs by listing their
+// IDs in Requires. Prerequisites are only enforced by DoChecks.
+//
+// A Check may also have a sequence of Steps that run after its question, where
+// each step asks questions built from the answers to the previous step. The
+// validators and observers of a Check only apply to its question. A multi-step
+// Check may leave both Question and NameserverQuestion nil.
+//
+// Exchange errors fail a Check on their own. A Check that sets TolerateErrors
+// still records them, but leaves deciding whether they matter to its
+// ExchangeValidators, like okaycheck.AtLeast, that count a nameserver that
+// didn't reply as a failed nameserver.
 type Check struct {
+	ID                   string
 	Name                 string
 	ConfigureNameservers func(nameservers []Nameserver) []Nameserver
 	Question             func(fqdn string) *dns.Msg
+	NameserverQuestion   func(fqdn string, nameserver Nameserver) *dns.Msg
 	Validators           []RequestResponseValidator
+	ExchangeValidators   []ExchangeValidator
+	Observers            []Observer
+	ExchangeObservers    []ExchangeObserver
+	Attempts             int
+	Steps                []Step
+	Requires             []string
+	TolerateErrors       bool
+}
+
+// Identifier returns a Check's ID, or its Name if it doesn't have an ID.
+func (c *Check) Identifier() string {
+	if c.ID != "" {
+		return c.ID
+	}
+	return c.Name
+}
+
+// validators returns all of a Check's validators as ExchangeValidators.
+func (c *Check) validators() []ExchangeValidator {
+	validators := make([]ExchangeValidator, 0, len(c.Validators)+len(c.ExchangeValidators))
+	for _, v := range c.Validators {
+		validators = append(validators, AdaptValidator(v))
+	}
+	return append(validators, c.ExchangeValidators...)
+}
+
+// A Step is one step of a multi-step Check. Every step builds any number of
+// questions from the results of the previous step, and every question is sent
+// to every nameserver. The previous results passed to the first step of a
+// Check are the result of the Check's question, if it has one.
+//
+// Validators and ExchangeValidators run once for each question. StepValidators
+// run once for the whole step, and can compare the answers to this step with
+// the answers to the previous step.
+type Step struct {
+	Name string
+
+	// Questions builds the questions for this step. A step with no questions
+	// is skipped.
+	Questions func(fqdn string, previous []*CheckResult) []*dns.Msg
+
+	// Nameservers optionally changes the nameservers that this step's
+	// questions are sent to. By default, steps use the same nameservers as
+	// their Check.
+	Nameservers func(nameservers []Nameserver, previous []*CheckResult) []Nameserver
+
+	Validators         []RequestResponseValidator
+	ExchangeValidators []ExchangeValidator
+	StepValidators     []StepValidator
+}
+
+// validators returns all of a Step's per-question validators as
+// ExchangeValidators.
+func (s *Step) validators() []ExchangeValidator {
+	validators := make([]ExchangeValidator, 0, len(s.Validators)+len(s.ExchangeValidators))
+	for _, v := range s.Validators {
+		validators = append(validators, AdaptValidator(v))
+	}
+	return append(validators, s.ExchangeValidators...)
+}
+
+// A StepValidator checks the results of every question in one Step of a
+// multi-step Check, given the results of the step before it.
+type StepValidator func(previous, results []*CheckResult) []Failure
+
+// A StepResult is the result of running a single Step. It has a CheckResult for
+// every question the step asked.
+type StepResult struct {
+	Name    string
+	Results []*CheckResult
+}
+
+// A CheckGenerator builds a Check for a single question. CheckGenerators are
+// used to fan one kind of check out over many names and types, for example
+// every RRset in a zone.
+type CheckGenerator func(q dns.Question) Check
+
+// Generate builds a Check for every question using the given CheckGenerator.
+func Generate(generator CheckGenerator, questions []dns.Question) []Check {
+	checks := make([]Check, len(questions))
+	for i, q := range questions {
+		checks[i] = generator(q)
+	}
+	return checks
 }
 
 // A CheckResult is the result of running a CheckConfig. It includes the name
 // of the check that was run, the nameservers it was run on, the complete dns
 // request and response for every nameserver.
 //
+// Questions records the question sent to every nameserver. Exchanges records
+// every exchange with a nameserver. Answers and Errors are the replies and
+// errors from those exchanges.
+//
+// The results of a multi-step Check are recorded in Steps. Failures from
+// StepValidators are included in the Failures of the Check, and failures and
+// errors from any step count towards its Success.
+//
+// A check that wasn't run because one of its prerequisites failed is Skipped,
+// with the reason in SkipReason. A check that only ran on the nameservers that
+// passed its prerequisites records the rest in SkippedNameservers, with the
+// reason each one was skipped.
+//
 // Failures are returned per-nameserver and also as a general, global failure.
+// Observations never affect the success of a check. Errors don't either if
+// TolerateErrors is set, which it is for the results of a Check that sets it.
 type CheckResult struct {
-	Name        string
-	Nameservers []Nameserver
-	Question    *dns.Msg
-	Answers     map[Nameserver]*dns.Msg
-	Errors      map[Nameserver]error
-	Failures    []Failure
+	Name         string
+	Nameservers  []Nameserver
+	Questions    map[Nameserver]*dns.Msg
+	Exchanges    map[Nameserver]*Exchange
+	Answers      map[Nameserver]*dns.Msg
+	Errors       map[Nameserver]error
+	Failures     []Failure
+	Observations []Observation
+	Steps        []StepResult
+
+	TolerateErrors bool
+
+	Skipped            bool
+	SkipReason         string
+	SkippedNameservers map[Nameserver]string
 }
 
-// Success returns true if the check did not error or fail.
+// A Status summarizes a CheckResult.
+type Status string
+
+// Check statuses.
+const (
+	StatusOK      Status = "ok"
+	StatusWarning Status = "warning"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+)
+
+// Status returns the status of a check. A check that passed with warnings has
+// StatusWarning.
+func (c *CheckResult) Status() Status {
+	if c.Skipped {
+		return StatusSkipped
+	}
+	if !c.Success() {
+		return StatusFailed
+	}
+	if severity, failed := c.Severity(); failed && severity == SeverityWarning {
+		return StatusWarning
+	}
+	return StatusOK
+}
+
+// Success returns true if the check did not error and has no failures with a
+// severity of SeverityError or higher. Informational failures and warnings are
+// reported but don't fail a check. Skipped checks don't fail.
 func (c *CheckResult) Success() bool {
-	return len(c.Failures) == 0 && len(c.Errors) == 0
+	if len(c.Errors) > 0 && !c.TolerateErrors {
+		return false
+	}
+	for _, failure := range c.Failures {
+		if failure.Severity >= SeverityError {
+			return false
+		}
+	}
+	for _, step := range c.Steps {
+		for _, result := range step.Results {
+			if !result.Success() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Severity returns the highest severity of any of a CheckResult's failures.
+// Errors talking to a nameserver are treated as SeverityError, unless they're
+// tolerated. Returns false if the check has no failures or errors.
+func (c *CheckResult) Severity() (Severity, bool) {
+	severity, failed := SeverityInfo, false
+	if len(c.Errors) > 0 && !c.TolerateErrors {
+		severity, failed = SeverityError, true
+	}
+	for _, failure := range c.Failures {
+		if !failed || failure.Severity > severity {
+			severity, failed = failure.Severity, true
+		}
+	}
+	for _, step := range c.Steps {
+		for _, result := range step.Results {
+			if s, ok := result.Severity(); ok && (!failed || s > severity) {
+				severity, failed = s, true
+			}
+		}
+	}
+	if !failed {
+		return 0, false
+	}
+	return severity, true
 }
 
 // A Failure is a reason that a check fails. They optionally include the
 // nameserver that a failure is associated with. If a failure isn't associated
 // with a specific Nameserver it can be assumed to be applied to the group as
 // a whole.
+//
+// Every failure has a Severity. Failures that don't set one are errors. A
+// failure may also set a stable, machine-readable Code that can be used to
+// suppress or alert on a specific problem without matching on its Message,
+// and a Reference to the part of an RFC that describes the correct behavior.
 type Failure struct {
 	// Message is a string explaining this failure.
 	Message string
 
 	// Nameserver is the (optional) Nameserver that failed this check.
 	Nameserver Nameserver
+
+	// Severity is how bad this failure is. The zero value is SeverityError.
+	Severity Severity
+
+	// Code is an (optional) short, stable identifier for this kind of failure,
+	// like "not-authoritative".
+	Code string
+
+	// Reference is an (optional) link to the RFC section that describes the
+	// behavior this failure violates.
+	Reference string
+}
+
+// A Severity describes how bad a Failure is. Severities are ordered, so that
+// SeverityInfo < SeverityWarning < SeverityError < SeverityCritical.
+//
+// The zero value of a Severity is SeverityError, so that Failures that don't
+// specify a severity fail a check.
+type Severity int
+
+// Failure severities.
+const (
+	// SeverityInfo failures are worth knowing about but aren't problems.
+	SeverityInfo Severity = iota - 2
+
+	// SeverityWarning failures are problems that don't break resolution, like
+	// a violated SHOULD or an inconsistency that's likely to be temporary.
+	SeverityWarning
+
+	// SeverityError failures break resolution for some clients.
+	SeverityError
+
+	// SeverityCritical failures break resolution for most clients, or put the
+	// nameserver or its users at risk.
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityInfo:     "info",
+	SeverityWarning:  "warning",
+	SeverityError:    "error",
+	SeverityCritical: "critical",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// ParseSeverity parses the name of a Severity. Names are case-insensitive.
+func ParseSeverity(name string) (Severity, error) {
+	for severity, severityName := range severityNames {
+		if strings.EqualFold(name, severityName) {
+			return severity, nil
+		}
+	}
+	return 0, errors.Errorf("unknown severity: %q", name)
+}
+
+// MarshalText encodes a Severity as its name.
+func (s Severity) MarshalText() ([]byte, error) {
+	if _, ok := severityNames[s]; !ok {
+		return nil, errors.Errorf("unknown severity: %d", int(s))
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a Severity from its name.
+func (s *Severity) UnmarshalText(text []byte) error {
+	severity, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = severity
+	return nil
+}
+
+// An Observation is a named value recorded about a check. Like a Failure, an
+// Observation optionally includes the nameserver it's associated with.
+type Observation struct {
+	// Name is a short, machine-friendly name for the observed value.
+	Name string
+
+	// Value is the observed value.
+	Value string
+
+	// Nameserver is the (optional) Nameserver this observation was made about.
+	Nameserver Nameserver
 }
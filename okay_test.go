@@ -0,0 +1,92 @@
+package okaydns
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckResultSuccess(t *testing.T) {
+	ns := Nameserver{Hostname: "ns1.example.com.", IP: "192.0.2.1", Port: "53"}
+
+	tcs := []struct {
+		name     string
+		result   CheckResult
+		success  bool
+		severity Severity
+		failed   bool
+	}{
+		{
+			name:    "empty",
+			success: true,
+		},
+		{
+			name:     "info",
+			result:   CheckResult{Failures: []Failure{{Message: "fyi", Severity: SeverityInfo}}},
+			success:  true,
+			severity: SeverityInfo,
+			failed:   true,
+		},
+		{
+			name:     "warning",
+			result:   CheckResult{Failures: []Failure{{Severity: SeverityInfo}, {Severity: SeverityWarning}}},
+			success:  true,
+			severity: SeverityWarning,
+			failed:   true,
+		},
+		{
+			name:     "unset severity is an error",
+			result:   CheckResult{Failures: []Failure{{Message: "broken"}}},
+			success:  false,
+			severity: SeverityError,
+			failed:   true,
+		},
+		{
+			name:     "critical",
+			result:   CheckResult{Failures: []Failure{{Severity: SeverityWarning}, {Severity: SeverityCritical}}},
+			success:  false,
+			severity: SeverityCritical,
+			failed:   true,
+		},
+		{
+			name:     "errors",
+			result:   CheckResult{Errors: map[Nameserver]error{ns: errors.New("timeout")}},
+			success:  false,
+			severity: SeverityError,
+			failed:   true,
+		},
+	}
+
+	for _, tc := range tcs {
+		assert.Equal(t, tc.success, tc.result.Success(), tc.name)
+
+		severity, failed := tc.result.Severity()
+		assert.Equal(t, tc.failed, failed, tc.name)
+		assert.Equal(t, tc.severity, severity, tc.name)
+	}
+}
+
+func TestSeverityText(t *testing.T) {
+	for _, severity := range []Severity{SeverityInfo, SeverityWarning, SeverityError, SeverityCritical} {
+		bs, err := json.Marshal(severity)
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		var decoded Severity
+		assert.NoError(t, json.Unmarshal(bs, &decoded))
+		assert.Equal(t, severity, decoded)
+	}
+
+	severity, err := ParseSeverity("WARNING")
+	assert.NoError(t, err)
+	assert.Equal(t, SeverityWarning, severity)
+
+	_, err = ParseSeverity("catastrophic")
+	assert.Error(t, err)
+
+	_, err = json.Marshal(Severity(12))
+	assert.Error(t, err)
+}
@@ -0,0 +1,161 @@
+package okaycheck
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+)
+
+// Codes for the failures returned by the combinators in this package.
+const (
+	CodeNoAlternative = "no-alternative-passed"
+	CodeNegation      = "negated-validator-passed"
+	CodeQuorum        = "quorum-not-met"
+)
+
+// AllOf builds a MessageValidator that runs every one of vs and returns all of
+// their failures.
+func AllOf(vs ...okaydns.MessageValidator) okaydns.MessageValidator {
+	return func(m *dns.Msg) (failures []okaydns.Failure) {
+		for _, v := range vs {
+			failures = append(failures, v(m)...)
+		}
+		return failures
+	}
+}
+
+// AnyOf builds a MessageValidator that passes if any one of vs passes. An
+// alternative passes if it returns no failures at all.
+//
+// If every alternative fails, AnyOf returns a single failure that describes
+// every alternative's failures. Its severity is the lowest of the worst
+// severities of each alternative, since passing any alternative would have
+// been enough.
+func AnyOf(vs ...okaydns.MessageValidator) okaydns.MessageValidator {
+	return func(m *dns.Msg) []okaydns.Failure {
+		if len(vs) == 0 {
+			return nil
+		}
+
+		var (
+			messages []string
+			severity = okaydns.SeverityCritical
+		)
+		for _, v := range vs {
+			failures := v(m)
+			if len(failures) == 0 {
+				return nil
+			}
+
+			worst := failures[0].Severity
+			alternative := make([]string, len(failures))
+			for i, failure := range failures {
+				alternative[i] = failure.Message
+				if failure.Severity > worst {
+					worst = failure.Severity
+				}
+			}
+			if worst < severity {
+				severity = worst
+			}
+			messages = append(messages, "("+strings.Join(alternative, "; ")+")")
+		}
+
+		return []okaydns.Failure{{
+			Message:  "none of the alternatives passed: " + strings.Join(messages, " or "),
+			Severity: severity,
+			Code:     CodeNoAlternative,
+		}}
+	}
+}
+
+// Not builds a MessageValidator that fails with the given message if v passes,
+// and passes if v returns any failures.
+//
+//	okaycheck.Not(okaycheck.AnswerIsEmpty, "answer is empty")
+func Not(v okaydns.MessageValidator, message string) okaydns.MessageValidator {
+	return func(m *dns.Msg) []okaydns.Failure {
+		if len(v(m)) > 0 {
+			return nil
+		}
+		return []okaydns.Failure{{Message: message, Code: CodeNegation}}
+	}
+}
+
+// AtLeast builds an ExchangeValidator that requires at least n nameservers to
+// pass every one of vs. A nameserver passes if it replied and none of its
+// failures are errors or worse. A nameserver that didn't reply fails with a
+// CodeNoReply failure.
+//
+// When enough nameservers pass, the failures of the nameservers that didn't
+// are still returned, but as warnings. This makes it possible to tolerate a
+// misbehaving nameserver, during maintenance for example, without hiding it.
+// When too few nameservers pass, their failures are returned as-is along with
+// a failure that describes the quorum.
+//
+// Exchange errors fail a Check on their own, so a Check that uses AtLeast to
+// tolerate a nameserver that's down should also set TolerateErrors.
+func AtLeast(n int, vs ...okaydns.MessageValidator) okaydns.ExchangeValidator {
+	return quorum(func(int) int { return n }, vs)
+}
+
+// Majority builds an ExchangeValidator that requires more than half of the
+// nameservers that were queried to pass every one of vs, whether or not they
+// replied. See AtLeast for how failures are reported.
+func Majority(vs ...okaydns.MessageValidator) okaydns.ExchangeValidator {
+	return quorum(func(total int) int { return total/2 + 1 }, vs)
+}
+
+func quorum(required func(total int) int, vs []okaydns.MessageValidator) okaydns.ExchangeValidator {
+	validate := AllOf(vs...)
+
+	return func(_ *dns.Msg, exchanges map[okaydns.Nameserver]*okaydns.Exchange) []okaydns.Failure {
+		var (
+			passed   int
+			failures []okaydns.Failure
+			failed   []string
+		)
+		for nameserver, exchange := range exchanges {
+			if exchange.Err != nil {
+				failures = append(failures, noReply(nameserver, exchange))
+				failed = append(failed, nameserver.Hostname)
+				continue
+			}
+
+			nsFailures := validate(exchange.Reply)
+			ok := true
+			for _, failure := range nsFailures {
+				failure.Nameserver = nameserver
+				failures = append(failures, failure)
+				if failure.Severity >= okaydns.SeverityError {
+					ok = false
+				}
+			}
+
+			if ok {
+				passed++
+			} else {
+				failed = append(failed, nameserver.Hostname)
+			}
+		}
+
+		need := required(len(exchanges))
+		if passed >= need {
+			for i := range failures {
+				if failures[i].Severity > okaydns.SeverityWarning {
+					failures[i].Severity = okaydns.SeverityWarning
+				}
+			}
+			return failures
+		}
+
+		sort.Strings(failed)
+		return append(failures, okaydns.Failure{
+			Message: fmt.Sprintf("%d of %d nameservers passed but %d must pass (failed: %s)", passed, len(exchanges), need, strings.Join(failed, ", ")),
+			Code:    CodeQuorum,
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package okaycheck
+
+import (
+	"testing"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func warn(m *dns.Msg) []okaydns.Failure {
+	return []okaydns.Failure{{Message: "warn", Severity: okaydns.SeverityWarning}}
+}
+
+func fail(m *dns.Msg) []okaydns.Failure {
+	return []okaydns.Failure{{Message: "fail"}}
+}
+
+func pass(m *dns.Msg) []okaydns.Failure {
+	return nil
+}
+
+func TestLogicalCombinators(t *testing.T) {
+	m := new(dns.Msg)
+
+	assert.Empty(t, AllOf()(m))
+	assert.Empty(t, AllOf(pass, pass)(m))
+	assert.Len(t, AllOf(fail, pass, warn)(m), 2)
+
+	assert.Empty(t, AnyOf()(m))
+	assert.Empty(t, AnyOf(fail, pass)(m))
+	if failures := AnyOf(fail, AllOf(warn, warn))(m); assert.Len(t, failures, 1) {
+		assert.Equal(t, CodeNoAlternative, failures[0].Code)
+		assert.Equal(t, "none of the alternatives passed: (fail) or (warn; warn)", failures[0].Message)
+		assert.Equal(t, okaydns.SeverityWarning, failures[0].Severity, "failing AnyOf should have the least severe alternative's severity")
+	}
+
+	assert.Empty(t, Not(fail, "passed")(m))
+	if failures := Not(pass, "passed")(m); assert.Len(t, failures, 1) {
+		assert.Equal(t, "passed", failures[0].Message)
+		assert.Equal(t, CodeNegation, failures[0].Code)
+	}
+}
+
+func TestQuorum(t *testing.T) {
+	ok := new(dns.Msg)
+	ok.Rcode = dns.RcodeSuccess
+	servfail := new(dns.Msg)
+	servfail.Rcode = dns.RcodeServerFailure
+	timeout := &okaydns.ExchangeError{Kind: okaydns.ErrorTimeout}
+
+	// exchanges builds exchanges with n nameservers, where the first failing
+	// nameservers reply with SERVFAIL and the next down don't reply at all.
+	exchanges := func(n, failing, down int) map[okaydns.Nameserver]*okaydns.Exchange {
+		exchanges := make(map[okaydns.Nameserver]*okaydns.Exchange)
+		for i := 0; i < n; i++ {
+			ns := okaydns.Nameserver{Hostname: string(rune('a'+i)) + ".example.com.", IP: "192.0.2.1", Port: string(rune('0' + i))}
+			switch {
+			case i < failing:
+				exchanges[ns] = &okaydns.Exchange{Reply: servfail, Attempts: 1}
+			case i < failing+down:
+				exchanges[ns] = &okaydns.Exchange{Err: timeout, Attempts: 2}
+			default:
+				exchanges[ns] = &okaydns.Exchange{Reply: ok, Attempts: 1}
+			}
+		}
+		return exchanges
+	}
+
+	tcs := []struct {
+		name      string
+		validator okaydns.ExchangeValidator
+		exchanges map[okaydns.Nameserver]*okaydns.Exchange
+		warnings  int
+		errors    int
+		quorum    bool
+	}{
+		{"all pass", AtLeast(2, ResponseCode(dns.RcodeSuccess)), exchanges(3, 0, 0), 0, 0, false},
+		{"tolerates one", AtLeast(2, ResponseCode(dns.RcodeSuccess)), exchanges(3, 1, 0), 1, 0, false},
+		{"tolerates one down", AtLeast(2, ResponseCode(dns.RcodeSuccess)), exchanges(3, 0, 1), 1, 0, false},
+		{"too many fail", AtLeast(2, ResponseCode(dns.RcodeSuccess)), exchanges(3, 2, 0), 0, 2, true},
+		{"too many down", AtLeast(2, ResponseCode(dns.RcodeSuccess)), exchanges(3, 1, 1), 0, 2, true},
+		{"too few nameservers", AtLeast(4, ResponseCode(dns.RcodeSuccess)), exchanges(3, 0, 0), 0, 0, true},
+		{"warnings pass", AtLeast(3, warn), exchanges(3, 0, 0), 3, 0, false},
+		{"majority of three", Majority(ResponseCode(dns.RcodeSuccess)), exchanges(3, 1, 0), 1, 0, false},
+		{"majority of three with one down", Majority(ResponseCode(dns.RcodeSuccess)), exchanges(3, 0, 1), 1, 0, false},
+		{"no majority of four", Majority(ResponseCode(dns.RcodeSuccess)), exchanges(4, 2, 0), 0, 2, true},
+		{"no majority of four with two down", Majority(ResponseCode(dns.RcodeSuccess)), exchanges(4, 0, 2), 0, 2, true},
+	}
+
+	for _, tc := range tcs {
+		var warnings, errors int
+		quorum := false
+		for _, failure := range tc.validator(nil, tc.exchanges) {
+			switch {
+			case failure.Code == CodeQuorum:
+				quorum = true
+			case failure.Severity == okaydns.SeverityWarning:
+				warnings++
+				assert.False(t, failure.Nameserver.IsZero(), tc.name)
+			default:
+				errors++
+				assert.False(t, failure.Nameserver.IsZero(), tc.name)
+			}
+		}
+		assert.Equal(t, tc.warnings, warnings, "%s: warnings", tc.name)
+		assert.Equal(t, tc.errors, errors, "%s: errors", tc.name)
+		assert.Equal(t, tc.quorum, quorum, "%s: quorum failure", tc.name)
+	}
+}
@@ -0,0 +1,180 @@
+package okaycheck
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+)
+
+// Codes for the failures returned by the consistency validators in this
+// package.
+const (
+	CodeInconsistentAnswer = "inconsistent-answer"
+	CodeSerialMismatch     = "serial-mismatch"
+)
+
+// A Consistency compares the records in a section of every nameserver's reply
+// and groups together the nameservers that gave the same records.
+//
+// Records are compared as a set, in their canonical form: they're sorted, owner
+// names and the names in record data are lowercased, and TTLs are ignored. If
+// there are any Filters, only the records selected by every filter are
+// compared. If Key is set, records are compared by the key it returns instead,
+// so that only part of a record has to be the same everywhere.
+//
+// Failures have CodeInconsistentAnswer and SeverityError unless Code or
+// Severity are set.
+type Consistency struct {
+	Section  Section
+	Filters  []RecordFilter
+	Key      func(dns.RR) string
+	Code     string
+	Severity okaydns.Severity
+}
+
+// A Group is a set of nameservers that gave the same records.
+type Group struct {
+	Nameservers []okaydns.Nameserver
+	Records     []dns.RR
+
+	keys  []string
+	byKey map[string]dns.RR
+}
+
+// Groups returns the nameservers in replies grouped by the records they gave.
+// Groups are ordered from largest to smallest, and nameservers in a group are
+// ordered by hostname and address. Records in a group are in the order the
+// first nameserver in the group returned them, with duplicates removed.
+func (c Consistency) Groups(replies map[okaydns.Nameserver]*dns.Msg) []Group {
+	key := c.Key
+	if key == nil {
+		key = Canonical
+	}
+
+	nameservers := make([]okaydns.Nameserver, 0, len(replies))
+	for nameserver := range replies {
+		nameservers = append(nameservers, nameserver)
+	}
+	sort.Slice(nameservers, func(i, j int) bool {
+		if nameservers[i].Hostname != nameservers[j].Hostname {
+			return nameservers[i].Hostname < nameservers[j].Hostname
+		}
+		return nameservers[i].String() < nameservers[j].String()
+	})
+
+	var groups []*Group
+	bySet := make(map[string]*Group)
+	for _, nameserver := range nameservers {
+		var (
+			records []dns.RR
+			keys    []string
+			byKey   = make(map[string]dns.RR)
+		)
+		for _, rr := range selectRecords(c.Section.Records(replies[nameserver]), c.Filters) {
+			if k := key(rr); byKey[k] == nil {
+				byKey[k] = rr
+				records = append(records, rr)
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		set := strings.Join(keys, "\n")
+		group, ok := bySet[set]
+		if !ok {
+			group = &Group{Records: records, keys: keys, byKey: byKey}
+			bySet[set] = group
+			groups = append(groups, group)
+		}
+		group.Nameservers = append(group.Nameservers, nameserver)
+	}
+
+	// stable, so that groups of the same size stay ordered by their first
+	// nameserver
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].Nameservers) > len(groups[j].Nameservers)
+	})
+
+	sorted := make([]Group, len(groups))
+	for i, group := range groups {
+		sorted[i] = *group
+	}
+	return sorted
+}
+
+// Validator builds a RequestResponseValidator that asserts every nameserver
+// gave the same records. Any difference is reported as a single failure that
+// lists every group of nameservers and their records. Every group but the
+// largest also has a diff against the largest group, with the records it's
+// missing prefixed with "-" and the records it has in addition prefixed with
+// "+".
+func (c Consistency) Validator() okaydns.RequestResponseValidator {
+	code := c.Code
+	if code == "" {
+		code = CodeInconsistentAnswer
+	}
+
+	return func(_ *dns.Msg, replies map[okaydns.Nameserver]*dns.Msg) []okaydns.Failure {
+		groups := c.Groups(replies)
+		if len(groups) <= 1 {
+			return nil
+		}
+
+		var bs bytes.Buffer
+		fmt.Fprintf(&bs, "nameservers gave %d different %s sections:", len(groups), c.Section)
+		for i, group := range groups {
+			names := make([]string, len(group.Nameservers))
+			for i, nameserver := range group.Nameservers {
+				names[i] = nameserver.Hostname + " (" + nameserver.String() + ")"
+			}
+			records := make([]string, len(group.Records))
+			for i, rr := range group.Records {
+				records[i] = rr.String()
+			}
+			if len(records) == 0 {
+				records = []string{"no records"}
+			}
+			fmt.Fprintf(&bs, "\n\t%s: %s", strings.Join(names, ", "), strings.Join(records, "; "))
+
+			if i > 0 {
+				groups[0].diff(&bs, group)
+			}
+		}
+
+		return []okaydns.Failure{{
+			Message:  bs.String(),
+			Code:     code,
+			Severity: c.Severity,
+		}}
+	}
+}
+
+// diff writes a line for every record in g that's missing from other, prefixed
+// with "-", and a line for every record in other that isn't in g, prefixed with
+// "+".
+func (g Group) diff(bs *bytes.Buffer, other Group) {
+	for _, key := range g.keys {
+		if _, ok := other.byKey[key]; !ok {
+			fmt.Fprintf(bs, "\n\t\t- %s", g.byKey[key])
+		}
+	}
+	for _, key := range other.keys {
+		if _, ok := g.byKey[key]; !ok {
+			fmt.Fprintf(bs, "\n\t\t+ %s", other.byKey[key])
+		}
+	}
+}
+
+// SameAnswer builds a RequestResponseValidator that asserts every nameserver
+// replied with the same records in the Answer section. If there are any
+// filters, only the records selected by every filter are compared. See
+// Consistency for how records are compared and differences are reported.
+//
+//	okaycheck.SameAnswer(okaycheck.OfType(dns.TypeNS))
+func SameAnswer(filters ...RecordFilter) okaydns.RequestResponseValidator {
+	return Consistency{Section: AnswerSection, Filters: filters}.Validator()
+}
@@ -0,0 +1,138 @@
+package okaycheck
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSameAnswer(t *testing.T) {
+	ns1 := okaydns.Nameserver{Hostname: "ns1.example.com.", IP: "192.0.2.1", Port: "53"}
+	ns2 := okaydns.Nameserver{Hostname: "ns2.example.com.", IP: "192.0.2.2", Port: "53"}
+	ns3 := okaydns.Nameserver{Hostname: "ns3.example.com.", IP: "192.0.2.3", Port: "53"}
+
+	tcs := []struct {
+		name     string
+		filters  []RecordFilter
+		replies  map[okaydns.Nameserver]*dns.Msg
+		failures int
+	}{
+		{
+			"same records in any order, case and ttl",
+			nil,
+			map[okaydns.Nameserver]*dns.Msg{
+				ns1: answer(t, "example.com. 300 IN NS ns1.example.com.", "example.com. 300 IN NS ns2.example.com."),
+				ns2: answer(t, "example.com. 60 IN NS NS2.example.com.", "EXAMPLE.com. 60 IN NS ns1.example.com."),
+			},
+			0,
+		},
+		{
+			"different records",
+			nil,
+			map[okaydns.Nameserver]*dns.Msg{
+				ns1: answer(t, "example.com. 300 IN A 192.0.2.1"),
+				ns2: answer(t, "example.com. 300 IN A 192.0.2.1"),
+				ns3: answer(t, "example.com. 300 IN A 192.0.2.2"),
+			},
+			1,
+		},
+		{
+			"empty answer",
+			nil,
+			map[okaydns.Nameserver]*dns.Msg{
+				ns1: answer(t, "example.com. 300 IN A 192.0.2.1"),
+				ns2: answer(t),
+			},
+			1,
+		},
+		{
+			"filtered records",
+			[]RecordFilter{OfType(dns.TypeA)},
+			map[okaydns.Nameserver]*dns.Msg{
+				ns1: answer(t, "example.com. 300 IN A 192.0.2.1", "example.com. 300 IN RRSIG A 8 2 300 20300101000000 20200101000000 12345 example.com. AAAA"),
+				ns2: answer(t, "example.com. 300 IN A 192.0.2.1"),
+			},
+			0,
+		},
+	}
+
+	for _, tc := range tcs {
+		failures := SameAnswer(tc.filters...)(nil, tc.replies)
+		if assert.Len(t, failures, tc.failures, tc.name) && tc.failures > 0 {
+			assert.Equal(t, CodeInconsistentAnswer, failures[0].Code, tc.name)
+		}
+	}
+
+	failures := SameAnswer()(nil, map[okaydns.Nameserver]*dns.Msg{
+		ns1: answer(t, "example.com. 300 IN A 192.0.2.1"),
+		ns2: answer(t, "example.com. 300 IN A 192.0.2.1"),
+		ns3: answer(t),
+	})
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, "nameservers gave 2 different answer sections:\n"+
+			"\tns1.example.com. (udp://192.0.2.1:53), ns2.example.com. (udp://192.0.2.2:53): example.com.\t300\tIN\tA\t192.0.2.1\n"+
+			"\tns3.example.com. (udp://192.0.2.3:53): no records\n"+
+			"\t\t- example.com.\t300\tIN\tA\t192.0.2.1", failures[0].Message)
+		assert.Equal(t, okaydns.SeverityError, failures[0].Severity)
+	}
+}
+
+func TestConsistencyGroups(t *testing.T) {
+	ns1 := okaydns.Nameserver{Hostname: "ns1.example.com.", IP: "192.0.2.1", Port: "53"}
+	ns2 := okaydns.Nameserver{Hostname: "ns2.example.com.", IP: "192.0.2.2", Port: "53"}
+	ns3 := okaydns.Nameserver{Hostname: "ns3.example.com.", IP: "192.0.2.3", Port: "53"}
+	ns3v6 := okaydns.Nameserver{Hostname: "ns3.example.com.", IP: "2001:db8::3", Port: "53"}
+
+	replies := map[okaydns.Nameserver]*dns.Msg{
+		ns1:   answer(t, "example.com. 300 IN MX 10 mail.example.com.", "example.com. 300 IN MX 20 backup.example.com."),
+		ns2:   answer(t, "example.com. 300 IN MX 10 mail.example.com."),
+		ns3:   answer(t, "example.com. 60 IN MX 20 BACKUP.example.com.", "example.com. 60 IN MX 10 mail.example.com.", "example.com. 60 IN MX 10 mail.example.com."),
+		ns3v6: answer(t, "example.com. 300 IN MX 10 mail.example.com.", "example.com. 300 IN MX 20 backup.example.com."),
+	}
+
+	groups := Consistency{Section: AnswerSection}.Groups(replies)
+	if assert.Len(t, groups, 2) {
+		assert.Equal(t, []okaydns.Nameserver{ns1, ns3, ns3v6}, groups[0].Nameservers)
+		assert.Len(t, groups[0].Records, 2, "duplicate records should be removed")
+		assert.Equal(t, []okaydns.Nameserver{ns2}, groups[1].Nameservers)
+		assert.Len(t, groups[1].Records, 1)
+	}
+
+	failures := Consistency{Section: AnswerSection, Code: "mx-mismatch", Severity: okaydns.SeverityWarning}.Validator()(nil, replies)
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, "mx-mismatch", failures[0].Code)
+		assert.Equal(t, okaydns.SeverityWarning, failures[0].Severity)
+		assert.Contains(t, failures[0].Message, "\n\tns2.example.com. (udp://192.0.2.2:53): example.com.\t300\tIN\tMX\t10 mail.example.com.\n"+
+			"\t\t- example.com.\t300\tIN\tMX\t20 backup.example.com.")
+	}
+}
+
+func TestConsistencyKey(t *testing.T) {
+	ns1 := okaydns.Nameserver{Hostname: "ns1.example.com.", IP: "192.0.2.1", Port: "53"}
+	ns2 := okaydns.Nameserver{Hostname: "ns2.example.com.", IP: "192.0.2.2", Port: "53"}
+
+	serials := Consistency{
+		Section: AnswerSection,
+		Filters: []RecordFilter{OfType(dns.TypeSOA)},
+		Key: func(rr dns.RR) string {
+			return fmt.Sprint(rr.(*dns.SOA).Serial)
+		},
+	}.Validator()
+
+	assert.Empty(t, serials(nil, map[okaydns.Nameserver]*dns.Msg{
+		ns1: answer(t, "example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 2020010101 3600 600 86400 300"),
+		ns2: answer(t, "example.com. 300 IN SOA ns2.example.com. hostmaster.example.com. 2020010101 3600 600 86400 300"),
+	}), "only serials should be compared")
+
+	failures := serials(nil, map[okaydns.Nameserver]*dns.Msg{
+		ns1: answer(t, "example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 2020010101 3600 600 86400 300"),
+		ns2: answer(t, "example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 2020010102 3600 600 86400 300"),
+	})
+	if assert.Len(t, failures, 1) {
+		assert.Contains(t, failures[0].Message, "\t\t- example.com.\t300\tIN\tSOA\tns1.example.com. hostmaster.example.com. 2020010101")
+		assert.Contains(t, failures[0].Message, "\t\t+ example.com.\t300\tIN\tSOA\tns1.example.com. hostmaster.example.com. 2020010102")
+	}
+}
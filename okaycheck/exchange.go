@@ -0,0 +1,88 @@
+package okaycheck
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+)
+
+// Codes for the failures returned by the exchange validators in this package.
+const (
+	CodeNoReply      = "no-reply"
+	CodeSlowReply    = "slow-reply"
+	CodeLargeReply   = "large-reply"
+	CodeRetriedReply = "retried-reply"
+)
+
+// EveryNameserverReplies is an ExchangeValidator that fails for every
+// nameserver that didn't send a usable reply. Failures include the kind of
+// error that caused the exchange to fail.
+func EveryNameserverReplies(_ *dns.Msg, exchanges map[okaydns.Nameserver]*okaydns.Exchange) (failures []okaydns.Failure) {
+	for nameserver, exchange := range exchanges {
+		if exchange.Err != nil {
+			failures = append(failures, noReply(nameserver, exchange))
+		}
+	}
+	return failures
+}
+
+// noReply describes an exchange that failed with an error.
+func noReply(nameserver okaydns.Nameserver, exchange *okaydns.Exchange) okaydns.Failure {
+	return okaydns.Failure{
+		Message:    fmt.Sprintf("no reply after %d attempts (%s): %s", exchange.Attempts, okaydns.KindOf(exchange.Err), exchange.Err),
+		Nameserver: nameserver,
+		Code:       CodeNoReply,
+	}
+}
+
+// RepliesWithin builds an ExchangeValidator that warns about every nameserver
+// that took longer than max to reply, or that only replied after a retry.
+func RepliesWithin(max time.Duration) okaydns.ExchangeValidator {
+	return func(_ *dns.Msg, exchanges map[okaydns.Nameserver]*okaydns.Exchange) (failures []okaydns.Failure) {
+		for nameserver, exchange := range exchanges {
+			if exchange.Err != nil {
+				continue
+			}
+			if exchange.RTT > max {
+				failures = append(failures, okaydns.Failure{
+					Message:    fmt.Sprintf("reply took %s, longer than %s", exchange.RTT, max),
+					Nameserver: nameserver,
+					Severity:   okaydns.SeverityWarning,
+					Code:       CodeSlowReply,
+				})
+			}
+			if exchange.Attempts > 1 {
+				failures = append(failures, okaydns.Failure{
+					Message:    fmt.Sprintf("replied after %d attempts", exchange.Attempts),
+					Nameserver: nameserver,
+					Severity:   okaydns.SeverityWarning,
+					Code:       CodeRetriedReply,
+				})
+			}
+		}
+		return failures
+	}
+}
+
+// ReplySizeAtMost builds an ExchangeValidator that asserts every reply is at
+// most size bytes on the wire. Use it with 512 to check that UDP replies to
+// queries without EDNS fit in a single classic DNS message.
+//
+// See https://tools.ietf.org/html/rfc1035#section-4.2.1
+func ReplySizeAtMost(size int) okaydns.ExchangeValidator {
+	return func(_ *dns.Msg, exchanges map[okaydns.Nameserver]*okaydns.Exchange) (failures []okaydns.Failure) {
+		for nameserver, exchange := range exchanges {
+			if exchange.Err == nil && exchange.Size > size {
+				failures = append(failures, okaydns.Failure{
+					Message:    fmt.Sprintf("reply is %d bytes, larger than %d", exchange.Size, size),
+					Nameserver: nameserver,
+					Code:       CodeLargeReply,
+					Reference:  "https://tools.ietf.org/html/rfc1035#section-4.2.1",
+				})
+			}
+		}
+		return failures
+	}
+}
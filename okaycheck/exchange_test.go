@@ -0,0 +1,57 @@
+package okaycheck
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExchangeValidators(t *testing.T) {
+	ok := okaydns.Nameserver{Hostname: "ok.example.com.", IP: "192.0.2.1", Port: "53"}
+	slow := okaydns.Nameserver{Hostname: "slow.example.com.", IP: "192.0.2.2", Port: "53"}
+	broken := okaydns.Nameserver{Hostname: "broken.example.com.", IP: "192.0.2.3", Port: "53"}
+
+	exchanges := map[okaydns.Nameserver]*okaydns.Exchange{
+		ok:     {Nameserver: ok, Reply: new(dns.Msg), RTT: time.Millisecond, Size: 100, Attempts: 1},
+		slow:   {Nameserver: slow, Reply: new(dns.Msg), RTT: time.Second, Size: 1000, Attempts: 2},
+		broken: {Nameserver: broken, Err: okaydns.ClassifyError(dns.ErrId), Attempts: 1},
+	}
+
+	failures := EveryNameserverReplies(nil, exchanges)
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, broken, failures[0].Nameserver)
+		assert.Contains(t, failures[0].Message, "id-mismatch")
+	}
+
+	failures = RepliesWithin(100*time.Millisecond)(nil, exchanges)
+	if assert.Len(t, failures, 2) {
+		for _, failure := range failures {
+			assert.Equal(t, slow, failure.Nameserver)
+			assert.Equal(t, okaydns.SeverityWarning, failure.Severity)
+		}
+	}
+
+	failures = ReplySizeAtMost(512)(nil, exchanges)
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, slow, failures[0].Nameserver)
+	}
+}
+
+func TestAdaptValidator(t *testing.T) {
+	ok := okaydns.Nameserver{Hostname: "ok.example.com.", IP: "192.0.2.1", Port: "53"}
+	broken := okaydns.Nameserver{Hostname: "broken.example.com.", IP: "192.0.2.3", Port: "53"}
+
+	exchanges := map[okaydns.Nameserver]*okaydns.Exchange{
+		ok:     {Nameserver: ok, Reply: new(dns.Msg)},
+		broken: {Nameserver: broken, Err: errors.New("nope")},
+	}
+
+	failures := okaydns.AdaptValidator(EachNameserver(AuthoritativeResponse))(nil, exchanges)
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, ok, failures[0].Nameserver)
+	}
+}
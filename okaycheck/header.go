@@ -0,0 +1,192 @@
+package okaycheck
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+)
+
+// Codes for the failures returned by the header validators in this package.
+const (
+	CodeIDMismatch       = "id-mismatch"
+	CodeNotResponse      = "not-response"
+	CodeOpcodeMismatch   = "opcode-mismatch"
+	CodeQuestionMismatch = "question-mismatch"
+	CodeZBitSet          = "z-bit-set"
+	CodeCaseMismatch     = "case-mismatch"
+)
+
+// EchoesQuery builds a RequestResponseValidator that asserts every response
+// echoes the header and question section of the query it was sent in reply to.
+func EchoesQuery() okaydns.RequestResponseValidator {
+	return func(q *dns.Msg, answers map[okaydns.Nameserver]*dns.Msg) []okaydns.Failure {
+		return EachNameserver(
+			EchoesID(q),
+			IsResponse,
+			EchoesOpcode(q),
+			EchoesQuestion(q),
+			ZeroZ,
+		)(q, answers)
+	}
+}
+
+// EchoesID builds a MessageValidator that asserts a response has the same ID as
+// the query q.
+//
+// A Checker records a reply with the wrong ID as an okaydns.ErrorIDMismatch
+// error before any validators run, so EchoesID never fails in a Check. It's
+// for validating messages exchanged some other way, like with
+// okaydns.ExchangeRaw.
+func EchoesID(q *dns.Msg) okaydns.MessageValidator {
+	return func(m *dns.Msg) []okaydns.Failure {
+		if m.Id != q.Id {
+			return []okaydns.Failure{{
+				Message:   fmt.Sprintf("response ID %d does not match query ID %d", m.Id, q.Id),
+				Severity:  okaydns.SeverityCritical,
+				Code:      CodeIDMismatch,
+				Reference: RefHeader,
+			}}
+		}
+		return nil
+	}
+}
+
+// EchoesOpcode builds a MessageValidator that asserts a response has the same
+// opcode as the query q.
+func EchoesOpcode(q *dns.Msg) okaydns.MessageValidator {
+	return func(m *dns.Msg) []okaydns.Failure {
+		if m.Opcode != q.Opcode {
+			return []okaydns.Failure{{
+				Message:   fmt.Sprintf("response opcode %s does not match query opcode %s", opcodeString(m.Opcode), opcodeString(q.Opcode)),
+				Code:      CodeOpcodeMismatch,
+				Reference: RefHeader,
+			}}
+		}
+		return nil
+	}
+}
+
+// EchoesQuestion builds a MessageValidator that asserts a response has exactly
+// the same question section as the query q. Names must match exactly, including
+// case.
+func EchoesQuestion(q *dns.Msg) okaydns.MessageValidator {
+	return func(m *dns.Msg) []okaydns.Failure {
+		if len(m.Question) != len(q.Question) {
+			return []okaydns.Failure{{
+				Message:   fmt.Sprintf("response has %d questions but the query had %d", len(m.Question), len(q.Question)),
+				Code:      CodeQuestionMismatch,
+				Reference: RefHeader,
+			}}
+		}
+
+		var failures []okaydns.Failure
+		for i, question := range m.Question {
+			expected := q.Question[i]
+			switch {
+			case question.Name != expected.Name:
+				failures = append(failures, okaydns.Failure{
+					Message:   fmt.Sprintf("response question name %s does not match %s", question.Name, expected.Name),
+					Code:      CodeQuestionMismatch,
+					Reference: RefHeader,
+				})
+			case question.Qtype != expected.Qtype:
+				failures = append(failures, okaydns.Failure{
+					Message:   fmt.Sprintf("response question type %s does not match %s", typeString(question.Qtype), typeString(expected.Qtype)),
+					Code:      CodeQuestionMismatch,
+					Reference: RefHeader,
+				})
+			case question.Qclass != expected.Qclass:
+				failures = append(failures, okaydns.Failure{
+					Message:   fmt.Sprintf("response question class %s does not match %s", classString(question.Qclass), classString(expected.Qclass)),
+					Code:      CodeQuestionMismatch,
+					Reference: RefHeader,
+				})
+			}
+		}
+		return failures
+	}
+}
+
+// EchoesCase builds a RequestResponseValidator that asserts every record in
+// the Answer section that's owned by the question name spells it with exactly
+// the same case as the query. Nameservers that preserve case let resolvers use
+// 0x20 randomization.
+//
+// See:
+// - https://tools.ietf.org/html/draft-vixie-dnsext-dns0x20-00
+func EchoesCase() okaydns.RequestResponseValidator {
+	return func(q *dns.Msg, answers map[okaydns.Nameserver]*dns.Msg) []okaydns.Failure {
+		if len(q.Question) != 1 {
+			return []okaydns.Failure{{Message: "missing a question", Code: CodeMissingQuestion}}
+		}
+		return EachNameserver(EchoesNameCase(q.Question[0].Name))(q, answers)
+	}
+}
+
+// EchoesNameCase builds a MessageValidator that asserts every record in the
+// Answer section owned by name spells it with exactly the same case.
+func EchoesNameCase(name string) okaydns.MessageValidator {
+	return func(m *dns.Msg) (failures []okaydns.Failure) {
+		for _, rr := range m.Answer {
+			if owner := rr.Header().Name; owner != name && strings.EqualFold(owner, name) {
+				failures = append(failures, okaydns.Failure{
+					Message: fmt.Sprintf("answer owner %s does not match the case of %s", owner, name),
+					Code:    CodeCaseMismatch,
+				})
+			}
+		}
+		return failures
+	}
+}
+
+// IsResponse is a MessageValidator that asserts a message has the QR bit set.
+func IsResponse(m *dns.Msg) []okaydns.Failure {
+	if !m.Response {
+		return []okaydns.Failure{{
+			Message:   "response does not have the QR bit set",
+			Severity:  okaydns.SeverityCritical,
+			Code:      CodeNotResponse,
+			Reference: RefHeader,
+		}}
+	}
+	return nil
+}
+
+// ZeroZ is a MessageValidator that asserts a message has the reserved Z bit
+// cleared.
+//
+// See https://tools.ietf.org/html/rfc1035#section-4.1.1
+func ZeroZ(m *dns.Msg) []okaydns.Failure {
+	if m.Zero {
+		return []okaydns.Failure{{
+			Message:   "response has the reserved Z bit set",
+			Severity:  okaydns.SeverityWarning,
+			Code:      CodeZBitSet,
+			Reference: RefHeader,
+		}}
+	}
+	return nil
+}
+
+func opcodeString(opcode int) string {
+	if s, ok := dns.OpcodeToString[opcode]; ok {
+		return s
+	}
+	return fmt.Sprintf("OPCODE%d", opcode)
+}
+
+func typeString(rrtype uint16) string {
+	if s, ok := dns.TypeToString[rrtype]; ok {
+		return s
+	}
+	return fmt.Sprintf("TYPE%d", rrtype)
+}
+
+func classString(class uint16) string {
+	if s, ok := dns.ClassToString[class]; ok {
+		return s
+	}
+	return fmt.Sprintf("CLASS%d", class)
+}
@@ -0,0 +1,171 @@
+package okaycheck
+
+import (
+	"testing"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEchoesID(t *testing.T) {
+	q := new(dns.Msg).SetQuestion("example.com.", dns.TypeA)
+
+	validatorTests(t, []validatorTestCase{
+		{
+			"passes on a matching ID",
+			EchoesID(q),
+			new(dns.Msg).SetReply(q),
+			false,
+		},
+		{
+			"fails on a different ID",
+			EchoesID(q),
+			&dns.Msg{MsgHdr: dns.MsgHdr{Id: q.Id + 1}},
+			true,
+		},
+	})
+}
+
+func TestEchoesOpcode(t *testing.T) {
+	q := new(dns.Msg).SetQuestion("example.com.", dns.TypeA)
+
+	validatorTests(t, []validatorTestCase{
+		{
+			"passes on a matching opcode",
+			EchoesOpcode(q),
+			new(dns.Msg).SetReply(q),
+			false,
+		},
+		{
+			"fails on a different opcode",
+			EchoesOpcode(q),
+			&dns.Msg{MsgHdr: dns.MsgHdr{Opcode: dns.OpcodeNotify}},
+			true,
+		},
+	})
+}
+
+func TestEchoesQuestion(t *testing.T) {
+	q := new(dns.Msg).SetQuestion("ExAmPlE.com.", dns.TypeA)
+	withQuestion := func(question ...dns.Question) *dns.Msg {
+		return &dns.Msg{Question: question}
+	}
+
+	validatorTests(t, []validatorTestCase{
+		{
+			"passes on the same question",
+			EchoesQuestion(q),
+			new(dns.Msg).SetReply(q),
+			false,
+		},
+		{
+			"fails on a missing question",
+			EchoesQuestion(q),
+			withQuestion(),
+			true,
+		},
+		{
+			"fails on extra questions",
+			EchoesQuestion(q),
+			withQuestion(q.Question[0], q.Question[0]),
+			true,
+		},
+		{
+			"fails on a different case",
+			EchoesQuestion(q),
+			withQuestion(dns.Question{Name: "example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}),
+			true,
+		},
+		{
+			"fails on a different type",
+			EchoesQuestion(q),
+			withQuestion(dns.Question{Name: "ExAmPlE.com.", Qtype: dns.TypeAAAA, Qclass: dns.ClassINET}),
+			true,
+		},
+		{
+			"fails on a different class",
+			EchoesQuestion(q),
+			withQuestion(dns.Question{Name: "ExAmPlE.com.", Qtype: dns.TypeA, Qclass: dns.ClassCHAOS}),
+			true,
+		},
+	})
+}
+
+func TestIsResponse(t *testing.T) {
+	validatorTests(t, []validatorTestCase{
+		{
+			"fails without QR",
+			IsResponse,
+			&dns.Msg{},
+			true,
+		},
+		{
+			"passes with QR",
+			IsResponse,
+			&dns.Msg{MsgHdr: dns.MsgHdr{Response: true}},
+			false,
+		},
+	})
+}
+
+func TestZeroZ(t *testing.T) {
+	validatorTests(t, []validatorTestCase{
+		{
+			"fails with Z",
+			ZeroZ,
+			&dns.Msg{MsgHdr: dns.MsgHdr{Zero: true}},
+			true,
+		},
+		{
+			"passes without Z",
+			ZeroZ,
+			&dns.Msg{},
+			false,
+		},
+	})
+}
+
+func TestEchoesQuery(t *testing.T) {
+	nameserver := okaydns.Nameserver{Hostname: "ns1.example.com.", IP: "127.0.0.1", Port: "53"}
+	q := new(dns.Msg).SetQuestion("ExAmPlE.com.", dns.TypeA)
+
+	good := new(dns.Msg).SetReply(q)
+	failures := EchoesQuery()(q, map[okaydns.Nameserver]*dns.Msg{nameserver: good})
+	assert.Empty(t, failures, "expected a reply built with SetReply to be valid")
+
+	bad := new(dns.Msg).SetReply(q)
+	bad.Zero = true
+	bad.Question[0].Name = "example.com."
+	failures = EchoesQuery()(q, map[okaydns.Nameserver]*dns.Msg{nameserver: bad})
+	if assert.Len(t, failures, 2) {
+		for _, failure := range failures {
+			assert.Equal(t, nameserver, failure.Nameserver)
+		}
+	}
+}
+
+func TestEchoesCase(t *testing.T) {
+	nameserver := okaydns.Nameserver{Hostname: "ns1.example.com.", IP: "127.0.0.1", Port: "53"}
+	q := new(dns.Msg).SetQuestion("wWw.ExAmPlE.com.", dns.TypeA)
+
+	reply := func(records ...string) map[okaydns.Nameserver]*dns.Msg {
+		m := new(dns.Msg).SetReply(q)
+		m.Answer = MustParseRecords(records...)
+		return map[okaydns.Nameserver]*dns.Msg{nameserver: m}
+	}
+
+	assert.Empty(t, EchoesCase()(q, reply("wWw.ExAmPlE.com. 300 IN A 192.0.2.1")))
+	assert.Empty(t, EchoesCase()(q, reply("wWw.ExAmPlE.com. 300 IN CNAME cdn.example.net.", "cdn.example.net. 300 IN A 192.0.2.1")), "other names in the chain can have any case")
+
+	failures := EchoesCase()(q, reply("www.example.com. 300 IN A 192.0.2.1"))
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, CodeCaseMismatch, failures[0].Code)
+		assert.Equal(t, nameserver, failures[0].Nameserver)
+	}
+
+	failures = EchoesCase()(new(dns.Msg), reply())
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, CodeMissingQuestion, failures[0].Code)
+	}
+}
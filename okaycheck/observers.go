@@ -0,0 +1,85 @@
+package okaycheck
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+)
+
+// ResponseSizes is an ExchangeObserver that records the size of the question,
+// the size of every reply, and the amplification factor of every reply in
+// bytes. Reply sizes are the size of the reply on the wire. Question sizes are
+// the size of the question as it's sent, with the compression it was built
+// with.
+//
+// If every nameserver was sent its own question, the size of each question is
+// recorded with its nameserver.
+func ResponseSizes(q *dns.Msg, exchanges map[okaydns.Nameserver]*okaydns.Exchange) (observations []okaydns.Observation) {
+	if q != nil {
+		observations = append(observations, okaydns.Observation{
+			Name:  "query_bytes",
+			Value: strconv.Itoa(packedLen(q)),
+		})
+	}
+
+	for nameserver, exchange := range exchanges {
+		if exchange.Err != nil {
+			continue
+		}
+
+		qsize := packedLen(exchange.Query)
+		if q == nil {
+			observations = append(observations, okaydns.Observation{
+				Name:       "query_bytes",
+				Value:      strconv.Itoa(qsize),
+				Nameserver: nameserver,
+			})
+		}
+		observations = append(observations, okaydns.Observation{
+			Name:       "response_bytes",
+			Value:      strconv.Itoa(exchange.Size),
+			Nameserver: nameserver,
+		})
+		if qsize > 0 {
+			observations = append(observations, okaydns.Observation{
+				Name:       "amplification",
+				Value:      fmt.Sprintf("%.2f", float64(exchange.Size)/float64(qsize)),
+				Nameserver: nameserver,
+			})
+		}
+	}
+
+	return observations
+}
+
+// packedLen returns the length of a packed message, or zero if the message
+// can't be packed.
+func packedLen(m *dns.Msg) int {
+	bs, err := m.Pack()
+	if err != nil {
+		return 0
+	}
+	return len(bs)
+}
+
+// AnswerTXT builds an Observer that records the strings in every TXT record in
+// every response under the given name.
+func AnswerTXT(name string) okaydns.Observer {
+	return func(_ *dns.Msg, answers map[okaydns.Nameserver]*dns.Msg) (observations []okaydns.Observation) {
+		for nameserver, answer := range answers {
+			for _, rr := range answer.Answer {
+				if txt, ok := rr.(*dns.TXT); ok {
+					observations = append(observations, okaydns.Observation{
+						Name:       name,
+						Value:      strings.Join(txt.Txt, " "),
+						Nameserver: nameserver,
+					})
+				}
+			}
+		}
+		return observations
+	}
+}
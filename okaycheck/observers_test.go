@@ -0,0 +1,69 @@
+package okaycheck
+
+import (
+	"net"
+	"testing"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseSizes(t *testing.T) {
+	nameserver := okaydns.Nameserver{Hostname: "ns1.example.com.", IP: "127.0.0.1", Port: "53"}
+	unreachable := okaydns.Nameserver{Hostname: "ns2.example.com.", IP: "127.0.0.2", Port: "53"}
+
+	q := new(dns.Msg).SetQuestion("example.com.", dns.TypeANY)
+	reply := new(dns.Msg).SetReply(q)
+	for i := 0; i < 10; i++ {
+		reply.Answer = append(reply.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET},
+			A:   net.IPv4(10, 0, 0, byte(i)),
+		})
+	}
+
+	// the size on the wire is what counts, even if packing the parsed reply
+	// again would give a different size.
+	exchanges := map[okaydns.Nameserver]*okaydns.Exchange{
+		nameserver:  {Nameserver: nameserver, Query: q, Reply: reply, Size: 290},
+		unreachable: {Nameserver: unreachable, Query: q, Err: &okaydns.ExchangeError{Kind: okaydns.ErrorTimeout}},
+	}
+
+	observations := ResponseSizes(q, exchanges)
+	values := make(map[string]string)
+	for _, o := range observations {
+		values[o.Name] = o.Value
+		if o.Name != "query_bytes" {
+			assert.Equal(t, nameserver, o.Nameserver, "response observations should include a nameserver")
+		}
+	}
+
+	assert.Len(t, observations, 3)
+	assert.Equal(t, "29", values["query_bytes"])
+	assert.Equal(t, "290", values["response_bytes"])
+	assert.Equal(t, "10.00", values["amplification"])
+
+	observations = ResponseSizes(nil, exchanges)
+	if assert.Len(t, observations, 3) {
+		assert.Equal(t, okaydns.Observation{Name: "query_bytes", Value: "29", Nameserver: nameserver}, observations[0])
+	}
+}
+
+func TestAnswerTXT(t *testing.T) {
+	disclosing := okaydns.Nameserver{Hostname: "ns1.example.com.", IP: "127.0.0.1", Port: "53"}
+	quiet := okaydns.Nameserver{Hostname: "ns2.example.com.", IP: "127.0.0.2", Port: "53"}
+
+	q := okaydns.NonRecursiveClassQuestion("version.bind.", dns.TypeTXT, dns.ClassCHAOS)
+	version := new(dns.Msg).SetReply(q)
+	version.Answer = []dns.RR{&dns.TXT{
+		Hdr: dns.RR_Header{Name: "version.bind.", Rrtype: dns.TypeTXT, Class: dns.ClassCHAOS},
+		Txt: []string{"9.11.3", "RedHat"},
+	}}
+	refused := new(dns.Msg).SetRcode(q, dns.RcodeRefused)
+
+	observations := AnswerTXT("version.bind")(q, map[okaydns.Nameserver]*dns.Msg{
+		disclosing: version,
+		quiet:      refused,
+	})
+	assert.Equal(t, []okaydns.Observation{{Name: "version.bind", Value: "9.11.3 RedHat", Nameserver: disclosing}}, observations)
+}
@@ -0,0 +1,369 @@
+package okaycheck
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// Codes for the failures returned by the record validators in this package.
+const (
+	CodeRecordMismatch  = "record-mismatch"
+	CodeMissingRecord   = "missing-record"
+	CodeUnexpectedOwner = "unexpected-owner"
+	CodeTTLOutOfRange   = "ttl-out-of-range"
+)
+
+// A Section is one of the sections of a DNS message that holds records.
+type Section int
+
+// Message sections.
+const (
+	AnswerSection Section = iota
+	AuthoritySection
+	AdditionalSection
+)
+
+func (s Section) String() string {
+	switch s {
+	case AnswerSection:
+		return "answer"
+	case AuthoritySection:
+		return "authority"
+	case AdditionalSection:
+		return "additional"
+	default:
+		return fmt.Sprintf("section %d", int(s))
+	}
+}
+
+// Records returns the records in a section of m. The OPT pseudo-record is never
+// part of the Additional section.
+func (s Section) Records(m *dns.Msg) []dns.RR {
+	switch s {
+	case AnswerSection:
+		return m.Answer
+	case AuthoritySection:
+		return m.Ns
+	case AdditionalSection:
+		var records []dns.RR
+		for _, rr := range m.Extra {
+			if rr.Header().Rrtype != dns.TypeOPT {
+				records = append(records, rr)
+			}
+		}
+		return records
+	default:
+		return nil
+	}
+}
+
+// A RecordFilter selects records.
+type RecordFilter func(dns.RR) bool
+
+// Owner selects records owned by name. Names are compared case-insensitively.
+func Owner(name string) RecordFilter {
+	name = dns.Fqdn(name)
+	return func(rr dns.RR) bool {
+		return strings.EqualFold(rr.Header().Name, name)
+	}
+}
+
+// OwnerIn selects records owned by zone or any name below it.
+func OwnerIn(zone string) RecordFilter {
+	zone = dns.Fqdn(zone)
+	return func(rr dns.RR) bool {
+		return dns.IsSubDomain(zone, rr.Header().Name)
+	}
+}
+
+// OwnerMatches selects records with an owner name that matches re. Owner names
+// are lowercased before they're matched.
+func OwnerMatches(re *regexp.Regexp) RecordFilter {
+	return func(rr dns.RR) bool {
+		return re.MatchString(strings.ToLower(rr.Header().Name))
+	}
+}
+
+// OfType selects records of the given type.
+func OfType(rrtype uint16) RecordFilter {
+	return func(rr dns.RR) bool {
+		return rr.Header().Rrtype == rrtype
+	}
+}
+
+// SameData selects records with the same type and record data as rr. Records
+// are compared in their canonical form, ignoring their owner names and TTLs.
+func SameData(rr dns.RR) RecordFilter {
+	want := Canonical(withOwner(rr, "."))
+	return func(other dns.RR) bool {
+		return Canonical(withOwner(other, ".")) == want
+	}
+}
+
+// withOwner returns a copy of rr owned by name.
+func withOwner(rr dns.RR, name string) dns.RR {
+	rr = dns.Copy(rr)
+	rr.Header().Name = name
+	return rr
+}
+
+func selectRecords(records []dns.RR, filters []RecordFilter) (selected []dns.RR) {
+	for _, rr := range records {
+		if matchesAll(rr, filters) {
+			selected = append(selected, rr)
+		}
+	}
+	return selected
+}
+
+func matchesAll(rr dns.RR, filters []RecordFilter) bool {
+	for _, filter := range filters {
+		if !filter(rr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains builds a MessageValidator that asserts a section has at least one
+// record selected by every filter.
+//
+//	okaycheck.Contains(okaycheck.AnswerSection, okaycheck.Owner("www.example.com."), okaycheck.OfType(dns.TypeCNAME))
+func Contains(section Section, filters ...RecordFilter) okaydns.MessageValidator {
+	return func(m *dns.Msg) []okaydns.Failure {
+		if len(selectRecords(section.Records(m), filters)) == 0 {
+			return []okaydns.Failure{{
+				Message: fmt.Sprintf("%s section has no matching records", section),
+				Code:    CodeMissingRecord,
+			}}
+		}
+		return nil
+	}
+}
+
+// EveryRecord builds a MessageValidator that asserts every record in a section
+// is selected by every filter. It's most useful with owner name filters, to
+// make sure a section doesn't contain records for unexpected names.
+//
+//	okaycheck.EveryRecord(okaycheck.AnswerSection, okaycheck.OwnerIn("example.com."))
+func EveryRecord(section Section, filters ...RecordFilter) okaydns.MessageValidator {
+	return func(m *dns.Msg) (failures []okaydns.Failure) {
+		for _, rr := range section.Records(m) {
+			if !matchesAll(rr, filters) {
+				failures = append(failures, okaydns.Failure{
+					Message: fmt.Sprintf("%s section has an unexpected record: %s", section, rr),
+					Code:    CodeUnexpectedOwner,
+				})
+			}
+		}
+		return failures
+	}
+}
+
+// TTLInRange builds a MessageValidator that asserts every record in a section
+// that's selected by every filter has a TTL between min and max, inclusive.
+//
+//	okaycheck.TTLInRange(okaycheck.AnswerSection, 60, 300, okaycheck.OfType(dns.TypeA))
+func TTLInRange(section Section, min, max uint32, filters ...RecordFilter) okaydns.MessageValidator {
+	return func(m *dns.Msg) (failures []okaydns.Failure) {
+		for _, rr := range selectRecords(section.Records(m), filters) {
+			if ttl := rr.Header().Ttl; ttl < min || ttl > max {
+				failures = append(failures, okaydns.Failure{
+					Message: fmt.Sprintf("TTL is %d, expected between %d and %d: %s", ttl, min, max, rr),
+					Code:    CodeTTLOutOfRange,
+				})
+			}
+		}
+		return failures
+	}
+}
+
+// A MatchMode is how a RecordMatcher compares a section to the expected
+// records.
+type MatchMode int
+
+// Match modes.
+const (
+	// MatchExact requires a section to have exactly the expected records.
+	MatchExact MatchMode = iota
+
+	// MatchSuperset requires a section to have every expected record, and
+	// allows it to have others.
+	MatchSuperset
+
+	// MatchSubset requires every record in a section to be one of the
+	// expected records, but doesn't require every expected record to be there.
+	MatchSubset
+)
+
+func (m MatchMode) String() string {
+	switch m {
+	case MatchExact:
+		return "exact"
+	case MatchSuperset:
+		return "superset"
+	case MatchSubset:
+		return "subset"
+	default:
+		return fmt.Sprintf("mode %d", int(m))
+	}
+}
+
+// A RecordMatcher compares the records in a section of a message to a set of
+// expected records.
+//
+// Records are compared as a set, using their canonical form: owner names and
+// the names in record data are compared case-insensitively. TTLs must match
+// unless IgnoreTTL is set. If there are any Filters, only the records in the
+// section selected by every filter are compared. If Key is set, records are
+// compared by the key it returns instead, so that only part of a record has to
+// match.
+type RecordMatcher struct {
+	Section   Section
+	Mode      MatchMode
+	IgnoreTTL bool
+	Filters   []RecordFilter
+	Key       func(dns.RR) string
+}
+
+// Validator builds a MessageValidator that compares a section to the expected
+// records. Any difference is reported as a single failure with a diff of every
+// record that's missing, unexpected, or has the wrong TTL.
+func (r RecordMatcher) Validator(expected ...dns.RR) okaydns.MessageValidator {
+	return func(m *dns.Msg) []okaydns.Failure {
+		diff := r.diff(expected, selectRecords(r.Section.Records(m), r.Filters))
+		if diff == "" {
+			return nil
+		}
+		return []okaydns.Failure{{
+			Message: fmt.Sprintf("%s section doesn't match the expected records (%s):\n%s", r.Section, r.Mode, diff),
+			Code:    CodeRecordMismatch,
+		}}
+	}
+}
+
+// diff returns a line for every expected record that's missing or has the wrong
+// TTL, prefixed with "-" and "~", and a line for every unexpected record,
+// prefixed with "+". Returns an empty string if the records match.
+func (r RecordMatcher) diff(expected, records []dns.RR) string {
+	keyOf := r.Key
+	if keyOf == nil {
+		keyOf = Canonical
+	}
+
+	got := make(map[string]dns.RR, len(records))
+	for _, rr := range records {
+		got[keyOf(rr)] = rr
+	}
+	want := make(map[string]bool, len(expected))
+
+	var bs bytes.Buffer
+	for _, rr := range expected {
+		key := keyOf(rr)
+		if want[key] {
+			continue
+		}
+		want[key] = true
+
+		answer, ok := got[key]
+		switch {
+		case !ok && r.Mode != MatchSubset:
+			fmt.Fprintf(&bs, "\t- %s\n", rr)
+		case ok && !r.IgnoreTTL && answer.Header().Ttl != rr.Header().Ttl:
+			fmt.Fprintf(&bs, "\t~ %s (TTL %d)\n", rr, answer.Header().Ttl)
+		}
+	}
+
+	if r.Mode != MatchSuperset {
+		// walk the records instead of got, so that unexpected records are listed
+		// in the order they were in the message, and only once.
+		for _, rr := range records {
+			if key := keyOf(rr); !want[key] && got[key] == rr {
+				fmt.Fprintf(&bs, "\t+ %s\n", rr)
+			}
+		}
+	}
+
+	return strings.TrimSuffix(bs.String(), "\n")
+}
+
+// Canonical returns rr in zone file syntax with its TTL zeroed and its owner
+// name, and any names in its record data, lowercased. Two records with the
+// same canonical form are the same record.
+//
+// See:
+// - https://tools.ietf.org/html/rfc4034#section-6.2
+func Canonical(rr dns.RR) string {
+	rr = dns.Copy(rr)
+	rr.Header().Name = strings.ToLower(rr.Header().Name)
+	rr.Header().Ttl = 0
+
+	switch rr := rr.(type) {
+	case *dns.NS:
+		rr.Ns = strings.ToLower(rr.Ns)
+	case *dns.CNAME:
+		rr.Target = strings.ToLower(rr.Target)
+	case *dns.DNAME:
+		rr.Target = strings.ToLower(rr.Target)
+	case *dns.PTR:
+		rr.Ptr = strings.ToLower(rr.Ptr)
+	case *dns.MX:
+		rr.Mx = strings.ToLower(rr.Mx)
+	case *dns.SRV:
+		rr.Target = strings.ToLower(rr.Target)
+	case *dns.SOA:
+		rr.Ns = strings.ToLower(rr.Ns)
+		rr.Mbox = strings.ToLower(rr.Mbox)
+	}
+
+	return rr.String()
+}
+
+// AnswerMatches builds a MessageValidator that compares the Answer section of
+// a message to records written in zone file syntax. It panics if the records
+// can't be parsed. See RecordMatcher for how records are compared.
+//
+//	okaycheck.AnswerMatches(okaycheck.MatchExact, "www.example.com. 300 IN CNAME cdn.example.net.")
+func AnswerMatches(mode MatchMode, records ...string) okaydns.MessageValidator {
+	return RecordMatcher{Section: AnswerSection, Mode: mode}.Validator(MustParseRecords(records...)...)
+}
+
+// AuthorityMatches is like AnswerMatches for the Authority section.
+func AuthorityMatches(mode MatchMode, records ...string) okaydns.MessageValidator {
+	return RecordMatcher{Section: AuthoritySection, Mode: mode}.Validator(MustParseRecords(records...)...)
+}
+
+// AdditionalMatches is like AnswerMatches for the Additional section.
+func AdditionalMatches(mode MatchMode, records ...string) okaydns.MessageValidator {
+	return RecordMatcher{Section: AdditionalSection, Mode: mode}.Validator(MustParseRecords(records...)...)
+}
+
+// ParseRecords parses records written in zone file syntax, one record per
+// line. Lines may include directives like $ORIGIN and $TTL, which apply to the
+// lines after them.
+func ParseRecords(lines ...string) ([]dns.RR, error) {
+	var records []dns.RR
+	for token := range dns.ParseZone(strings.NewReader(strings.Join(lines, "\n")+"\n"), ".", "") {
+		if token.Error != nil {
+			return nil, errors.Wrap(token.Error, "invalid records")
+		}
+		records = append(records, token.RR)
+	}
+	return records, nil
+}
+
+// MustParseRecords is like ParseRecords but panics if the records can't be
+// parsed. It simplifies building validators in global variables.
+func MustParseRecords(lines ...string) []dns.RR {
+	records, err := ParseRecords(lines...)
+	if err != nil {
+		panic(fmt.Sprintf("okaycheck: %s", err))
+	}
+	return records
+}
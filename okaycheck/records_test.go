@@ -0,0 +1,189 @@
+package okaycheck
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func answer(t *testing.T, records ...string) *dns.Msg {
+	t.Helper()
+
+	rrs, err := ParseRecords(records...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := new(dns.Msg)
+	m.Answer = rrs
+	return m
+}
+
+func TestRecordMatcher(t *testing.T) {
+	expected := MustParseRecords(
+		"$ORIGIN example.com.",
+		"@   300 IN A  192.0.2.1",
+		"@   300 IN A  192.0.2.2",
+		"@   300 IN MX 10 mail",
+	)
+
+	tcs := []struct {
+		name    string
+		matcher RecordMatcher
+		answer  []string
+		diff    string
+	}{
+		{
+			"exact match in any order",
+			RecordMatcher{Mode: MatchExact},
+			[]string{"example.com. 300 IN MX 10 mail.example.com.", "example.com. 300 IN A 192.0.2.2", "example.com. 300 IN A 192.0.2.1"},
+			"",
+		},
+		{
+			"names are case-insensitive",
+			RecordMatcher{Mode: MatchExact},
+			[]string{"EXAMPLE.com. 300 IN MX 10 MAIL.example.com.", "Example.com. 300 IN A 192.0.2.2", "example.COM. 300 IN A 192.0.2.1"},
+			"",
+		},
+		{
+			"exact with a missing and an extra record",
+			RecordMatcher{Mode: MatchExact},
+			[]string{"example.com. 300 IN A 192.0.2.1", "example.com. 300 IN A 192.0.2.9", "example.com. 300 IN MX 10 mail.example.com."},
+			"\t- example.com.\t300\tIN\tA\t192.0.2.2\n\t+ example.com.\t300\tIN\tA\t192.0.2.9",
+		},
+		{
+			"exact with the wrong ttl",
+			RecordMatcher{Mode: MatchExact},
+			[]string{"example.com. 3600 IN A 192.0.2.1", "example.com. 300 IN A 192.0.2.2", "example.com. 300 IN MX 10 mail.example.com."},
+			"\t~ example.com.\t300\tIN\tA\t192.0.2.1 (TTL 3600)",
+		},
+		{
+			"ignoring ttls",
+			RecordMatcher{Mode: MatchExact, IgnoreTTL: true},
+			[]string{"example.com. 3600 IN A 192.0.2.1", "example.com. 60 IN A 192.0.2.2", "example.com. 300 IN MX 10 mail.example.com."},
+			"",
+		},
+		{
+			"superset allows extra records",
+			RecordMatcher{Mode: MatchSuperset},
+			[]string{"example.com. 300 IN A 192.0.2.1", "example.com. 300 IN A 192.0.2.2", "example.com. 300 IN A 192.0.2.3", "example.com. 300 IN MX 10 mail.example.com."},
+			"",
+		},
+		{
+			"superset requires every record",
+			RecordMatcher{Mode: MatchSuperset},
+			[]string{"example.com. 300 IN A 192.0.2.1", "example.com. 300 IN A 192.0.2.3"},
+			"\t- example.com.\t300\tIN\tA\t192.0.2.2\n\t- example.com.\t300\tIN\tMX\t10 mail.example.com.",
+		},
+		{
+			"subset allows missing records",
+			RecordMatcher{Mode: MatchSubset},
+			[]string{"example.com. 300 IN A 192.0.2.1"},
+			"",
+		},
+		{
+			"subset rejects extra records",
+			RecordMatcher{Mode: MatchSubset},
+			[]string{"example.com. 300 IN A 192.0.2.1", "example.com. 300 IN TXT \"hello\""},
+			"\t+ example.com.\t300\tIN\tTXT\t\"hello\"",
+		},
+		{
+			"filters limit what's compared",
+			RecordMatcher{Mode: MatchExact, Filters: []RecordFilter{OfType(dns.TypeA)}},
+			[]string{"example.com. 300 IN A 192.0.2.1", "example.com. 300 IN A 192.0.2.2", "example.com. 300 IN TXT \"hello\""},
+			"\t- example.com.\t300\tIN\tMX\t10 mail.example.com.",
+		},
+		{
+			"key compares part of a record",
+			RecordMatcher{Mode: MatchExact, Key: func(rr dns.RR) string { return dns.Type(rr.Header().Rrtype).String() }},
+			[]string{"example.com. 300 IN A 192.0.2.9", "example.com. 300 IN MX 20 mx.example.net."},
+			"",
+		},
+	}
+
+	for _, tc := range tcs {
+		failures := tc.matcher.Validator(expected...)(answer(t, tc.answer...))
+		if tc.diff == "" {
+			assert.Empty(t, failures, tc.name)
+			continue
+		}
+		if assert.Len(t, failures, 1, tc.name) {
+			assert.Equal(t, CodeRecordMismatch, failures[0].Code, tc.name)
+			assert.Contains(t, failures[0].Message, "\n"+tc.diff, tc.name)
+		}
+	}
+}
+
+func TestSectionMatches(t *testing.T) {
+	m := answer(t, "www.example.com. 300 IN CNAME cdn.example.net.")
+	m.Ns = MustParseRecords("example.com. 300 IN NS ns1.example.com.")
+	m.Extra = MustParseRecords("ns1.example.com. 300 IN A 192.0.2.53")
+	m.SetEdns0(1232, false)
+
+	assert.Empty(t, AnswerMatches(MatchExact, "www.example.com. 300 IN CNAME cdn.example.net.")(m))
+	assert.Empty(t, AuthorityMatches(MatchExact, "example.com. 300 IN NS ns1.example.com.")(m))
+	assert.Empty(t, AdditionalMatches(MatchExact, "ns1.example.com. 300 IN A 192.0.2.53")(m), "OPT records shouldn't be compared")
+	assert.NotEmpty(t, AuthorityMatches(MatchExact)(m))
+
+	assert.Panics(t, func() { AnswerMatches(MatchExact, "www.example.com. 300 IN A not-an-ip") })
+}
+
+func TestRecordFilters(t *testing.T) {
+	rr := MustParseRecords("WWW.Example.com. 300 IN A 192.0.2.1")[0]
+
+	tcs := []struct {
+		name    string
+		filter  RecordFilter
+		matches bool
+	}{
+		{"owner", Owner("www.example.com"), true},
+		{"other owner", Owner("example.com."), false},
+		{"in zone", OwnerIn("example.com."), true},
+		{"in itself", OwnerIn("www.example.com."), true},
+		{"not in zone", OwnerIn("example.net."), false},
+		{"not in child", OwnerIn("sub.www.example.com."), false},
+		{"pattern", OwnerMatches(regexp.MustCompile(`^www\.`)), true},
+		{"other pattern", OwnerMatches(regexp.MustCompile(`^mail\.`)), false},
+		{"type", OfType(dns.TypeA), true},
+		{"other type", OfType(dns.TypeAAAA), false},
+		{"same data", SameData(MustParseRecords("example.net. 60 IN A 192.0.2.1")[0]), true},
+		{"other data", SameData(MustParseRecords("www.example.com. 300 IN A 192.0.2.2")[0]), false},
+	}
+
+	for _, tc := range tcs {
+		assert.Equal(t, tc.matches, tc.filter(rr), tc.name)
+	}
+}
+
+func TestRecordValidators(t *testing.T) {
+	m := answer(t,
+		"www.example.com. 3600 IN CNAME cdn.example.net.",
+		"cdn.example.net. 60 IN A 192.0.2.1",
+	)
+
+	tcs := []struct {
+		name      string
+		validator func(*dns.Msg) int
+		failures  int
+	}{
+		{"contains", count(Contains(AnswerSection, Owner("www.example.com."), OfType(dns.TypeCNAME))), 0},
+		{"does not contain", count(Contains(AnswerSection, Owner("www.example.com."), OfType(dns.TypeA))), 1},
+		{"empty section", count(Contains(AuthoritySection)), 1},
+		{"every record in zone", count(EveryRecord(AnswerSection, OwnerIn("example.com."))), 1},
+		{"every record anywhere", count(EveryRecord(AnswerSection)), 0},
+		{"ttl in range", count(TTLInRange(AnswerSection, 60, 3600)), 0},
+		{"ttl too high", count(TTLInRange(AnswerSection, 0, 300)), 1},
+		{"ttl too high for cname", count(TTLInRange(AnswerSection, 0, 300, OfType(dns.TypeA))), 0},
+		{"ttl too low", count(TTLInRange(AnswerSection, 300, 86400)), 1},
+	}
+
+	for _, tc := range tcs {
+		assert.Equal(t, tc.failures, tc.validator(m), tc.name)
+	}
+}
+
+func count(v okaydns.MessageValidator) func(*dns.Msg) int {
+	return func(m *dns.Msg) int { return len(v(m)) }
+}
@@ -0,0 +1,415 @@
+package okaycheck
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+)
+
+// Codes for the failures returned by the RRset validators in this package.
+const (
+	CodeRRsetTTLMismatch  = "rrset-ttl-mismatch"
+	CodeDuplicateRecord   = "duplicate-record"
+	CodeCNAMEAndOtherData = "cname-and-other-data"
+	CodeTargetIsAlias     = "target-is-alias"
+	CodeTargetIsAddress   = "target-is-address"
+	CodeOutOfChainAnswer  = "out-of-chain-answer"
+)
+
+// References to the RFC sections that describe the rules checked by the RRset
+// validators in this package.
+const (
+	RefRRsets      = "https://tools.ietf.org/html/rfc2181#section-5"
+	RefRRsetTTL    = "https://tools.ietf.org/html/rfc2181#section-5.2"
+	RefCNAME       = "https://tools.ietf.org/html/rfc2181#section-10.1"
+	RefNoAliases   = "https://tools.ietf.org/html/rfc2181#section-10.3"
+	RefTargetNames = "https://tools.ietf.org/html/rfc1035#section-3.3.9"
+)
+
+var recordSections = []Section{AnswerSection, AuthoritySection, AdditionalSection}
+
+// An rrset identifies the RRset a record belongs to. RRSIGs are grouped by the
+// type they cover, since every RRset has its own signatures.
+type rrset struct {
+	name    string
+	class   uint16
+	rrtype  uint16
+	covered uint16
+}
+
+func rrsetOf(rr dns.RR) rrset {
+	key := rrset{
+		name:   strings.ToLower(rr.Header().Name),
+		class:  rr.Header().Class,
+		rrtype: rr.Header().Rrtype,
+	}
+	if sig, ok := rr.(*dns.RRSIG); ok {
+		key.covered = sig.TypeCovered
+	}
+	return key
+}
+
+func (r rrset) String() string {
+	if r.rrtype == dns.TypeRRSIG {
+		return fmt.Sprintf("%s RRSIG %s", r.name, dns.Type(r.covered))
+	}
+	return fmt.Sprintf("%s %s", r.name, dns.Type(r.rrtype))
+}
+
+// RRsetIntegrity is a MessageValidator that runs every validator for the
+// RRset rules of RFC 2181 that only need a single message: RRsetTTLsMatch,
+// NoDuplicateRecords, CNAMEWithoutOtherData and TargetsAreHostnames.
+func RRsetIntegrity(m *dns.Msg) []okaydns.Failure {
+	return AllOf(
+		RRsetTTLsMatch,
+		NoDuplicateRecords,
+		CNAMEWithoutOtherData,
+		TargetsAreHostnames,
+	)(m)
+}
+
+// RRsetTTLsMatch is a MessageValidator that asserts every record in an RRset
+// has the same TTL. RRsets are compared within each section of a message.
+func RRsetTTLsMatch(m *dns.Msg) []okaydns.Failure {
+	return bySection(m, RRsetTTLFailures)
+}
+
+// RRsetTTLFailures returns a failure for every RRset in records that has more
+// than one TTL.
+func RRsetTTLFailures(records []dns.RR) (failures []okaydns.Failure) {
+	var rrsets []rrset
+	ttls := make(map[rrset][]uint32)
+	for _, rr := range records {
+		key, ttl := rrsetOf(rr), rr.Header().Ttl
+		if _, ok := ttls[key]; !ok {
+			rrsets = append(rrsets, key)
+		}
+		if !containsTTL(ttls[key], ttl) {
+			ttls[key] = append(ttls[key], ttl)
+		}
+	}
+
+	for _, key := range rrsets {
+		if len(ttls[key]) > 1 {
+			failures = append(failures, okaydns.Failure{
+				Message:   fmt.Sprintf("RRset %s has more than one TTL: %s", key, joinTTLs(ttls[key])),
+				Code:      CodeRRsetTTLMismatch,
+				Reference: RefRRsetTTL,
+			})
+		}
+	}
+	return failures
+}
+
+// bySection runs check against the records in each section of m, and prefixes
+// the message of every failure with the section it's in.
+func bySection(m *dns.Msg, check func([]dns.RR) []okaydns.Failure) (failures []okaydns.Failure) {
+	for _, section := range recordSections {
+		for _, failure := range check(section.Records(m)) {
+			failure.Message = fmt.Sprintf("%s section: %s", section, failure.Message)
+			failures = append(failures, failure)
+		}
+	}
+	return failures
+}
+
+// allRecords returns the records in every section of m.
+func allRecords(m *dns.Msg) (records []dns.RR) {
+	for _, section := range recordSections {
+		records = append(records, section.Records(m)...)
+	}
+	return records
+}
+
+func containsTTL(ttls []uint32, ttl uint32) bool {
+	for _, t := range ttls {
+		if t == ttl {
+			return true
+		}
+	}
+	return false
+}
+
+func joinTTLs(ttls []uint32) string {
+	strs := make([]string, len(ttls))
+	for i, ttl := range ttls {
+		strs[i] = fmt.Sprint(ttl)
+	}
+	return strings.Join(strs, ", ")
+}
+
+// NoDuplicateRecords is a MessageValidator that asserts no section of a
+// message has the same record more than once. Servers should suppress
+// duplicates, so they are only warnings.
+func NoDuplicateRecords(m *dns.Msg) []okaydns.Failure {
+	return bySection(m, DuplicateRecordFailures)
+}
+
+// DuplicateRecordFailures returns a warning for every record that's in records
+// more than once. Records are compared in their canonical form, ignoring TTLs.
+func DuplicateRecordFailures(records []dns.RR) (failures []okaydns.Failure) {
+	var duplicated []dns.RR
+	counts := make(map[string]int)
+	for _, rr := range records {
+		key := Canonical(rr)
+		counts[key]++
+		if counts[key] == 2 {
+			duplicated = append(duplicated, rr)
+		}
+	}
+
+	for _, rr := range duplicated {
+		failures = append(failures, okaydns.Failure{
+			Message:   fmt.Sprintf("%d copies of %s", counts[Canonical(rr)], rr),
+			Severity:  okaydns.SeverityWarning,
+			Code:      CodeDuplicateRecord,
+			Reference: RefRRsets,
+		})
+	}
+	return failures
+}
+
+// CNAMEWithoutOtherData is a MessageValidator that asserts no name in a
+// message has a CNAME record and records of any other type, or more than one
+// CNAME record. See CNAMEFailures.
+func CNAMEWithoutOtherData(m *dns.Msg) []okaydns.Failure {
+	return CNAMEFailures(allRecords(m))
+}
+
+// CNAMEFailures returns a failure for every name in records that has more
+// than one CNAME record, and for every name that has a CNAME record and
+// records of any other type. The DNSSEC records that can be next to a CNAME are
+// allowed.
+//
+// See:
+// - https://tools.ietf.org/html/rfc2181#section-10.1
+// - https://tools.ietf.org/html/rfc4035#section-2.5
+func CNAMEFailures(records []dns.RR) (failures []okaydns.Failure) {
+	var owners []string
+	types := make(map[string][]uint16)
+	cnames := make(map[string]map[string]bool)
+	for _, rr := range records {
+		owner, rrtype := strings.ToLower(rr.Header().Name), rr.Header().Rrtype
+		if _, ok := types[owner]; !ok {
+			owners = append(owners, owner)
+		}
+		if !containsType(types[owner], rrtype) {
+			types[owner] = append(types[owner], rrtype)
+		}
+		if rrtype == dns.TypeCNAME {
+			if cnames[owner] == nil {
+				cnames[owner] = make(map[string]bool)
+			}
+			cnames[owner][Canonical(rr)] = true
+		}
+	}
+
+	for _, owner := range owners {
+		if len(cnames[owner]) == 0 {
+			continue
+		}
+		if n := len(cnames[owner]); n > 1 {
+			failures = append(failures, okaydns.Failure{
+				Message:   fmt.Sprintf("%s has %d CNAME records", owner, n),
+				Code:      CodeCNAMEAndOtherData,
+				Reference: RefCNAME,
+			})
+		}
+
+		var others []string
+		for _, rrtype := range types[owner] {
+			switch rrtype {
+			case dns.TypeCNAME, dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3:
+				continue
+			}
+			others = append(others, dns.Type(rrtype).String())
+		}
+		if len(others) > 0 {
+			failures = append(failures, okaydns.Failure{
+				Message:   fmt.Sprintf("%s has a CNAME record and other data (%s)", owner, strings.Join(others, ", ")),
+				Code:      CodeCNAMEAndOtherData,
+				Reference: RefCNAME,
+			})
+		}
+	}
+	return failures
+}
+
+func containsType(types []uint16, rrtype uint16) bool {
+	for _, t := range types {
+		if t == rrtype {
+			return true
+		}
+	}
+	return false
+}
+
+// TargetsAreHostnames is a MessageValidator that asserts the targets of NS and
+// MX records in a message are hostnames. A target must not be an IP address
+// written as a name, and must not be the owner of a CNAME record in the same
+// message. The null MX target "." is allowed.
+//
+// A target that is an alias can only be seen in a single message if the
+// server includes the CNAME. Use TargetsAreNotAliases in a step that asks
+// about every target to find the rest.
+func TargetsAreHostnames(m *dns.Msg) (failures []okaydns.Failure) {
+	records := allRecords(m)
+	for _, rr := range records {
+		target, ok := targetOf(rr)
+		if !ok || target == "." {
+			continue
+		}
+
+		if net.ParseIP(strings.TrimSuffix(target, ".")) != nil {
+			failures = append(failures, okaydns.Failure{
+				Message:   fmt.Sprintf("%s target is an IP address, not a hostname: %s", dns.Type(rr.Header().Rrtype), rr),
+				Code:      CodeTargetIsAddress,
+				Reference: RefTargetNames,
+			})
+		}
+	}
+	return append(failures, AliasTargetFailures(records)...)
+}
+
+// AliasTargetFailures returns a failure for every NS and MX record in records
+// whose target is the owner of a CNAME record in records.
+func AliasTargetFailures(records []dns.RR) (failures []okaydns.Failure) {
+	aliases := make(map[string]string)
+	for _, rr := range records {
+		if cname, ok := rr.(*dns.CNAME); ok {
+			aliases[strings.ToLower(cname.Hdr.Name)] = cname.Target
+		}
+	}
+
+	for _, rr := range records {
+		target, ok := targetOf(rr)
+		if !ok {
+			continue
+		}
+		if alias, ok := aliases[strings.ToLower(target)]; ok {
+			failures = append(failures, okaydns.Failure{
+				Message:   fmt.Sprintf("%s target %s is an alias for %s: %s", dns.Type(rr.Header().Rrtype), target, alias, rr),
+				Code:      CodeTargetIsAlias,
+				Reference: RefNoAliases,
+			})
+		}
+	}
+	return failures
+}
+
+// targetOf returns the target of an NS or MX record.
+func targetOf(rr dns.RR) (string, bool) {
+	switch rr := rr.(type) {
+	case *dns.NS:
+		return rr.Ns, true
+	case *dns.MX:
+		return rr.Mx, true
+	default:
+		return "", false
+	}
+}
+
+// TargetsAreNotAliases is a StepValidator that asserts that no name asked
+// about in a step is the owner of a CNAME record. Use it in a step that asks
+// about the targets of NS or MX records found in the previous step.
+func TargetsAreNotAliases(_, results []*okaydns.CheckResult) (failures []okaydns.Failure) {
+	type key struct {
+		name       string
+		nameserver okaydns.Nameserver
+	}
+	seen := make(map[key]bool)
+
+	for _, result := range results {
+		for nameserver, answer := range result.Answers {
+			q := result.Questions[nameserver]
+			if len(q.Question) == 0 {
+				continue
+			}
+
+			name := q.Question[0].Name
+			for _, rr := range answer.Answer {
+				cname, ok := rr.(*dns.CNAME)
+				if !ok || !strings.EqualFold(cname.Hdr.Name, name) {
+					continue
+				}
+
+				k := key{strings.ToLower(name), nameserver}
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+				failures = append(failures, okaydns.Failure{
+					Message:    fmt.Sprintf("%s is an alias for %s", k.name, cname.Target),
+					Nameserver: nameserver,
+					Code:       CodeTargetIsAlias,
+					Reference:  RefNoAliases,
+				})
+			}
+		}
+	}
+	return failures
+}
+
+// AnswerInChain builds a RequestResponseValidator that asserts every record in
+// the Answer section of every response is part of the answer to the query's
+// question. See AnswerInChainOf.
+func AnswerInChain() okaydns.RequestResponseValidator {
+	return func(q *dns.Msg, answers map[okaydns.Nameserver]*dns.Msg) []okaydns.Failure {
+		if len(q.Question) != 1 {
+			return []okaydns.Failure{{Message: "missing a question", Code: CodeMissingQuestion}}
+		}
+		return EachNameserver(AnswerInChainOf(q.Question[0].Name))(q, answers)
+	}
+}
+
+// AnswerInChainOf builds a MessageValidator that asserts every record in the
+// Answer section is owned by name, by a name that name is an alias for, or by
+// a DNAME that applies to one of those names. A DNAME only applies to the
+// names below its owner, never to the owner itself. Any other record is data
+// that the question didn't ask for, and that resolvers should not trust.
+func AnswerInChainOf(name string) okaydns.MessageValidator {
+	return func(m *dns.Msg) (failures []okaydns.Failure) {
+		chain := map[string]bool{strings.ToLower(name): true}
+
+		// follow CNAMEs and DNAMEs until nothing new is added, since a server may
+		// list a chain in any order.
+		for added := true; added; {
+			added = false
+			for _, rr := range m.Answer {
+				owner := strings.ToLower(rr.Header().Name)
+				switch rr := rr.(type) {
+				case *dns.CNAME:
+					if target := strings.ToLower(rr.Target); chain[owner] && !chain[target] {
+						chain[target] = true
+						added = true
+					}
+				case *dns.DNAME:
+					if chain[owner] {
+						continue
+					}
+					for name := range chain {
+						if owner != name && dns.IsSubDomain(owner, name) {
+							chain[owner] = true
+							added = true
+							break
+						}
+					}
+				}
+			}
+		}
+
+		for _, rr := range m.Answer {
+			if !chain[strings.ToLower(rr.Header().Name)] {
+				failures = append(failures, okaydns.Failure{
+					Message:   fmt.Sprintf("answer section has a record outside the chain of %s: %s", name, rr),
+					Code:      CodeOutOfChainAnswer,
+					Reference: RefAuthoritative,
+				})
+			}
+		}
+		return failures
+	}
+}
@@ -0,0 +1,238 @@
+package okaycheck
+
+import (
+	"testing"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRRsetValidators(t *testing.T) {
+	tcs := []struct {
+		name      string
+		validator okaydns.MessageValidator
+		answer    []string
+		codes     []string
+	}{
+		{
+			"ttls match",
+			RRsetTTLsMatch,
+			[]string{"example.com. 300 IN A 192.0.2.1", "EXAMPLE.com. 300 IN A 192.0.2.2", "example.com. 60 IN TXT \"hi\""},
+			nil,
+		},
+		{
+			"ttls differ",
+			RRsetTTLsMatch,
+			[]string{"example.com. 300 IN A 192.0.2.1", "EXAMPLE.com. 60 IN A 192.0.2.2", "example.com. 60 IN A 192.0.2.3"},
+			[]string{CodeRRsetTTLMismatch},
+		},
+		{
+			"signatures of different rrsets",
+			RRsetTTLsMatch,
+			[]string{
+				"example.com. 300 IN RRSIG A 8 2 300 20300101000000 20200101000000 12345 example.com. AAAA",
+				"example.com. 60 IN RRSIG TXT 8 2 60 20300101000000 20200101000000 12345 example.com. AAAA",
+			},
+			nil,
+		},
+		{
+			"no duplicates",
+			NoDuplicateRecords,
+			[]string{"example.com. 300 IN A 192.0.2.1", "example.com. 300 IN A 192.0.2.2"},
+			nil,
+		},
+		{
+			"duplicates",
+			NoDuplicateRecords,
+			[]string{"example.com. 300 IN A 192.0.2.1", "EXAMPLE.com. 60 IN A 192.0.2.1", "example.com. 300 IN A 192.0.2.1"},
+			[]string{CodeDuplicateRecord},
+		},
+		{
+			"cname chain",
+			CNAMEWithoutOtherData,
+			[]string{
+				"www.example.com. 300 IN CNAME cdn.example.net.",
+				"www.example.com. 300 IN RRSIG CNAME 8 3 300 20300101000000 20200101000000 12345 example.com. AAAA",
+				"cdn.example.net. 300 IN A 192.0.2.1",
+			},
+			nil,
+		},
+		{
+			"cname and other data",
+			CNAMEWithoutOtherData,
+			[]string{"www.example.com. 300 IN CNAME cdn.example.net.", "WWW.example.com. 300 IN A 192.0.2.1"},
+			[]string{CodeCNAMEAndOtherData},
+		},
+		{
+			"more than one cname",
+			CNAMEWithoutOtherData,
+			[]string{"www.example.com. 300 IN CNAME cdn.example.net.", "www.example.com. 300 IN CNAME cdn.example.org."},
+			[]string{CodeCNAMEAndOtherData},
+		},
+		{
+			"hostname targets",
+			TargetsAreHostnames,
+			[]string{"example.com. 300 IN MX 10 mail.example.com.", "example.com. 300 IN NS ns1.example.com.", "null.example.com. 300 IN MX 0 ."},
+			nil,
+		},
+		{
+			"address targets",
+			TargetsAreHostnames,
+			[]string{"example.com. 300 IN MX 10 192.0.2.25.", "example.com. 300 IN NS 2001:db8::53."},
+			[]string{CodeTargetIsAddress, CodeTargetIsAddress},
+		},
+		{
+			"alias target",
+			TargetsAreHostnames,
+			[]string{"example.com. 300 IN MX 10 mail.example.com.", "MAIL.example.com. 300 IN CNAME mx.example.net."},
+			[]string{CodeTargetIsAlias},
+		},
+		{
+			"integrity",
+			RRsetIntegrity,
+			[]string{"example.com. 300 IN MX 10 mail.example.com.", "example.com. 60 IN MX 10 mail.example.com."},
+			[]string{CodeRRsetTTLMismatch, CodeDuplicateRecord},
+		},
+	}
+
+	for _, tc := range tcs {
+		var codes []string
+		for _, failure := range tc.validator(answer(t, tc.answer...)) {
+			codes = append(codes, failure.Code)
+		}
+		assert.Equal(t, tc.codes, codes, tc.name)
+	}
+}
+
+func TestRRsetTTLsMatchBySection(t *testing.T) {
+	m := answer(t, "ns1.example.com. 300 IN A 192.0.2.53")
+	m.Extra = MustParseRecords("ns1.example.com. 60 IN A 192.0.2.53")
+	m.SetEdns0(1232, false)
+
+	assert.Empty(t, RRsetTTLsMatch(m), "RRsets should be compared within a section")
+	assert.Empty(t, NoDuplicateRecords(m), "duplicates should be found within a section")
+}
+
+func TestAnswerInChain(t *testing.T) {
+	tcs := []struct {
+		name   string
+		qname  string
+		answer []string
+		out    int
+	}{
+		{
+			"direct answer",
+			"www.example.com.",
+			[]string{"WWW.example.com. 300 IN A 192.0.2.1"},
+			0,
+		},
+		{
+			"cname chain in any order",
+			"www.example.com.",
+			[]string{
+				"cdn.example.org. 300 IN A 192.0.2.1",
+				"cdn.example.net. 300 IN CNAME cdn.example.org.",
+				"www.example.com. 300 IN CNAME CDN.example.net.",
+			},
+			0,
+		},
+		{
+			"dname",
+			"www.example.com.",
+			[]string{
+				"example.com. 300 IN DNAME example.net.",
+				"www.example.com. 300 IN CNAME www.example.net.",
+				"www.example.net. 300 IN A 192.0.2.1",
+			},
+			0,
+		},
+		{
+			"dname below the question name",
+			"www.example.com.",
+			[]string{
+				"www.example.com. 300 IN A 192.0.2.1",
+				"sub.www.example.com. 300 IN DNAME example.net.",
+			},
+			1,
+		},
+		{
+			"unrelated records",
+			"www.example.com.",
+			[]string{
+				"www.example.com. 300 IN A 192.0.2.1",
+				"example.com. 300 IN NS ns1.example.com.",
+				"victim.example.net. 300 IN A 192.0.2.66",
+			},
+			2,
+		},
+		{
+			"cname target of another name",
+			"www.example.com.",
+			[]string{
+				"www.example.com. 300 IN A 192.0.2.1",
+				"other.example.com. 300 IN CNAME cdn.example.net.",
+				"cdn.example.net. 300 IN A 192.0.2.2",
+			},
+			2,
+		},
+	}
+
+	for _, tc := range tcs {
+		failures := AnswerInChainOf(tc.qname)(answer(t, tc.answer...))
+		if assert.Len(t, failures, tc.out, tc.name) {
+			for _, failure := range failures {
+				assert.Equal(t, CodeOutOfChainAnswer, failure.Code, tc.name)
+			}
+		}
+	}
+
+	nameserver := okaydns.Nameserver{Hostname: "ns1.example.com.", IP: "192.0.2.1", Port: "53"}
+	q := new(dns.Msg).SetQuestion("www.example.com.", dns.TypeA)
+	failures := AnswerInChain()(q, map[okaydns.Nameserver]*dns.Msg{
+		nameserver: answer(t, "www.example.com. 300 IN A 192.0.2.1", "mail.example.com. 300 IN A 192.0.2.25"),
+	})
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, nameserver, failures[0].Nameserver)
+	}
+}
+
+func TestTargetsAreNotAliases(t *testing.T) {
+	ns1 := okaydns.Nameserver{Hostname: "ns1.example.com.", IP: "192.0.2.1", Port: "53"}
+	ns2 := okaydns.Nameserver{Hostname: "ns2.example.com.", IP: "192.0.2.2", Port: "53"}
+
+	result := func(qname string, qtype uint16, replies map[okaydns.Nameserver][]string) *okaydns.CheckResult {
+		q := new(dns.Msg).SetQuestion(qname, qtype)
+		result := &okaydns.CheckResult{
+			Questions: make(map[okaydns.Nameserver]*dns.Msg),
+			Answers:   make(map[okaydns.Nameserver]*dns.Msg),
+		}
+		for nameserver, records := range replies {
+			result.Questions[nameserver] = q
+			result.Answers[nameserver] = answer(t, records...)
+		}
+		return result
+	}
+
+	results := []*okaydns.CheckResult{
+		result("mail.example.com.", dns.TypeA, map[okaydns.Nameserver][]string{
+			ns1: {"mail.example.com. 300 IN A 192.0.2.25"},
+			ns2: {"mail.example.com. 300 IN A 192.0.2.25"},
+		}),
+		result("mx.example.com.", dns.TypeA, map[okaydns.Nameserver][]string{
+			ns1: {"mx.example.com. 300 IN CNAME mail.example.com.", "mail.example.com. 300 IN A 192.0.2.25"},
+			ns2: {"mx.example.com. 300 IN A 192.0.2.25"},
+		}),
+		result("mx.example.com.", dns.TypeAAAA, map[okaydns.Nameserver][]string{
+			ns1: {"MX.example.com. 300 IN CNAME mail.example.com."},
+			ns2: {},
+		}),
+	}
+
+	failures := TargetsAreNotAliases(nil, results)
+	if assert.Len(t, failures, 1, "aliases should be reported once per nameserver") {
+		assert.Equal(t, ns1, failures[0].Nameserver)
+		assert.Equal(t, CodeTargetIsAlias, failures[0].Code)
+		assert.Equal(t, "mx.example.com. is an alias for mail.example.com.", failures[0].Message)
+	}
+}
@@ -0,0 +1,80 @@
+package okaycheck
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+)
+
+// Codes for the failures returned by the step validators in this package.
+const (
+	CodeNoAddress = "target-has-no-address"
+)
+
+// Records returns every distinct record of the given type in the answer section
+// of every reply in results. Use Records to build the questions for a Step from
+// the answers to the previous step.
+func Records(results []*okaydns.CheckResult, rrtype uint16) (records []dns.RR) {
+	seen := make(map[string]bool)
+	for _, result := range results {
+		for _, answer := range result.Answers {
+			for _, rr := range answer.Answer {
+				if rr.Header().Rrtype != rrtype {
+					continue
+				}
+
+				if key := Canonical(rr); !seen[key] {
+					seen[key] = true
+					records = append(records, rr)
+				}
+			}
+		}
+	}
+	return records
+}
+
+// EveryNameHasAddress is a StepValidator that asserts that every name asked
+// about in a step has at least one A or AAAA record on every nameserver that
+// answered. Use it in a step that asks for the A and AAAA records of the
+// targets of records found in the previous step, like MX or SRV targets.
+func EveryNameHasAddress(_, results []*okaydns.CheckResult) (failures []okaydns.Failure) {
+	type key struct {
+		name       string
+		nameserver okaydns.Nameserver
+	}
+	found := make(map[key]bool)
+	var keys []key
+
+	for _, result := range results {
+		for nameserver, answer := range result.Answers {
+			q := result.Questions[nameserver]
+			if len(q.Question) == 0 {
+				continue
+			}
+
+			k := key{strings.ToLower(q.Question[0].Name), nameserver}
+			if _, ok := found[k]; !ok {
+				keys = append(keys, k)
+				found[k] = false
+			}
+			for _, rr := range answer.Answer {
+				if t := rr.Header().Rrtype; t == dns.TypeA || t == dns.TypeAAAA {
+					found[k] = true
+				}
+			}
+		}
+	}
+
+	for _, k := range keys {
+		if !found[k] {
+			failures = append(failures, okaydns.Failure{
+				Message:    fmt.Sprintf("%s has no A or AAAA records", k.name),
+				Nameserver: k.nameserver,
+				Code:       CodeNoAddress,
+			})
+		}
+	}
+	return failures
+}
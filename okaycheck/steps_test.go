@@ -0,0 +1,37 @@
+package okaycheck
+
+import (
+	"testing"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecords(t *testing.T) {
+	ns1 := okaydns.Nameserver{Hostname: "ns1.example.com.", IP: "192.0.2.1", Port: "53"}
+	ns2 := okaydns.Nameserver{Hostname: "ns2.example.com.", IP: "192.0.2.2", Port: "53"}
+
+	reply := func(rrs ...string) *dns.Msg {
+		m := new(dns.Msg)
+		for _, s := range rrs {
+			rr, err := dns.NewRR(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.Answer = append(m.Answer, rr)
+		}
+		return m
+	}
+
+	results := []*okaydns.CheckResult{{
+		Answers: map[okaydns.Nameserver]*dns.Msg{
+			ns1: reply("example.com. 300 IN MX 10 mail.example.com.", "example.com. 300 IN TXT \"hi\""),
+			ns2: reply("EXAMPLE.com. 60 IN MX 10 mail.example.com.", "example.com. 300 IN MX 20 backup.example.com."),
+		},
+	}}
+
+	assert.Len(t, Records(results, dns.TypeMX), 2)
+	assert.Len(t, Records(results, dns.TypeTXT), 1)
+	assert.Empty(t, Records(results, dns.TypeA))
+}
@@ -2,11 +2,38 @@ package okaycheck
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/blinsay/okaydns"
 	"github.com/miekg/dns"
 )
 
+// Codes for the failures returned by the validators in this package.
+const (
+	CodeResponseCode       = "response-code"
+	CodeNotAuthoritative   = "not-authoritative"
+	CodeUnexpectedAnswer   = "unexpected-answer"
+	CodeMissingAnswer      = "missing-answer"
+	CodeNegativeSOA        = "negative-response-soa"
+	CodeNegativeSOATTL     = "negative-response-soa-ttl"
+	CodeRecursionAvailable = "recursion-available"
+	CodeOutOfZoneAnswer    = "out-of-zone-answer"
+	CodeRecursiveAnswer    = "recursive-answer"
+	CodeUnexpectedReferral = "unexpected-referral"
+	CodeANYNotMinimal      = "any-not-minimal"
+	CodeMissingQuestion    = "missing-question"
+)
+
+// References to the RFC sections that describe the behavior checked by the
+// validators in this package.
+const (
+	RefHeader           = "https://tools.ietf.org/html/rfc1035#section-4.1.1"
+	RefNegativeResponse = "https://tools.ietf.org/html/rfc2308#section-3"
+	RefAuthoritative    = "https://tools.ietf.org/html/rfc1034#section-4.3.2"
+	RefReflectors       = "https://tools.ietf.org/html/rfc5358#section-4"
+	RefMinimalANY       = "https://tools.ietf.org/html/rfc8482#section-4"
+)
+
 // EachNameserver builds a RequestResponseValidator that runs the given
 // MessageValidators for each request-response pair. Failures are grouped by
 // nameserver.
@@ -36,6 +63,7 @@ func ResponseCode(rcode int) okaydns.MessageValidator {
 		if m.Rcode != rcode {
 			return []okaydns.Failure{{
 				Message: fmt.Sprintf("invalid response code: %s", dns.RcodeToString[m.Rcode]),
+				Code:    CodeResponseCode,
 			}}
 		}
 		return nil
@@ -46,7 +74,11 @@ func ResponseCode(rcode int) okaydns.MessageValidator {
 // Authoritative.
 func AuthoritativeResponse(m *dns.Msg) []okaydns.Failure {
 	if !m.Authoritative {
-		return []okaydns.Failure{{Message: "response was not authoritative"}}
+		return []okaydns.Failure{{
+			Message:   "response was not authoritative",
+			Code:      CodeNotAuthoritative,
+			Reference: RefAuthoritative,
+		}}
 	}
 	return nil
 }
@@ -57,6 +89,7 @@ func AnswerIsEmpty(m *dns.Msg) []okaydns.Failure {
 	if len(m.Answer) > 0 {
 		return []okaydns.Failure{{
 			Message: fmt.Sprintf("expected no Answers but got %d", len(m.Answer)),
+			Code:    CodeUnexpectedAnswer,
 		}}
 	}
 	return nil
@@ -73,6 +106,7 @@ func AnswerContains(rtype uint16) okaydns.MessageValidator {
 		}
 		return []okaydns.Failure{{
 			Message: fmt.Sprintf("response does not contain a %s record", dns.TypeToString[rtype]),
+			Code:    CodeMissingAnswer,
 		}}
 	}
 }
@@ -85,9 +119,248 @@ func AnswerDoesNotContain(rtype uint16) okaydns.MessageValidator {
 			if answer.Header().Rrtype == rtype {
 				return []okaydns.Failure{{
 					Message: fmt.Sprintf("response contains a %s record", dns.TypeToString[rtype]),
+					Code:    CodeUnexpectedAnswer,
 				}}
 			}
 		}
 		return nil
 	}
 }
+
+// NegativeResponseSOA is a MessageValidator that asserts a negative response
+// (NXDOMAIN or NODATA) carries the SOA of the zone the question belongs to in
+// the Authority section.
+//
+// RFC 2308 requires the TTL of that SOA to be the minimum of the SOA's own TTL
+// and the SOA MINIMUM field. A negative reply doesn't include the SOA's own
+// TTL, so this only asserts that the TTL is no larger than MINIMUM. Checks that
+// ask for the zone's SOA first should use NegativeTTLMatchesSOA to check the TTL
+// exactly.
+//
+// See https://tools.ietf.org/html/rfc2308#section-3
+func NegativeResponseSOA(m *dns.Msg) []okaydns.Failure {
+	if len(m.Question) != 1 {
+		return []okaydns.Failure{{Message: "missing a question", Code: CodeMissingQuestion}}
+	}
+	qname := m.Question[0].Name
+
+	var soas []*dns.SOA
+	for _, rr := range m.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			soas = append(soas, soa)
+		}
+	}
+
+	switch {
+	case len(soas) == 0:
+		return []okaydns.Failure{{
+			Message:   "negative response does not contain a SOA record in the authority section",
+			Code:      CodeNegativeSOA,
+			Reference: RefNegativeResponse,
+		}}
+	case len(soas) > 1:
+		return []okaydns.Failure{{
+			Message:   fmt.Sprintf("negative response contains %d SOA records in the authority section", len(soas)),
+			Severity:  okaydns.SeverityWarning,
+			Code:      CodeNegativeSOA,
+			Reference: RefNegativeResponse,
+		}}
+	}
+
+	soa := soas[0]
+	if !dns.IsSubDomain(soa.Hdr.Name, qname) {
+		return []okaydns.Failure{{
+			Message:   fmt.Sprintf("negative response SOA for %s is not for a zone containing %s", soa.Hdr.Name, qname),
+			Code:      CodeNegativeSOA,
+			Reference: RefNegativeResponse,
+		}}
+	}
+	if soa.Hdr.Ttl > soa.Minttl {
+		return []okaydns.Failure{{
+			Message:   fmt.Sprintf("negative response SOA TTL %d is larger than the SOA minimum %d", soa.Hdr.Ttl, soa.Minttl),
+			Code:      CodeNegativeSOATTL,
+			Reference: RefNegativeResponse,
+		}}
+	}
+	return nil
+}
+
+// NegativeTTLMatchesSOA is a StepValidator that asserts the TTL of the SOA in
+// every negative response in a step is exactly the minimum of the SOA record's
+// own TTL and its MINIMUM field. The SOA record for the zone must be in the
+// answers to the previous step, from the same nameserver, and the TTL fails if
+// it isn't.
+//
+// See https://tools.ietf.org/html/rfc2308#section-3
+func NegativeTTLMatchesSOA(previous, results []*okaydns.CheckResult) (failures []okaydns.Failure) {
+	soas := make(map[okaydns.Nameserver][]*dns.SOA)
+	for _, result := range previous {
+		for nameserver, answer := range result.Answers {
+			for _, rr := range answer.Answer {
+				if soa, ok := rr.(*dns.SOA); ok {
+					soas[nameserver] = append(soas[nameserver], soa)
+				}
+			}
+		}
+	}
+
+	for _, result := range results {
+		for nameserver, answer := range result.Answers {
+			for _, rr := range answer.Ns {
+				negative, ok := rr.(*dns.SOA)
+				if !ok {
+					continue
+				}
+
+				soa := findSOA(soas[nameserver], negative.Hdr.Name)
+				if soa == nil {
+					failures = append(failures, okaydns.Failure{
+						Message:    fmt.Sprintf("can't check the negative response SOA TTL, the %s SOA wasn't in the previous answer", negative.Hdr.Name),
+						Nameserver: nameserver,
+						Code:       CodeNegativeSOATTL,
+						Reference:  RefNegativeResponse,
+					})
+					continue
+				}
+
+				expected := soa.Hdr.Ttl
+				if soa.Minttl < expected {
+					expected = soa.Minttl
+				}
+				if negative.Hdr.Ttl != expected {
+					failures = append(failures, okaydns.Failure{
+						Message:    fmt.Sprintf("negative response SOA TTL is %d but should be %d, the minimum of the SOA TTL %d and MINIMUM %d", negative.Hdr.Ttl, expected, soa.Hdr.Ttl, soa.Minttl),
+						Nameserver: nameserver,
+						Code:       CodeNegativeSOATTL,
+						Reference:  RefNegativeResponse,
+					})
+				}
+			}
+		}
+	}
+	return failures
+}
+
+func findSOA(soas []*dns.SOA, name string) *dns.SOA {
+	for _, soa := range soas {
+		if strings.EqualFold(soa.Hdr.Name, name) {
+			return soa
+		}
+	}
+	return nil
+}
+
+// RecursionNotAvailable is a MessageValidator that asserts a response does not
+// have the RA bit set. Authoritative-only nameservers should never advertise
+// recursion.
+func RecursionNotAvailable(m *dns.Msg) []okaydns.Failure {
+	if m.RecursionAvailable {
+		return []okaydns.Failure{{
+			Message:   "response has recursion available (RA) set",
+			Code:      CodeRecursionAvailable,
+			Reference: RefHeader,
+		}}
+	}
+	return nil
+}
+
+// RefusedOrRootReferral is a MessageValidator that asserts a response to a
+// question outside of a nameserver's zones is either REFUSED or an empty
+// referral to the root zone. Responses with answers are either recursive
+// lookups or cached data, and responses with any other referral leak data the
+// nameserver isn't authoritative for.
+func RefusedOrRootReferral(m *dns.Msg) []okaydns.Failure {
+	if m.Rcode == dns.RcodeRefused {
+		return nil
+	}
+
+	if len(m.Answer) > 0 {
+		if m.Authoritative {
+			return []okaydns.Failure{{
+				Message:   fmt.Sprintf("response contains %d answers for out-of-zone data", len(m.Answer)),
+				Code:      CodeOutOfZoneAnswer,
+				Reference: RefReflectors,
+			}}
+		}
+		return []okaydns.Failure{{
+			Message:   fmt.Sprintf("response contains %d non-authoritative (recursive or cached) answers", len(m.Answer)),
+			Severity:  okaydns.SeverityCritical,
+			Code:      CodeRecursiveAnswer,
+			Reference: RefReflectors,
+		}}
+	}
+
+	if m.Rcode != dns.RcodeSuccess {
+		return []okaydns.Failure{{
+			Message: fmt.Sprintf("expected REFUSED or a referral to the root but got %s", dns.RcodeToString[m.Rcode]),
+			Code:    CodeResponseCode,
+		}}
+	}
+
+	if len(m.Ns) == 0 {
+		return []okaydns.Failure{{
+			Message: "expected REFUSED or a referral to the root but got an empty response",
+			Code:    CodeUnexpectedReferral,
+		}}
+	}
+	for _, rr := range m.Ns {
+		if rr.Header().Rrtype != dns.TypeNS || rr.Header().Name != "." {
+			return []okaydns.Failure{{
+				Message:  fmt.Sprintf("expected REFUSED or a referral to the root but got a %s record for %s", dns.TypeToString[rr.Header().Rrtype], rr.Header().Name),
+				Severity: okaydns.SeverityWarning,
+				Code:     CodeUnexpectedReferral,
+			}}
+		}
+	}
+	return nil
+}
+
+// MinimalANYResponse is a MessageValidator that asserts a response to an ANY
+// question is one of the minimal responses allowed by RFC 8482: a truncated
+// response, a single RRset (including a synthesized HINFO), or no answer at
+// all. Responses containing more than one RRset are a reflection and
+// amplification risk.
+//
+// See https://tools.ietf.org/html/rfc8482#section-4
+func MinimalANYResponse(m *dns.Msg) []okaydns.Failure {
+	if m.Truncated || len(m.Answer) == 0 {
+		return nil
+	}
+
+	type rrsetKey struct {
+		name   string
+		rrtype uint16
+		class  uint16
+	}
+	rrsets := make(map[rrsetKey]struct{})
+	for _, rr := range m.Answer {
+		hdr := rr.Header()
+		rrsets[rrsetKey{strings.ToLower(hdr.Name), hdr.Rrtype, hdr.Class}] = struct{}{}
+	}
+
+	if len(rrsets) > 1 {
+		return []okaydns.Failure{{
+			Message:   fmt.Sprintf("ANY response contains %d RRsets (%d records)", len(rrsets), len(m.Answer)),
+			Severity:  okaydns.SeverityWarning,
+			Code:      CodeANYNotMinimal,
+			Reference: RefMinimalANY,
+		}}
+	}
+	return nil
+}
+
+// ResponseCodeIn builds a MessageValidator that asserts the response code of a
+// message is any one of the given rcodes.
+func ResponseCodeIn(rcodes ...int) okaydns.MessageValidator {
+	return func(m *dns.Msg) []okaydns.Failure {
+		for _, rcode := range rcodes {
+			if m.Rcode == rcode {
+				return nil
+			}
+		}
+		return []okaydns.Failure{{
+			Message: fmt.Sprintf("invalid response code: %s", dns.RcodeToString[m.Rcode]),
+			Code:    CodeResponseCode,
+		}}
+	}
+}
@@ -184,3 +184,274 @@ func TestAnswerDoesNotContain(t *testing.T) {
 		},
 	})
 }
+
+func TestNegativeResponseSOA(t *testing.T) {
+	question := []dns.Question{{Name: "nope.example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}}
+	soa := func(name string, ttl, minttl uint32) *dns.SOA {
+		return &dns.SOA{
+			Hdr:    dns.RR_Header{Name: name, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: ttl},
+			Minttl: minttl,
+		}
+	}
+
+	validatorTests(t, []validatorTestCase{
+		{
+			"fails without a question",
+			NegativeResponseSOA,
+			&dns.Msg{Ns: []dns.RR{soa("example.com.", 300, 300)}},
+			true,
+		},
+		{
+			"fails without a SOA",
+			NegativeResponseSOA,
+			&dns.Msg{Question: question},
+			true,
+		},
+		{
+			"fails with a SOA in the answer section",
+			NegativeResponseSOA,
+			&dns.Msg{Question: question, Answer: []dns.RR{soa("example.com.", 300, 300)}},
+			true,
+		},
+		{
+			"fails with multiple SOAs",
+			NegativeResponseSOA,
+			&dns.Msg{Question: question, Ns: []dns.RR{soa("example.com.", 300, 300), soa("example.com.", 300, 300)}},
+			true,
+		},
+		{
+			"fails with a SOA for another zone",
+			NegativeResponseSOA,
+			&dns.Msg{Question: question, Ns: []dns.RR{soa("example.net.", 300, 300)}},
+			true,
+		},
+		{
+			"fails with a TTL larger than the minimum",
+			NegativeResponseSOA,
+			&dns.Msg{Question: question, Ns: []dns.RR{soa("example.com.", 3600, 300)}},
+			true,
+		},
+		{
+			"passes with a TTL equal to the minimum",
+			NegativeResponseSOA,
+			&dns.Msg{Question: question, Ns: []dns.RR{soa("example.com.", 300, 300)}},
+			false,
+		},
+		{
+			"passes with a TTL smaller than the minimum",
+			NegativeResponseSOA,
+			&dns.Msg{Question: question, Ns: []dns.RR{soa("EXAMPLE.com.", 60, 300)}},
+			false,
+		},
+	})
+}
+
+func TestNegativeTTLMatchesSOA(t *testing.T) {
+	ns := okaydns.Nameserver{Hostname: "ns1.example.com.", IP: "192.0.2.1", Port: "53"}
+
+	soa := func(ttl, minttl uint32) *dns.SOA {
+		return &dns.SOA{
+			Hdr:    dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: ttl},
+			Ns:     "ns1.example.com.",
+			Mbox:   "hostmaster.example.com.",
+			Minttl: minttl,
+		}
+	}
+	results := func(section string, rr dns.RR) []*okaydns.CheckResult {
+		m := new(dns.Msg)
+		switch {
+		case rr == nil:
+		case section == "answer":
+			m.Answer = []dns.RR{rr}
+		default:
+			m.Ns = []dns.RR{rr}
+		}
+		return []*okaydns.CheckResult{{Answers: map[okaydns.Nameserver]*dns.Msg{ns: m}}}
+	}
+
+	tcs := []struct {
+		name       string
+		soa        *dns.SOA
+		negative   *dns.SOA
+		shouldFail bool
+	}{
+		{"minimum is smaller", soa(300, 60), soa(60, 60), false},
+		{"ttl is smaller", soa(30, 60), soa(30, 60), false},
+		{"uses ttl instead of minimum", soa(300, 60), soa(300, 60), true},
+		{"decremented by a cache", soa(300, 60), soa(59, 60), true},
+		{"no soa to compare to", nil, soa(60, 60), true},
+	}
+
+	for _, tc := range tcs {
+		var previous dns.RR
+		if tc.soa != nil {
+			previous = tc.soa
+		}
+		failures := NegativeTTLMatchesSOA(results("answer", previous), results("authority", tc.negative))
+		if tc.shouldFail {
+			if assert.NotEmpty(t, failures, tc.name) {
+				assert.Equal(t, okaydns.SeverityError, failures[0].Severity, tc.name)
+			}
+		} else {
+			assert.Empty(t, failures, tc.name)
+		}
+	}
+}
+
+func TestRecursionNotAvailable(t *testing.T) {
+	validatorTests(t, []validatorTestCase{
+		{
+			"fails when RA is set",
+			RecursionNotAvailable,
+			&dns.Msg{MsgHdr: dns.MsgHdr{RecursionAvailable: true}},
+			true,
+		},
+		{
+			"passes when RA is not set",
+			RecursionNotAvailable,
+			&dns.Msg{},
+			false,
+		},
+	})
+}
+
+func TestRefusedOrRootReferral(t *testing.T) {
+	rootNS := &dns.NS{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeNS}, Ns: "a.root-servers.net."}
+	tldNS := &dns.NS{Hdr: dns.RR_Header{Name: "com.", Rrtype: dns.TypeNS}, Ns: "a.gtld-servers.net."}
+	rootSOA := &dns.SOA{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeSOA}}
+	answer := &dns.A{Hdr: dns.RR_Header{Name: "www.example.com.", Rrtype: dns.TypeA}, A: net.IPv4zero}
+
+	validatorTests(t, []validatorTestCase{
+		{
+			"passes on REFUSED",
+			RefusedOrRootReferral,
+			&dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeRefused}},
+			false,
+		},
+		{
+			"passes on a referral to the root",
+			RefusedOrRootReferral,
+			&dns.Msg{Ns: []dns.RR{rootNS}},
+			false,
+		},
+		{
+			"fails on a referral to a tld",
+			RefusedOrRootReferral,
+			&dns.Msg{Ns: []dns.RR{rootNS, tldNS}},
+			true,
+		},
+		{
+			"fails on an NXDOMAIN from the root",
+			RefusedOrRootReferral,
+			&dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeNameError}, Ns: []dns.RR{rootSOA}},
+			true,
+		},
+		{
+			"fails on an empty NOERROR",
+			RefusedOrRootReferral,
+			&dns.Msg{},
+			true,
+		},
+		{
+			"fails on SERVFAIL",
+			RefusedOrRootReferral,
+			&dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeServerFailure}},
+			true,
+		},
+		{
+			"fails on cached answers",
+			RefusedOrRootReferral,
+			&dns.Msg{Answer: []dns.RR{answer}},
+			true,
+		},
+		{
+			"fails on authoritative out-of-zone answers",
+			RefusedOrRootReferral,
+			&dns.Msg{MsgHdr: dns.MsgHdr{Authoritative: true}, Answer: []dns.RR{answer}},
+			true,
+		},
+	})
+}
+
+func TestMinimalANYResponse(t *testing.T) {
+	a := &dns.A{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET}, A: net.IPv4zero}
+	otherA := &dns.A{Hdr: dns.RR_Header{Name: "EXAMPLE.com.", Rrtype: dns.TypeA, Class: dns.ClassINET}, A: net.IPv4bcast}
+	aaaa := &dns.AAAA{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeAAAA, Class: dns.ClassINET}, AAAA: net.IPv6zero}
+	hinfo := &dns.HINFO{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeHINFO, Class: dns.ClassINET}, Cpu: "RFC8482"}
+
+	validatorTests(t, []validatorTestCase{
+		{
+			"passes on an empty answer",
+			MinimalANYResponse,
+			&dns.Msg{},
+			false,
+		},
+		{
+			"passes on a synthesized HINFO",
+			MinimalANYResponse,
+			&dns.Msg{Answer: []dns.RR{hinfo}},
+			false,
+		},
+		{
+			"passes on a single RRset",
+			MinimalANYResponse,
+			&dns.Msg{Answer: []dns.RR{a, otherA}},
+			false,
+		},
+		{
+			"passes on a truncated response",
+			MinimalANYResponse,
+			&dns.Msg{MsgHdr: dns.MsgHdr{Truncated: true}, Answer: []dns.RR{a, aaaa}},
+			false,
+		},
+		{
+			"fails on multiple RRsets",
+			MinimalANYResponse,
+			&dns.Msg{Answer: []dns.RR{a, aaaa, hinfo}},
+			true,
+		},
+	})
+}
+
+func TestResponseCodeIn(t *testing.T) {
+	validatorTests(t, []validatorTestCase{
+		{
+			"response code does not match",
+			ResponseCodeIn(dns.RcodeFormatError, dns.RcodeNotImplemented),
+			&dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeSuccess}},
+			true,
+		},
+		{
+			"response code matches",
+			ResponseCodeIn(dns.RcodeFormatError, dns.RcodeNotImplemented),
+			&dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeNotImplemented}},
+			false,
+		},
+	})
+}
+
+func TestFailureSeverities(t *testing.T) {
+	m := new(dns.Msg)
+	m.RecursionAvailable = true
+
+	m.Zero = true
+
+	failures := RecursionNotAvailable(m)
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, okaydns.SeverityError, failures[0].Severity)
+		assert.Equal(t, CodeRecursionAvailable, failures[0].Code)
+		assert.Equal(t, RefHeader, failures[0].Reference)
+	}
+
+	failures = ZeroZ(m)
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, okaydns.SeverityWarning, failures[0].Severity)
+		assert.Equal(t, CodeZBitSet, failures[0].Code)
+	}
+
+	failures = AuthoritativeResponse(m)
+	if assert.Len(t, failures, 1) {
+		assert.Equal(t, okaydns.SeverityError, failures[0].Severity)
+		assert.Equal(t, CodeNotAuthoritative, failures[0].Code)
+	}
+}
@@ -0,0 +1,217 @@
+package okaytest
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/miekg/dns"
+)
+
+// the largest number of CNAMEs followed in a single answer
+const maxCNAMEChain = 8
+
+// answer builds a reply to r from the zone.
+func (s *Server) answer(r *dns.Msg, misbehaviors Misbehavior) *dns.Msg {
+	reply := new(dns.Msg).SetReply(r)
+
+	switch {
+	case r.Opcode != dns.OpcodeQuery:
+		return reply.SetRcode(r, dns.RcodeNotImplemented)
+	case len(r.Question) != 1:
+		return reply.SetRcodeFormatError(r)
+	}
+
+	if opt := r.IsEdns0(); opt != nil && misbehaviors&IgnoreEDNS == 0 {
+		reply.SetEdns0(dns.DefaultMsgSize, opt.Do())
+		if opt.Version() != 0 {
+			reply.Rcode = dns.RcodeBadVers
+			return reply
+		}
+	}
+
+	q := r.Question[0]
+	switch {
+	case misbehaviors&Lame != 0:
+		reply.Rcode = dns.RcodeRefused
+		return reply
+	case q.Qclass != dns.ClassINET:
+		reply.Rcode = dns.RcodeRefused
+		return reply
+	case !dns.IsSubDomain(s.zone.Origin, q.Name):
+		reply.Rcode = dns.RcodeRefused
+		return reply
+	case misbehaviors&FormerrUnknownTypes != 0 && !knownType(q.Qtype):
+		return reply.SetRcodeFormatError(r)
+	}
+
+	if cut := s.delegation(q.Name); cut != "" {
+		s.referral(reply, cut)
+		return reply
+	}
+
+	reply.Authoritative = true
+	name := q.Name
+	for i := 0; i < maxCNAMEChain; i++ {
+		records := s.records(name)
+
+		qtype := q.Qtype
+		if qtype == dns.TypeANY && len(records) > 0 {
+			// answer ANY with a single RRset, as recommended by RFC 8482
+			qtype = records[0].Header().Rrtype
+		}
+
+		var matched []dns.RR
+		for _, rr := range records {
+			if rr.Header().Rrtype == qtype {
+				matched = append(matched, rr)
+			}
+		}
+		if len(matched) > 0 {
+			reply.Answer = append(reply.Answer, s.owned(name, matched, misbehaviors)...)
+			break
+		}
+
+		// follow CNAMEs that stay inside the zone
+		if cname := cnameIn(records); cname != nil {
+			reply.Answer = append(reply.Answer, s.owned(name, []dns.RR{cname}, misbehaviors)...)
+			name = cname.Target
+			if dns.IsSubDomain(s.zone.Origin, name) && s.delegation(name) == "" {
+				continue
+			}
+			break
+		}
+
+		// nothing at this name. a name with no records is either an empty
+		// non-terminal or doesn't exist.
+		if len(records) == 0 && !s.emptyNonTerminal(name) {
+			reply.Rcode = dns.RcodeNameError
+		}
+		reply.Ns = append(reply.Ns, s.negativeSOA(misbehaviors))
+		break
+	}
+
+	return reply
+}
+
+// records returns every record in the zone with the given owner name. If name
+// doesn't exist, records returns the records of the wildcard at its closest
+// encloser, if there is one.
+//
+// See https://tools.ietf.org/html/rfc4592#section-3.3.1
+func (s *Server) records(name string) []dns.RR {
+	if found := s.lookup(name); len(found) > 0 || s.emptyNonTerminal(name) {
+		return found
+	}
+
+	labels := dns.SplitDomainName(name)
+	for i := 1; i < len(labels); i++ {
+		encloser := dns.Fqdn(strings.Join(labels[i:], "."))
+		if !dns.IsSubDomain(s.zone.Origin, encloser) {
+			break
+		}
+		if len(s.lookup(encloser)) > 0 || s.emptyNonTerminal(encloser) {
+			return s.lookup("*." + encloser)
+		}
+	}
+	return nil
+}
+
+// lookup returns every record in the zone with the given owner name.
+func (s *Server) lookup(name string) (found []dns.RR) {
+	for _, rr := range s.zone.Records {
+		if strings.EqualFold(rr.Header().Name, name) {
+			found = append(found, rr)
+		}
+	}
+	return found
+}
+
+// owned returns copies of records with their owner names set to name, the way
+// a nameserver echoes the case of a question in its answer. Any misbehaviors
+// that affect answers are applied to the copies.
+func (s *Server) owned(name string, records []dns.RR, misbehaviors Misbehavior) []dns.RR {
+	copies := make([]dns.RR, len(records))
+	for i, rr := range records {
+		rr = dns.Copy(rr)
+		rr.Header().Name = name
+		if misbehaviors&WrongCase != 0 {
+			rr.Header().Name = swapCase(name)
+		}
+		if soa, ok := rr.(*dns.SOA); ok && misbehaviors&StaleSerial != 0 {
+			soa.Serial--
+		}
+		copies[i] = rr
+	}
+	return copies
+}
+
+// negativeSOA returns the SOA record for a negative answer, with the TTL set to
+// the lesser of the SOA TTL and the SOA MINIMUM.
+func (s *Server) negativeSOA(misbehaviors Misbehavior) dns.RR {
+	soa := dns.Copy(s.zone.SOA()).(*dns.SOA)
+	if soa.Minttl < soa.Hdr.Ttl {
+		soa.Hdr.Ttl = soa.Minttl
+	}
+	if misbehaviors&StaleSerial != 0 {
+		soa.Serial--
+	}
+	return soa
+}
+
+// emptyNonTerminal returns true if name has no records but there are records
+// below it in the zone.
+func (s *Server) emptyNonTerminal(name string) bool {
+	for _, rr := range s.zone.Records {
+		if owner := rr.Header().Name; !strings.EqualFold(owner, name) && dns.IsSubDomain(name, owner) {
+			return true
+		}
+	}
+	return false
+}
+
+// delegation returns the name of the zone cut at or above name, or an empty
+// string if name isn't delegated. The apex of the zone is never a cut.
+func (s *Server) delegation(name string) string {
+	for _, rr := range s.zone.Records {
+		cut := rr.Header().Name
+		if rr.Header().Rrtype == dns.TypeNS && !strings.EqualFold(cut, s.zone.Origin) && dns.IsSubDomain(cut, name) {
+			return cut
+		}
+	}
+	return ""
+}
+
+// referral fills in a non-authoritative referral to the nameservers for cut,
+// including any glue in the zone.
+func (s *Server) referral(reply *dns.Msg, cut string) {
+	for _, rr := range s.zone.Lookup(cut, dns.TypeNS) {
+		reply.Ns = append(reply.Ns, rr)
+
+		target := rr.(*dns.NS).Ns
+		reply.Extra = append(reply.Extra, s.zone.Lookup(target, dns.TypeA)...)
+		reply.Extra = append(reply.Extra, s.zone.Lookup(target, dns.TypeAAAA)...)
+	}
+}
+
+func cnameIn(records []dns.RR) *dns.CNAME {
+	for _, rr := range records {
+		if cname, ok := rr.(*dns.CNAME); ok {
+			return cname
+		}
+	}
+	return nil
+}
+
+func knownType(qtype uint16) bool {
+	_, ok := dns.TypeToString[qtype]
+	return ok
+}
+
+func swapCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
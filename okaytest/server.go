@@ -0,0 +1,241 @@
+// Package okaytest provides an in-process authoritative nameserver for testing
+// checks end to end.
+//
+// A Server serves a single zone from a string over UDP and TCP on a loopback
+// port. Servers can be told to misbehave in the ways real nameservers do, so
+// that checks can be tested against both good and bad behavior.
+//
+//	s, err := okaytest.NewServer(zoneText, "example.com.")
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer s.Close()
+//
+//	s.Set(okaytest.WrongCase)
+//	result := okaydns.DoCheck(&check, "example.com.", s.Nameservers())
+//
+// Tests that need a nameserver that behaves in a way a zone can't describe can
+// use Serve to answer every query with their own handler instead.
+package okaytest
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/zone"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// A Misbehavior is a way a Server can be told to misbehave. Misbehaviors can be
+// combined with a bitwise or.
+type Misbehavior uint
+
+const (
+	// DropTCP makes a Server close TCP connections without answering.
+	DropTCP Misbehavior = 1 << iota
+
+	// IgnoreEDNS makes a Server answer queries with EDNS as if they didn't have
+	// an OPT record.
+	IgnoreEDNS
+
+	// WrongCase makes a Server flip the case of the owner names in answers.
+	WrongCase
+
+	// Lame makes a Server answer every query with a non-authoritative REFUSED,
+	// like a nameserver that doesn't know it's been delegated a zone.
+	Lame
+
+	// StaleSerial makes a Server answer with a SOA serial one less than the
+	// serial in its zone.
+	StaleSerial
+
+	// FormerrUnknownTypes makes a Server answer questions for types it doesn't
+	// know with FORMERR.
+	FormerrUnknownTypes
+
+	// Truncate makes a Server set TC and drop all records from every answer
+	// over UDP.
+	Truncate
+)
+
+// A Server is an authoritative nameserver for a single zone. A Server's
+// misbehaviors and delay may be changed while it's running.
+type Server struct {
+	zone   *zone.Zone
+	handle dns.Handler
+	host   string
+	port   string
+
+	udp *dns.Server
+	tcp *dns.Server
+
+	mu           sync.RWMutex
+	misbehaviors Misbehavior
+	delay        time.Duration
+}
+
+// NewServer parses a zone file from zoneText and starts serving it over UDP
+// and TCP on the same loopback port. If origin is empty, the origin is the
+// owner of the zone's SOA record.
+func NewServer(zoneText, origin string) (*Server, error) {
+	z, err := zone.ParseString(zoneText, origin)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid zone")
+	}
+	if z.SOA() == nil {
+		return nil, errors.New("invalid zone: no SOA record")
+	}
+
+	s := &Server{zone: z}
+	if err := s.start(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Serve starts a Server that answers every query over UDP and TCP on the same
+// loopback port with handler. A Server started with Serve has no zone, so its
+// Origin is empty and only the DropTCP misbehavior and SetDelay have any
+// effect.
+func Serve(handler dns.Handler) (*Server, error) {
+	s := &Server{handle: handler}
+	if err := s.start(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Server) start() error {
+	pc, l, err := listen()
+	if err != nil {
+		return err
+	}
+	s.host, s.port, _ = net.SplitHostPort(pc.LocalAddr().String())
+
+	s.udp = &dns.Server{PacketConn: pc, Handler: s.handler(okaydns.ProtoUDP)}
+	s.tcp = &dns.Server{Listener: l, Handler: s.handler(okaydns.ProtoTCP)}
+	for _, server := range []*dns.Server{s.udp, s.tcp} {
+		started := make(chan struct{})
+		server.NotifyStartedFunc = func() { close(started) }
+		go server.ActivateAndServe()
+		<-started
+	}
+	return nil
+}
+
+// listen opens a UDP and TCP socket on the same loopback port.
+func listen() (net.PacketConn, net.Listener, error) {
+	const attempts = 10
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		var pc net.PacketConn
+		if pc, err = net.ListenPacket("udp", "127.0.0.1:0"); err != nil {
+			continue
+		}
+
+		var l net.Listener
+		if l, err = net.Listen("tcp", pc.LocalAddr().String()); err != nil {
+			pc.Close()
+			continue
+		}
+		return pc, l, nil
+	}
+	return nil, nil, errors.Wrap(err, "could not listen on loopback")
+}
+
+// Close stops the Server.
+func (s *Server) Close() error {
+	udpErr := s.udp.Shutdown()
+	tcpErr := s.tcp.Shutdown()
+	if udpErr != nil {
+		return udpErr
+	}
+	return tcpErr
+}
+
+// Origin returns the name of the zone the server is serving, or an empty
+// string if the Server was started with Serve.
+func (s *Server) Origin() string {
+	if s.zone == nil {
+		return ""
+	}
+	return s.zone.Origin
+}
+
+// Nameserver returns a Nameserver that connects to the Server with the given
+// protocol. Only ProtoUDP and ProtoTCP are supported.
+func (s *Server) Nameserver(proto okaydns.Proto) okaydns.Nameserver {
+	return okaydns.Nameserver{
+		Hostname: s.host,
+		IP:       s.host,
+		Port:     s.port,
+		Proto:    proto,
+	}
+}
+
+// Nameservers returns the Server's UDP Nameserver as a list, for passing
+// straight to a Checker.
+func (s *Server) Nameservers() []okaydns.Nameserver {
+	return []okaydns.Nameserver{s.Nameserver(okaydns.ProtoUDP)}
+}
+
+// Set adds misbehaviors to the server.
+func (s *Server) Set(m Misbehavior) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.misbehaviors |= m
+}
+
+// Clear removes misbehaviors from the server.
+func (s *Server) Clear(m Misbehavior) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.misbehaviors &^= m
+}
+
+// SetDelay makes the server wait for d before answering any query. A zero
+// delay answers immediately.
+func (s *Server) SetDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = d
+}
+
+func (s *Server) config() (Misbehavior, time.Duration) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.misbehaviors, s.delay
+}
+
+func (s *Server) handler(proto okaydns.Proto) dns.Handler {
+	return dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		misbehaviors, delay := s.config()
+
+		if proto == okaydns.ProtoTCP && misbehaviors&DropTCP != 0 {
+			w.Close()
+			return
+		}
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		if s.handle != nil {
+			s.handle.ServeDNS(w, r)
+			return
+		}
+
+		reply := s.answer(r, misbehaviors)
+		if proto == okaydns.ProtoUDP && misbehaviors&Truncate != 0 {
+			opt := reply.IsEdns0()
+			reply.Truncated = true
+			reply.Answer, reply.Ns, reply.Extra = nil, nil, nil
+			if opt != nil {
+				reply.Extra = []dns.RR{opt}
+			}
+		}
+		w.WriteMsg(reply)
+	})
+}
@@ -0,0 +1,206 @@
+package okaytest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blinsay/okaydns"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+const testZone = ExampleZone + `
+@             IN MX    10 mail
+mail          IN A     192.0.2.25
+www           IN CNAME @
+_sip._tcp     IN SRV   10 10 5060 sip
+sub           IN NS    ns.sub
+ns.sub        IN A     192.0.2.54
+`
+
+func exchange(t *testing.T, s *Server, proto okaydns.Proto, q *dns.Msg) *dns.Msg {
+	t.Helper()
+
+	nameserver := s.Nameserver(proto)
+	client := dns.Client{Net: proto.String(), Timeout: time.Second}
+	reply, _, err := client.Exchange(q, nameserver.Address())
+	if err != nil && err != dns.ErrTruncated {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+func TestServerAnswers(t *testing.T) {
+	s := Start(t, testZone, "example.com.")
+	assert.Equal(t, "example.com.", s.Origin())
+
+	tcs := []struct {
+		name          string
+		qname         string
+		qtype         uint16
+		rcode         int
+		authoritative bool
+		answers       int
+		authority     int
+	}{
+		{"apex A", "example.com.", dns.TypeA, dns.RcodeSuccess, true, 1, 0},
+		{"case-insensitive names", "ExAmPlE.CoM.", dns.TypeMX, dns.RcodeSuccess, true, 1, 0},
+		{"ANY is a single RRset", "example.com.", dns.TypeANY, dns.RcodeSuccess, true, 1, 0},
+		{"CNAMEs are followed", "www.example.com.", dns.TypeA, dns.RcodeSuccess, true, 2, 0},
+		{"CNAMEs are returned", "www.example.com.", dns.TypeCNAME, dns.RcodeSuccess, true, 1, 0},
+		{"NODATA", "example.com.", dns.TypeAAAA, dns.RcodeSuccess, true, 0, 1},
+		{"NXDOMAIN", "nope.example.com.", dns.TypeA, dns.RcodeNameError, true, 0, 1},
+		{"empty non-terminals", "_tcp.example.com.", dns.TypeA, dns.RcodeSuccess, true, 0, 1},
+		{"referrals", "www.sub.example.com.", dns.TypeA, dns.RcodeSuccess, false, 0, 1},
+		{"out-of-zone names", "example.net.", dns.TypeA, dns.RcodeRefused, false, 0, 0},
+	}
+
+	for _, tc := range tcs {
+		reply := exchange(t, s, okaydns.ProtoUDP, okaydns.NonRecursiveQuestion(tc.qname, tc.qtype))
+		assert.Equal(t, tc.rcode, reply.Rcode, "%s: rcode", tc.name)
+		assert.Equal(t, tc.authoritative, reply.Authoritative, "%s: authoritative", tc.name)
+		assert.Len(t, reply.Answer, tc.answers, "%s: answers", tc.name)
+		assert.Len(t, reply.Ns, tc.authority, "%s: authority", tc.name)
+	}
+}
+
+func TestServerEchoesCase(t *testing.T) {
+	s := Start(t, testZone, "example.com.")
+
+	reply := exchange(t, s, okaydns.ProtoUDP, okaydns.NonRecursiveQuestion("ExAmPlE.CoM.", dns.TypeA))
+	if assert.Len(t, reply.Answer, 1) {
+		assert.Equal(t, "ExAmPlE.CoM.", reply.Answer[0].Header().Name)
+	}
+
+	s.Set(WrongCase)
+	reply = exchange(t, s, okaydns.ProtoUDP, okaydns.NonRecursiveQuestion("ExAmPlE.CoM.", dns.TypeA))
+	if assert.Len(t, reply.Answer, 1) {
+		assert.Equal(t, "eXaMpLe.cOm.", reply.Answer[0].Header().Name)
+	}
+}
+
+func TestServerNegativeSOA(t *testing.T) {
+	s := Start(t, testZone, "example.com.")
+
+	reply := exchange(t, s, okaydns.ProtoUDP, okaydns.NonRecursiveQuestion("nope.example.com.", dns.TypeA))
+	if assert.Len(t, reply.Ns, 1) {
+		assert.Equal(t, uint32(60), reply.Ns[0].Header().Ttl, "negative TTL should be the SOA minimum")
+	}
+}
+
+func TestServerMisbehaviors(t *testing.T) {
+	s := Start(t, testZone, "example.com.")
+	soa := okaydns.NonRecursiveQuestion("example.com.", dns.TypeSOA)
+
+	s.Set(Lame)
+	reply := exchange(t, s, okaydns.ProtoUDP, soa)
+	assert.Equal(t, dns.RcodeRefused, reply.Rcode)
+	assert.False(t, reply.Authoritative)
+	s.Clear(Lame)
+
+	s.Set(StaleSerial)
+	reply = exchange(t, s, okaydns.ProtoUDP, soa)
+	if assert.Len(t, reply.Answer, 1) {
+		assert.Equal(t, uint32(2018010100), reply.Answer[0].(*dns.SOA).Serial)
+	}
+	s.Clear(StaleSerial)
+
+	s.Set(Truncate)
+	reply = exchange(t, s, okaydns.ProtoUDP, soa)
+	assert.True(t, reply.Truncated)
+	assert.Empty(t, reply.Answer)
+	reply = exchange(t, s, okaydns.ProtoTCP, soa)
+	assert.False(t, reply.Truncated, "TCP answers should never be truncated")
+	assert.Len(t, reply.Answer, 1)
+	s.Clear(Truncate)
+
+	edns := okaydns.NonRecursiveQuestion("example.com.", dns.TypeSOA).SetEdns0(4096, false)
+	assert.NotNil(t, exchange(t, s, okaydns.ProtoUDP, edns).IsEdns0())
+	s.Set(IgnoreEDNS)
+	assert.Nil(t, exchange(t, s, okaydns.ProtoUDP, edns).IsEdns0())
+	s.Clear(IgnoreEDNS)
+
+	unknown := okaydns.NonRecursiveQuestion("example.com.", 666)
+	assert.Equal(t, dns.RcodeSuccess, exchange(t, s, okaydns.ProtoUDP, unknown).Rcode)
+	s.Set(FormerrUnknownTypes)
+	assert.Equal(t, dns.RcodeFormatError, exchange(t, s, okaydns.ProtoUDP, unknown).Rcode)
+	s.Clear(FormerrUnknownTypes)
+
+	s.Set(DropTCP)
+	client := dns.Client{Net: "tcp", Timeout: 250 * time.Millisecond}
+	tcp := s.Nameserver(okaydns.ProtoTCP)
+	_, _, err := client.Exchange(soa, tcp.Address())
+	assert.Error(t, err, "TCP queries should fail")
+	exchange(t, s, okaydns.ProtoUDP, soa)
+	s.Clear(DropTCP)
+
+	s.SetDelay(500 * time.Millisecond)
+	client = dns.Client{Timeout: 100 * time.Millisecond}
+	udp := s.Nameserver(okaydns.ProtoUDP)
+	_, _, err = client.Exchange(soa, udp.Address())
+	assert.Error(t, err, "slow queries should time out")
+	s.SetDelay(0)
+}
+
+func TestNewServerInvalidZone(t *testing.T) {
+	_, err := NewServer("@ IN A 192.0.2.1\n", "example.com.")
+	assert.Error(t, err, "a zone without a SOA should be an error")
+
+	_, err = NewServer("@ IN A nope\n", "example.com.")
+	assert.Error(t, err, "an unparseable zone should be an error")
+}
+
+func TestServe(t *testing.T) {
+	s := StartHandler(t, dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		w.WriteMsg(new(dns.Msg).SetRcode(r, dns.RcodeNotImplemented))
+	}))
+	assert.Empty(t, s.Origin())
+
+	q := okaydns.NonRecursiveQuestion("example.com.", dns.TypeSOA)
+	for _, proto := range []okaydns.Proto{okaydns.ProtoUDP, okaydns.ProtoTCP} {
+		assert.Equal(t, dns.RcodeNotImplemented, exchange(t, s, proto, q).Rcode, "%s", proto)
+	}
+
+	s.Set(DropTCP | Lame)
+	client := dns.Client{Net: "tcp", Timeout: 250 * time.Millisecond}
+	tcp := s.Nameserver(okaydns.ProtoTCP)
+	_, _, err := client.Exchange(q, tcp.Address())
+	assert.Error(t, err, "TCP queries should fail")
+	assert.Equal(t, dns.RcodeNotImplemented, exchange(t, s, okaydns.ProtoUDP, q).Rcode, "misbehaviors that change answers should be ignored")
+}
+
+func TestServerWildcards(t *testing.T) {
+	s := Start(t, `
+$TTL 300
+@             IN SOA   ns1 hostmaster 2018010101 3600 600 86400 60
+@             IN NS    ns1
+ns1           IN A     192.0.2.53
+*             IN A     192.0.2.1
+*.sub         IN TXT   "sub"
+www.sub       IN A     192.0.2.2
+`, "example.com.")
+
+	tcs := []struct {
+		name    string
+		qname   string
+		qtype   uint16
+		rcode   int
+		answers int
+	}{
+		{"synthesized", "nope.example.com.", dns.TypeA, dns.RcodeSuccess, 1},
+		{"synthesized below", "a.b.example.com.", dns.TypeA, dns.RcodeSuccess, 1},
+		{"synthesized NODATA", "nope.example.com.", dns.TypeTXT, dns.RcodeSuccess, 0},
+		{"existing names", "ns1.example.com.", dns.TypeTXT, dns.RcodeSuccess, 0},
+		{"closest encloser", "nope.sub.example.com.", dns.TypeTXT, dns.RcodeSuccess, 1},
+		{"not the closest encloser", "nope.sub.example.com.", dns.TypeA, dns.RcodeSuccess, 0},
+		{"below an existing name", "nope.www.sub.example.com.", dns.TypeA, dns.RcodeNameError, 0},
+	}
+
+	for _, tc := range tcs {
+		reply := exchange(t, s, okaydns.ProtoUDP, okaydns.NonRecursiveQuestion(tc.qname, tc.qtype))
+		assert.Equal(t, tc.rcode, reply.Rcode, "%s: rcode", tc.name)
+		if assert.Len(t, reply.Answer, tc.answers, "%s: answers", tc.name) && tc.answers > 0 {
+			assert.Equal(t, tc.qname, reply.Answer[0].Header().Name, "%s: owner", tc.name)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package okaytest
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// ExampleZone is a minimal zone for tests: a SOA, an NS and an A record at the
+// apex, and an address for the nameserver. Names are relative, so that it can
+// be served at any origin, and tests can add the records they need to it.
+//
+//	s := okaytest.Start(t, okaytest.ExampleZone+"www IN CNAME @\n", "example.com.")
+const ExampleZone = `
+$TTL 300
+@             IN SOA   ns1 hostmaster 2018010101 3600 600 86400 60
+@             IN NS    ns1
+@             IN A     192.0.2.1
+ns1           IN A     192.0.2.53
+`
+
+// Start starts a Server for zoneText like NewServer, and closes it when the
+// test finishes. The test fails immediately if the Server can't start.
+func Start(t testing.TB, zoneText, origin string) *Server {
+	t.Helper()
+
+	s, err := NewServer(zoneText, origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// StartHandler starts a Server that answers with handler like Serve, and
+// closes it when the test finishes. The test fails immediately if the Server
+// can't start.
+func StartHandler(t testing.TB, handler dns.Handler) *Server {
+	t.Helper()
+
+	s, err := Serve(handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
@@ -0,0 +1,346 @@
+// Package policy checks that a domain's nameservers serve exactly what the
+// domain's owner expects them to.
+//
+// A Policy is a JSON or YAML document that describes the expected state of a zone: the
+// exact NS set, the apex addresses, MX hosts, CAA issuers, any other records
+// that must have specific values, records that must not exist, and a maximum
+// TTL. Every part of a Policy becomes a Check that runs against every
+// authoritative nameserver.
+//
+//	{
+//	  "name": "example",
+//	  "domain": "example.com.",
+//	  "ns": ["ns1.example.com.", "ns2.example.net."],
+//	  "a": ["192.0.2.1"],
+//	  "mx": [{"preference": 10, "host": "mail"}],
+//	  "caa_issuers": ["letsencrypt.org"],
+//	  "max_ttl": 3600,
+//	  "records": [{"name": "www", "type": "CNAME", "values": ["cdn.example.net."]}],
+//	  "absent": [{"name": "old", "type": "A"}]
+//	}
+//
+// YAML policies use the same field names.
+package policy
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/checkdef"
+	"github.com/blinsay/okaydns/okaycheck"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// CodePresentRecord is the code for records that a Policy says must not exist.
+// RRsets with the wrong values are reported with okaycheck.CodeRecordMismatch,
+// and records with a TTL above the maximum with okaycheck.CodeTTLOutOfRange.
+const CodePresentRecord = "present-record"
+
+// A Policy is the expected state of a domain.
+//
+// Name namespaces the IDs of the checks for a Policy, so that more than one
+// Policy can apply to the same domain. Policies without a Name generate check
+// IDs like "policy-ns", and a Policy named "example" generates IDs like
+// "policy-example-ns".
+//
+// Domain is the domain a Policy applies to. A Policy without a Domain applies
+// to every domain. All names in a Policy are relative to the domain being
+// checked unless they end in a ".".
+//
+// NS, A, AAAA and MX are the exact sets of those records at the apex of the
+// domain, and CAAIssuers is the exact set of CAA "issue" values at the apex.
+// Records lists any other RRsets that must have exactly the given values, and
+// Absent lists records that must not exist. Names in values and CAA issuers
+// are compared case-insensitively. Policy checks are only generated for the
+// parts of a Policy that are set.
+//
+// If MaxTTL is set, every record checked by a Policy must have a TTL at or
+// below it.
+type Policy struct {
+	Name       string   `json:"name,omitempty"`
+	Domain     string   `json:"domain,omitempty"`
+	NS         []string `json:"ns,omitempty"`
+	A          []string `json:"a,omitempty"`
+	AAAA       []string `json:"aaaa,omitempty"`
+	MX         []MX     `json:"mx,omitempty"`
+	CAAIssuers []string `json:"caa_issuers,omitempty"`
+	Records    []Record `json:"records,omitempty"`
+	Absent     []Absent `json:"absent,omitempty"`
+	MaxTTL     uint32   `json:"max_ttl,omitempty"`
+}
+
+// An MX is an expected mail exchanger.
+type MX struct {
+	Preference uint16 `json:"preference"`
+	Host       string `json:"host"`
+}
+
+// A Record is an RRset that must have exactly the given Values. Values are
+// record data in zone file syntax. A Record's MaxTTL overrides the MaxTTL of
+// its Policy.
+type Record struct {
+	Name   string   `json:"name,omitempty"`
+	Type   string   `json:"type"`
+	Values []string `json:"values"`
+	MaxTTL uint32   `json:"max_ttl,omitempty"`
+}
+
+// Absent is a record that must not exist. Any response without a record of the
+// given Type at Name, including NXDOMAIN, satisfies it.
+type Absent struct {
+	Name string `json:"name,omitempty"`
+	Type string `json:"type"`
+}
+
+// Load reads a JSON or YAML encoded Policy from r and validates it.
+func Load(r io.Reader) (*Policy, error) {
+	var p Policy
+	if err := checkdef.Decode(r, &p); err != nil {
+		return nil, errors.Wrap(err, "invalid policy")
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate checks that every part of a Policy can be turned into a Check.
+func (p *Policy) Validate() error {
+	domain := p.Domain
+	if domain == "" {
+		domain = "example.com."
+	}
+	_, err := p.checks(dns.Fqdn(domain))
+	return err
+}
+
+// Applies returns true if a Policy applies to fqdn.
+func (p *Policy) Applies(fqdn string) bool {
+	return p.Domain == "" || strings.EqualFold(dns.Fqdn(p.Domain), fqdn)
+}
+
+// Checks returns the checks for a Policy applied to fqdn.
+func (p *Policy) Checks(fqdn string) ([]okaydns.Check, error) {
+	if !p.Applies(fqdn) {
+		return nil, errors.Errorf("policy for %s doesn't apply to %s", p.Domain, fqdn)
+	}
+	return p.checks(fqdn)
+}
+
+func (p *Policy) checks(fqdn string) ([]okaydns.Check, error) {
+	var rrsets []*rrset
+
+	if len(p.NS) > 0 {
+		rrsets = append(rrsets, &rrset{id: "ns", name: fqdn, rrtype: dns.TypeNS, values: p.NS})
+	}
+	if len(p.A) > 0 {
+		rrsets = append(rrsets, &rrset{id: "a", name: fqdn, rrtype: dns.TypeA, values: p.A})
+	}
+	if len(p.AAAA) > 0 {
+		rrsets = append(rrsets, &rrset{id: "aaaa", name: fqdn, rrtype: dns.TypeAAAA, values: p.AAAA})
+	}
+	if len(p.MX) > 0 {
+		values := make([]string, len(p.MX))
+		for i, mx := range p.MX {
+			values[i] = fmt.Sprintf("%d %s", mx.Preference, mx.Host)
+		}
+		rrsets = append(rrsets, &rrset{id: "mx", name: fqdn, rrtype: dns.TypeMX, values: values})
+	}
+	if len(p.CAAIssuers) > 0 {
+		rrsets = append(rrsets, &rrset{id: "caa", name: fqdn, rrtype: dns.TypeCAA, values: p.CAAIssuers, parse: caaIssue, filter: isCAAIssue, key: caaIssuer})
+	}
+	for i, record := range p.Records {
+		rrtype, err := okaydns.ParseType(record.Type)
+		if err != nil {
+			return nil, errors.Wrapf(err, "record %d", i)
+		}
+		if len(record.Values) == 0 {
+			return nil, errors.Errorf("record %d: no values", i)
+		}
+		name := okaydns.RelativeName(record.Name, fqdn)
+		rrsets = append(rrsets, &rrset{
+			id:     "record-" + checkID(record.Name, rrtype),
+			name:   name,
+			rrtype: rrtype,
+			values: record.Values,
+			maxTTL: record.MaxTTL,
+		})
+	}
+
+	var checks []okaydns.Check
+	ids := make(map[string]bool)
+	for _, rrset := range rrsets {
+		if ids[rrset.id] {
+			return nil, errors.Errorf("%s: more than one policy for %s %s", rrset.id, rrset.name, dns.Type(rrset.rrtype))
+		}
+		ids[rrset.id] = true
+
+		if rrset.maxTTL == 0 {
+			rrset.maxTTL = p.MaxTTL
+		}
+		check, err := rrset.check(p.checkID(rrset.id), fqdn)
+		if err != nil {
+			return nil, errors.Wrap(err, rrset.id)
+		}
+		checks = append(checks, check)
+	}
+
+	for i, absent := range p.Absent {
+		rrtype, err := okaydns.ParseType(absent.Type)
+		if err != nil {
+			return nil, errors.Wrapf(err, "absent %d", i)
+		}
+		checks = append(checks, absentCheck(p.checkID("absent-"+checkID(absent.Name, rrtype)), okaydns.RelativeName(absent.Name, fqdn), rrtype))
+	}
+
+	return checks, nil
+}
+
+// checkID returns the ID of the check for a part of a Policy, namespaced by
+// the Policy's Name.
+func (p *Policy) checkID(id string) string {
+	if p.Name == "" {
+		return "policy-" + id
+	}
+	return "policy-" + p.Name + "-" + id
+}
+
+// an rrset is an RRset with an exact set of expected values. Each value is
+// turned into an expected record with parse, and compared to the records in
+// the answer that are selected by filter. By default, values are record data in
+// zone file syntax and whole records are compared in their canonical form.
+type rrset struct {
+	id     string
+	name   string
+	rrtype uint16
+	values []string
+	parse  func(fqdn, name string, rrtype uint16, value string) (dns.RR, error)
+	filter okaycheck.RecordFilter
+	key    func(dns.RR) string
+	maxTTL uint32
+}
+
+func (r *rrset) check(id, fqdn string) (okaydns.Check, error) {
+	if r.parse == nil {
+		r.parse = parseRecord
+	}
+
+	expected := make([]dns.RR, len(r.values))
+	for i, value := range r.values {
+		rr, err := r.parse(fqdn, r.name, r.rrtype, value)
+		if err != nil {
+			return okaydns.Check{}, err
+		}
+		expected[i] = rr
+	}
+
+	filters := []okaycheck.RecordFilter{okaycheck.Owner(r.name), okaycheck.OfType(r.rrtype)}
+	if r.filter != nil {
+		filters = append(filters, r.filter)
+	}
+	validators := []okaydns.MessageValidator{
+		okaycheck.AuthoritativeResponse,
+		okaycheck.ResponseCode(dns.RcodeSuccess),
+		okaycheck.RecordMatcher{
+			Section:   okaycheck.AnswerSection,
+			IgnoreTTL: true,
+			Filters:   filters,
+			Key:       r.key,
+		}.Validator(expected...),
+	}
+	if r.maxTTL > 0 {
+		validators = append(validators, okaycheck.TTLInRange(okaycheck.AnswerSection, 0, r.maxTTL, filters...))
+	}
+
+	name, rrtype := r.name, r.rrtype
+	return okaydns.Check{
+		ID:   id,
+		Name: fmt.Sprintf("Policy: %s %s", name, dns.Type(rrtype)),
+		Question: func(_ string) *dns.Msg {
+			return okaydns.NonRecursiveQuestion(name, rrtype)
+		},
+		Validators: []okaydns.RequestResponseValidator{
+			okaycheck.EachNameserver(validators...),
+		},
+	}, nil
+}
+
+// parseRecord parses record data in zone file syntax, with names relative to
+// fqdn, into a record owned by name.
+func parseRecord(fqdn, name string, rrtype uint16, value string) (dns.RR, error) {
+	rr, err := dns.NewRR(fmt.Sprintf("$ORIGIN %s\n%s 0 %s %s", fqdn, name, dns.Type(rrtype), value))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s value %q", dns.Type(rrtype), value)
+	}
+	if rr == nil {
+		return nil, errors.Errorf("invalid %s value %q", dns.Type(rrtype), value)
+	}
+	return rr, nil
+}
+
+func absentCheck(id, name string, rrtype uint16) okaydns.Check {
+	return okaydns.Check{
+		ID:   id,
+		Name: fmt.Sprintf("Policy: no %s %s", name, dns.Type(rrtype)),
+		Question: func(_ string) *dns.Msg {
+			return okaydns.NonRecursiveQuestion(name, rrtype)
+		},
+		Validators: []okaydns.RequestResponseValidator{
+			okaycheck.EachNameserver(
+				okaycheck.AuthoritativeResponse,
+				okaycheck.ResponseCodeIn(dns.RcodeSuccess, dns.RcodeNameError),
+				func(m *dns.Msg) (failures []okaydns.Failure) {
+					for _, rr := range owned(m, name, rrtype) {
+						failures = append(failures, okaydns.Failure{
+							Message: fmt.Sprintf("record should not exist: %s", rr),
+							Code:    CodePresentRecord,
+						})
+					}
+					return failures
+				},
+			),
+		},
+	}
+}
+
+// owned returns the records in the answer section of m with the given owner
+// and type.
+func owned(m *dns.Msg, name string, rrtype uint16) (records []dns.RR) {
+	for _, rr := range m.Answer {
+		if hdr := rr.Header(); hdr.Rrtype == rrtype && strings.EqualFold(hdr.Name, name) {
+			records = append(records, rr)
+		}
+	}
+	return records
+}
+
+// caaIssue parses a CAA issuer into an "issue" record owned by name.
+func caaIssue(_, name string, _ uint16, value string) (dns.RR, error) {
+	return &dns.CAA{
+		Hdr:   dns.RR_Header{Name: name, Rrtype: dns.TypeCAA, Class: dns.ClassINET},
+		Tag:   "issue",
+		Value: value,
+	}, nil
+}
+
+// isCAAIssue selects CAA "issue" records.
+func isCAAIssue(rr dns.RR) bool {
+	caa, ok := rr.(*dns.CAA)
+	return ok && strings.EqualFold(caa.Tag, "issue")
+}
+
+// caaIssuer returns the lowercased issuer of a CAA record.
+func caaIssuer(rr dns.RR) string {
+	return strings.ToLower(rr.(*dns.CAA).Value)
+}
+
+func checkID(name string, rrtype uint16) string {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || name == "@" {
+		name = "apex"
+	}
+	return strings.ToLower(strings.Replace(name, ".", "-", -1) + "-" + dns.Type(rrtype).String())
+}
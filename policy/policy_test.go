@@ -0,0 +1,198 @@
+package policy_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/okaycheck"
+	"github.com/blinsay/okaydns/okaytest"
+	"github.com/blinsay/okaydns/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+const testZone = okaytest.ExampleZone + `
+@             IN NS    ns2
+@             IN MX    10 mail
+@             IN MX    20 backup.example.net.
+@             IN CAA   0 issue "letsencrypt.org"
+@             IN CAA   0 iodef "mailto:security@example.com"
+ns2           IN A     192.0.2.54
+mail          IN A     192.0.2.25
+www    3600   IN CNAME cdn.example.net.
+`
+
+func TestLoadErrors(t *testing.T) {
+	tcs := []struct {
+		name   string
+		policy string
+		err    string
+	}{
+		{"not json or yaml", `ns: [`, "invalid policy"},
+		{"unknown field", `{"nameservers": ["ns1"]}`, "unknown field"},
+		{"unknown yaml field", `nameservers: [ns1]`, "unknown field"},
+		{"bad address", `{"a": ["not-an-ip"]}`, `a: invalid A value "not-an-ip"`},
+		{"bad record type", `{"records": [{"type": "NOPE", "values": ["x"]}]}`, `record 0: unknown type: "NOPE"`},
+		{"record without values", `{"records": [{"name": "www", "type": "CNAME"}]}`, "record 0: no values"},
+		{"duplicate record", `{"records": [{"type": "TXT", "values": ["a"]}, {"name": "@", "type": "TXT", "values": ["b"]}]}`, "more than one policy"},
+		{"bad absent type", `{"absent": [{"type": "NOPE"}]}`, `absent 0: unknown type: "NOPE"`},
+	}
+
+	for _, tc := range tcs {
+		_, err := policy.Load(strings.NewReader(tc.policy))
+		if assert.Error(t, err, tc.name) {
+			assert.Contains(t, err.Error(), tc.err, tc.name)
+		}
+	}
+}
+
+func TestLoadYAML(t *testing.T) {
+	p, err := policy.Load(strings.NewReader(`
+name: example
+domain: example.com.
+ns: [ns1.example.com., ns2.example.net.]
+mx:
+  - preference: 10
+    host: mail
+max_ttl: 3600
+`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, &policy.Policy{
+			Name:   "example",
+			Domain: "example.com.",
+			NS:     []string{"ns1.example.com.", "ns2.example.net."},
+			MX:     []policy.MX{{Preference: 10, Host: "mail"}},
+			MaxTTL: 3600,
+		}, p)
+	}
+}
+
+func TestCheckIDs(t *testing.T) {
+	tcs := []struct {
+		name     string
+		policy   policy.Policy
+		expected []string
+	}{
+		{"unnamed", policy.Policy{NS: []string{"ns1"}, Absent: []policy.Absent{{Name: "old", Type: "A"}}}, []string{"policy-ns", "policy-absent-old-a"}},
+		{"named", policy.Policy{Name: "prod", NS: []string{"ns1"}, Absent: []policy.Absent{{Name: "old", Type: "A"}}}, []string{"policy-prod-ns", "policy-prod-absent-old-a"}},
+	}
+
+	for _, tc := range tcs {
+		checks, err := tc.policy.Checks("example.com.")
+		if assert.NoError(t, err, tc.name) {
+			var ids []string
+			for _, check := range checks {
+				ids = append(ids, check.ID)
+			}
+			assert.Equal(t, tc.expected, ids, tc.name)
+		}
+	}
+}
+
+func TestApplies(t *testing.T) {
+	p := policy.Policy{Domain: "Example.com"}
+	assert.True(t, p.Applies("example.com."))
+	assert.False(t, p.Applies("example.net."))
+
+	_, err := p.Checks("example.net.")
+	assert.Error(t, err)
+
+	p = policy.Policy{}
+	assert.True(t, p.Applies("example.net."))
+}
+
+func TestPolicyChecks(t *testing.T) {
+	s := okaytest.Start(t, testZone, "example.com.")
+
+	p, err := policy.Load(strings.NewReader(`{
+		"domain": "example.com",
+		"ns": ["ns1", "ns2.example.com."],
+		"a": ["192.0.2.1", "192.0.2.2"],
+		"mx": [{"preference": 10, "host": "MAIL"}, {"preference": 20, "host": "backup.example.net."}],
+		"caa_issuers": ["LetsEncrypt.org"],
+		"max_ttl": 600,
+		"records": [
+			{"name": "www", "type": "CNAME", "values": ["cdn.example.net."], "max_ttl": 3600},
+			{"name": "mail", "type": "A", "values": ["192.0.2.25"]}
+		],
+		"absent": [
+			{"name": "old", "type": "A"},
+			{"name": "www", "type": "CNAME"}
+		]
+	}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	checks, err := p.Checks(s.Origin())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	results := okaydns.DoChecks(checks, s.Origin(), s.Nameservers())
+	statuses := make(map[string]okaydns.Status)
+	failures := make(map[string][]string)
+	for i, result := range results {
+		statuses[checks[i].ID] = result.Status()
+		for _, failure := range result.Failures {
+			failures[checks[i].ID] = append(failures[checks[i].ID], failure.Code)
+		}
+	}
+
+	assert.Equal(t, map[string]okaydns.Status{
+		"policy-ns":               okaydns.StatusOK,
+		"policy-a":                okaydns.StatusFailed,
+		"policy-mx":               okaydns.StatusOK,
+		"policy-caa":              okaydns.StatusOK,
+		"policy-record-www-cname": okaydns.StatusOK,
+		"policy-record-mail-a":    okaydns.StatusOK,
+		"policy-absent-old-a":     okaydns.StatusOK,
+		"policy-absent-www-cname": okaydns.StatusFailed,
+	}, statuses)
+	assert.Equal(t, []string{okaycheck.CodeRecordMismatch}, failures["policy-a"])
+	assert.Equal(t, []string{policy.CodePresentRecord}, failures["policy-absent-www-cname"])
+}
+
+func TestPolicyValues(t *testing.T) {
+	s := okaytest.Start(t, testZone, "example.com.")
+
+	tcs := []struct {
+		name     string
+		policy   policy.Policy
+		failures []string
+	}{
+		{
+			"extra ns",
+			policy.Policy{NS: []string{"ns1"}},
+			[]string{okaycheck.CodeRecordMismatch},
+		},
+		{
+			"wrong mx preference",
+			policy.Policy{MX: []policy.MX{{Preference: 10, Host: "mail"}, {Preference: 30, Host: "backup.example.net."}}},
+			[]string{okaycheck.CodeRecordMismatch},
+		},
+		{
+			"wrong caa issuer",
+			policy.Policy{CAAIssuers: []string{"pki.goog"}},
+			[]string{okaycheck.CodeRecordMismatch},
+		},
+		{
+			"ttl too high",
+			policy.Policy{MaxTTL: 60, Records: []policy.Record{{Name: "www", Type: "CNAME", Values: []string{"cdn.example.net."}}}},
+			[]string{okaycheck.CodeTTLOutOfRange},
+		},
+	}
+
+	for _, tc := range tcs {
+		checks, err := tc.policy.Checks(s.Origin())
+		if !assert.NoError(t, err, tc.name) || !assert.Len(t, checks, 1, tc.name) {
+			continue
+		}
+
+		var codes []string
+		result := okaydns.DoCheck(&checks[0], s.Origin(), s.Nameservers())
+		for _, failure := range result.Failures {
+			codes = append(codes, failure.Code)
+		}
+		assert.Equal(t, tc.failures, codes, tc.name)
+	}
+}
@@ -0,0 +1,177 @@
+package okaydns
+
+import (
+	"fmt"
+)
+
+// DoChecks runs checks with the default Checker, making sure that every check
+// runs after its prerequisites. Results are returned in the same order as
+// checks.
+//
+// A check is skipped if any of its prerequisites can't be found, was skipped,
+// or failed in a way that isn't specific to a nameserver. When a prerequisite
+// failed for some nameservers, a check only runs on the nameservers that passed
+// it. Nameservers are matched by address, ignoring their protocol, so a TCP
+// check can require a check done over UDP.
+func DoChecks(checks []Check, fqdn string, nameservers []Nameserver) []*CheckResult {
+	s := scheduler{
+		checks:  checks,
+		fqdn:    fqdn,
+		nss:     nameservers,
+		byName:  make(map[string]int, len(checks)),
+		results: make([]*CheckResult, len(checks)),
+		state:   make([]int, len(checks)),
+	}
+	for i, check := range checks {
+		if _, ok := s.byName[check.Identifier()]; !ok {
+			s.byName[check.Identifier()] = i
+		}
+	}
+
+	for i := range checks {
+		s.run(i)
+	}
+	return s.results
+}
+
+const (
+	unvisited = iota
+	visiting
+	visited
+)
+
+type scheduler struct {
+	checks  []Check
+	fqdn    string
+	nss     []Nameserver
+	byName  map[string]int
+	results []*CheckResult
+	state   []int
+}
+
+// nameserverKey identifies a nameserver regardless of protocol.
+type nameserverKey struct {
+	hostname, ip, port string
+}
+
+func keyOf(nameserver Nameserver) nameserverKey {
+	return nameserverKey{nameserver.Hostname, nameserver.IP, nameserver.Port}
+}
+
+func (s *scheduler) run(i int) {
+	if s.state[i] != unvisited {
+		return
+	}
+	s.state[i] = visiting
+	defer func() { s.state[i] = visited }()
+
+	check := s.checks[i]
+
+	skip := make(map[nameserverKey]string)
+	for _, name := range check.Requires {
+		j, ok := s.byName[name]
+		if !ok {
+			s.results[i] = skipped(check, fmt.Sprintf("unknown prerequisite %q", name))
+			return
+		}
+
+		s.run(j)
+		prerequisite := s.results[j]
+		switch {
+		case prerequisite == nil:
+			s.results[i] = skipped(check, fmt.Sprintf("prerequisite %q depends on this check", name))
+			return
+		case prerequisite.Skipped:
+			s.results[i] = skipped(check, fmt.Sprintf("prerequisite %q was skipped", name))
+			return
+		}
+
+		failed, global := failedNameservers(prerequisite)
+		if global {
+			s.results[i] = skipped(check, fmt.Sprintf("prerequisite %q failed", name))
+			return
+		}
+		for nameserver := range failed {
+			skip[keyOf(nameserver)] = fmt.Sprintf("failed prerequisite %q", name)
+		}
+		for nameserver, reason := range prerequisite.SkippedNameservers {
+			skip[keyOf(nameserver)] = reason
+		}
+	}
+
+	skippedNameservers := make(map[Nameserver]string)
+	configure := check.ConfigureNameservers
+	check.ConfigureNameservers = func(nameservers []Nameserver) []Nameserver {
+		if configure != nil {
+			nameservers = configure(nameservers)
+		}
+
+		var filtered []Nameserver
+		for _, nameserver := range nameservers {
+			if reason, ok := skip[keyOf(nameserver)]; ok {
+				skippedNameservers[nameserver] = reason
+				continue
+			}
+			filtered = append(filtered, nameserver)
+		}
+		return filtered
+	}
+
+	if len(skip) > 0 {
+		// check before running anything, so a check with every nameserver
+		// skipped doesn't send any queries.
+		if len(check.ConfigureNameservers(s.nss)) == 0 {
+			s.results[i] = skipped(s.checks[i], "every nameserver failed a prerequisite")
+			s.results[i].SkippedNameservers = skippedNameservers
+			return
+		}
+	}
+
+	result := DoCheck(&check, s.fqdn, s.nss)
+	if len(skippedNameservers) > 0 {
+		result.SkippedNameservers = skippedNameservers
+	}
+	s.results[i] = result
+}
+
+func skipped(check Check, reason string) *CheckResult {
+	return &CheckResult{
+		Name:       check.Name,
+		Skipped:    true,
+		SkipReason: reason,
+	}
+}
+
+// failedNameservers returns the nameservers that failed a check, including in
+// any of its steps. global is true if the check failed in a way that isn't
+// specific to any one nameserver.
+func failedNameservers(result *CheckResult) (failed map[Nameserver]bool, global bool) {
+	failed = make(map[Nameserver]bool)
+
+	var collect func(result *CheckResult)
+	collect = func(result *CheckResult) {
+		for nameserver := range result.Errors {
+			if !result.TolerateErrors {
+				failed[nameserver] = true
+			}
+		}
+		for _, failure := range result.Failures {
+			if failure.Severity < SeverityError {
+				continue
+			}
+			if failure.Nameserver.IsZero() {
+				global = true
+				continue
+			}
+			failed[failure.Nameserver] = true
+		}
+		for _, step := range result.Steps {
+			for _, stepResult := range step.Results {
+				collect(stepResult)
+			}
+		}
+	}
+	collect(result)
+
+	return failed, global
+}
@@ -0,0 +1,96 @@
+package okaydns_test
+
+import (
+	"testing"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/okaycheck"
+	"github.com/blinsay/okaydns/okaytest"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func soaCheck(name string, requires ...string) okaydns.Check {
+	return okaydns.Check{
+		Name:     name,
+		Requires: requires,
+		Question: func(fqdn string) *dns.Msg {
+			return okaydns.NonRecursiveQuestion(fqdn, dns.TypeSOA)
+		},
+		Validators: []okaydns.RequestResponseValidator{
+			okaycheck.EachNameserver(okaycheck.AuthoritativeResponse),
+		},
+	}
+}
+
+func failingCheck(name string, requires ...string) okaydns.Check {
+	check := soaCheck(name, requires...)
+	check.Validators = append(check.Validators, func(_ *dns.Msg, _ map[okaydns.Nameserver]*dns.Msg) []okaydns.Failure {
+		return []okaydns.Failure{{Message: "always fails"}}
+	})
+	return check
+}
+
+func TestDoChecksPrerequisites(t *testing.T) {
+	s := okaytest.Start(t, okaytest.ExampleZone, "example.com.")
+
+	checks := []okaydns.Check{
+		soaCheck("needs base", "base"),
+		soaCheck("base"),
+		failingCheck("broken", "base"),
+		soaCheck("needs broken", "broken"),
+		soaCheck("needs needs broken", "needs broken"),
+		soaCheck("needs nothing real", "nope"),
+		soaCheck("cycle a", "cycle b"),
+		soaCheck("cycle b", "cycle a"),
+	}
+
+	results := okaydns.DoChecks(checks, s.Origin(), s.Nameservers())
+	if !assert.Len(t, results, len(checks)) {
+		return
+	}
+	for i, result := range results {
+		assert.Equal(t, checks[i].Name, result.Name, "results should be in the same order as checks")
+	}
+
+	assert.Equal(t, okaydns.StatusOK, results[0].Status())
+	assert.Equal(t, okaydns.StatusOK, results[1].Status())
+	assert.Equal(t, okaydns.StatusFailed, results[2].Status())
+
+	for _, result := range results[3:] {
+		assert.Equal(t, okaydns.StatusSkipped, result.Status(), result.Name)
+		assert.True(t, result.Success(), "skipped checks don't fail")
+		assert.NotEmpty(t, result.SkipReason, result.Name)
+		assert.Empty(t, result.Exchanges, "skipped checks shouldn't send queries")
+	}
+	assert.Contains(t, results[3].SkipReason, `"broken" failed`)
+	assert.Contains(t, results[4].SkipReason, `"needs broken" was skipped`)
+	assert.Contains(t, results[5].SkipReason, `unknown prerequisite "nope"`)
+}
+
+func TestDoChecksPrerequisitesPerNameserver(t *testing.T) {
+	ok := okaytest.Start(t, okaytest.ExampleZone, "example.com.")
+	lame := okaytest.Start(t, okaytest.ExampleZone, "example.com.")
+	lame.Set(okaytest.Lame)
+
+	nameservers := append(ok.Nameservers(), lame.Nameservers()...)
+	tcp := soaCheck("over tcp", "base")
+	tcp.ConfigureNameservers = func(nameservers []okaydns.Nameserver) (tcp []okaydns.Nameserver) {
+		for _, nameserver := range nameservers {
+			nameserver.Proto = okaydns.ProtoTCP
+			tcp = append(tcp, nameserver)
+		}
+		return tcp
+	}
+
+	results := okaydns.DoChecks([]okaydns.Check{soaCheck("base"), tcp}, ok.Origin(), nameservers)
+	if !assert.Len(t, results, 2) {
+		return
+	}
+	assert.Equal(t, okaydns.StatusFailed, results[0].Status())
+
+	result := results[1]
+	assert.Equal(t, okaydns.StatusOK, result.Status(), "%v %v", result.Failures, result.Errors)
+	assert.Equal(t, []okaydns.Nameserver{ok.Nameserver(okaydns.ProtoTCP)}, result.Nameservers)
+	assert.Contains(t, result.SkippedNameservers, lame.Nameserver(okaydns.ProtoTCP))
+}
@@ -0,0 +1,527 @@
+// Package stdchecks is a library of standard checks for authoritative
+// nameservers. Every check is an exported okaydns.Check that can be run on its
+// own, or looked up and selected by tag from a Registry.
+package stdchecks
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/okaycheck"
+	"github.com/miekg/dns"
+)
+
+// Checks that there is an A record and no CNAME at the given domain. This is a
+// basic sanity check.
+var CheckA = okaydns.Check{
+	ID:   "a",
+	Name: "A record",
+	Question: func(fqdn string) *dns.Msg {
+		return okaydns.NonRecursiveQuestion(fqdn, dns.TypeA)
+	},
+	Validators: []okaydns.RequestResponseValidator{
+		okaycheck.EachNameserver(
+			okaycheck.AuthoritativeResponse,
+			okaycheck.ResponseCode(dns.RcodeSuccess),
+			okaycheck.AnswerContains(dns.TypeA),
+		),
+	},
+}
+
+// Validates that the reply to the A record question echoes the ID, opcode and
+// question of the query, has QR set, and has the Z bit cleared.
+var CheckAEchoesQuery = okaydns.Check{
+	ID:       "a-echoes-query",
+	Name:     "A record echoes the query",
+	Requires: []string{CheckA.ID},
+	Question: func(fqdn string) *dns.Msg {
+		return okaydns.NonRecursiveQuestion(fqdn, dns.TypeA)
+	},
+	Validators: []okaydns.RequestResponseValidator{
+		okaycheck.EchoesQuery(),
+	},
+}
+
+// Validates that the answer to the A record question is made of whole RRsets
+// that follow RFC 2181, and that every record in it is on the CNAME chain from
+// the question name.
+//
+// See:
+// - https://tools.ietf.org/html/rfc2181#section-5
+var CheckARRsets = okaydns.Check{
+	ID:       "a-rrsets",
+	Name:     "A record RRsets",
+	Requires: []string{CheckA.ID},
+	Question: func(fqdn string) *dns.Msg {
+		return okaydns.NonRecursiveQuestion(fqdn, dns.TypeA)
+	},
+	Validators: []okaydns.RequestResponseValidator{
+		okaycheck.EachNameserver(
+			okaycheck.RRsetIntegrity,
+		),
+		okaycheck.AnswerInChain(),
+	},
+}
+
+// Validates that an A query succeeds over TCP. Uses the same validations as
+// the CheckA check.
+var CheckAOverTCP = okaydns.Check{
+	ID:       "a-tcp",
+	Name:     "A record (TCP)",
+	Requires: []string{CheckA.ID},
+	Question: func(fqdn string) *dns.Msg {
+		return okaydns.NonRecursiveQuestion(fqdn, dns.TypeA)
+	},
+	ConfigureNameservers: overTCP,
+	Validators: []okaydns.RequestResponseValidator{
+		okaycheck.EachNameserver(
+			okaycheck.AuthoritativeResponse,
+			okaycheck.ResponseCode(dns.RcodeSuccess),
+		),
+	},
+}
+
+// overTCP configures a check to run against every nameserver over TCP.
+func overTCP(nameservers []okaydns.Nameserver) []okaydns.Nameserver {
+	tcpns := make([]okaydns.Nameserver, len(nameservers))
+	for i, ns := range nameservers {
+		tcpns[i] = ns
+		tcpns[i].Proto = okaydns.ProtoTCP
+	}
+	return tcpns
+}
+
+var CheckNoCNAMEAtRoot = okaydns.Check{
+	ID:       "no-cname-at-apex",
+	Name:     "Not a CNAME",
+	Requires: []string{CheckSOA.ID},
+	Question: func(fqdn string) *dns.Msg {
+		return okaydns.NonRecursiveQuestion(fqdn, dns.TypeCNAME)
+	},
+	Validators: []okaydns.RequestResponseValidator{
+		okaycheck.EachNameserver(
+			okaycheck.AuthoritativeResponse,
+			okaycheck.ResponseCode(dns.RcodeSuccess),
+			okaycheck.AnswerIsEmpty,
+		),
+	},
+}
+
+// Checks that nameservers respond to queries with the same capitalization of
+// domains as the question. Every nameserver gets a differently randomized
+// question, so a nameserver can't pass by answering from a shared cache.
+//
+// See:
+// - https://tools.ietf.org/html/draft-vixie-dnsext-dns0x20-00
+var Check0x20 = okaydns.Check{
+	ID:       "0x20",
+	Name:     "Handles 0x20 randomization",
+	Requires: []string{CheckA.ID},
+	NameserverQuestion: func(fqdn string, _ okaydns.Nameserver) *dns.Msg {
+		return okaydns.NonRecursiveQuestion(okaydns.RandomizeCase(fqdn), dns.TypeA)
+	},
+	Validators: []okaydns.RequestResponseValidator{
+		okaycheck.EachNameserver(
+			okaycheck.AuthoritativeResponse,
+			okaycheck.ResponseCode(dns.RcodeSuccess),
+			okaycheck.AnswerContains(dns.TypeA),
+		),
+		okaycheck.EchoesQuery(),
+		okaycheck.EchoesCase(),
+	},
+}
+
+// Validates that nameservers respond to a question with an unknown query syntax
+// by returning an empty answer with an okay response code.
+var CheckUnknownQuestion = okaydns.Check{
+	ID:       "unknown-qtype",
+	Name:     "Handles unknown question types",
+	Requires: []string{CheckSOA.ID},
+	Question: func(fqdn string) *dns.Msg {
+		return okaydns.NonRecursiveQuestion(fqdn, typeUnknownQuestion)
+	},
+	Validators: []okaydns.RequestResponseValidator{
+		okaycheck.EachNameserver(
+			okaycheck.AuthoritativeResponse,
+			okaycheck.ResponseCode(dns.RcodeSuccess),
+			okaycheck.AnswerIsEmpty,
+		),
+	},
+}
+
+const typeUnknownQuestion uint16 = 666
+
+// Validates that nameservers all return authoritative SOA records for this
+// domain and that their serials match. Most other checks require this one,
+// since every authoritative nameserver for a zone should pass it.
+var CheckSOA = okaydns.Check{
+	ID:   "soa",
+	Name: "SOA serials match",
+	Question: func(fqdn string) *dns.Msg {
+		return okaydns.NonRecursiveQuestion(fqdn, dns.TypeSOA)
+	},
+	Validators: []okaydns.RequestResponseValidator{
+		okaycheck.EachNameserver(
+			okaycheck.AuthoritativeResponse,
+			okaycheck.ResponseCode(dns.RcodeSuccess),
+			okaycheck.AnswerContains(dns.TypeSOA),
+		),
+		validateSerialsMatch,
+	},
+}
+
+var validateSerialsMatch = okaycheck.Consistency{
+	Section: okaycheck.AnswerSection,
+	Filters: []okaycheck.RecordFilter{okaycheck.OfType(dns.TypeSOA)},
+	Key: func(rr dns.RR) string {
+		return strconv.FormatUint(uint64(rr.(*dns.SOA).Serial), 10)
+	},
+	Code: okaycheck.CodeSerialMismatch,
+}.Validator()
+
+// Validates that nameservers answer a question for a name that doesn't exist
+// in the zone with an authoritative NXDOMAIN that includes the zone's SOA.
+//
+// See:
+// - https://tools.ietf.org/html/rfc2308
+var CheckNXDOMAIN = negativeResponseCheck("nxdomain", "NXDOMAIN for nonexistent names", dns.RcodeNameError, func(fqdn string) *dns.Msg {
+	return okaydns.NonRecursiveQuestion(okaydns.RandomLabel()+"."+fqdn, dns.TypeA)
+})
+
+// Validates that nameservers answer a question for a type that doesn't exist at
+// the root of the zone with an authoritative NODATA response that includes the
+// zone's SOA.
+//
+// See:
+// - https://tools.ietf.org/html/rfc2308
+var CheckNODATA = negativeResponseCheck("nodata", "NODATA for missing types", dns.RcodeSuccess, func(fqdn string) *dns.Msg {
+	return okaydns.NonRecursiveQuestion(fqdn, typeNoData)
+})
+
+// TYPE65280 is the first type reserved for private use, so it's never assigned
+// and no zone should publish records of it.
+//
+// See https://tools.ietf.org/html/rfc6895#section-3.1
+const typeNoData uint16 = 65280
+
+// Validates that nameservers answer NXDOMAIN for a name below a name that
+// doesn't exist. The check first asks about a random name to make sure it
+// doesn't exist, since a wildcard would make every name below the zone exist,
+// and then asks about a random name below it.
+//
+// See:
+// - https://tools.ietf.org/html/rfc8020
+var CheckNXDOMAINBelowNXDOMAIN = okaydns.Check{
+	ID:       "nxdomain-below-nxdomain",
+	Name:     "NXDOMAIN below nonexistent names",
+	Requires: []string{CheckSOA.ID},
+	Question: func(fqdn string) *dns.Msg {
+		return okaydns.NonRecursiveQuestion(fqdn, dns.TypeSOA)
+	},
+	Steps: []okaydns.Step{
+		negativeResponseStep("Nonexistent name", dns.RcodeNameError, func(fqdn string) *dns.Msg {
+			return okaydns.NonRecursiveQuestion(okaydns.RandomLabel()+"."+fqdn, dns.TypeA)
+		}),
+		{
+			Name: "Below the nonexistent name",
+			Questions: func(_ string, previous []*okaydns.CheckResult) (questions []*dns.Msg) {
+				for _, name := range askedNames(previous) {
+					questions = append(questions, okaydns.NonRecursiveQuestion(okaydns.RandomLabel()+"."+name, dns.TypeA))
+				}
+				return questions
+			},
+			Validators: negativeResponseValidators(dns.RcodeNameError),
+		},
+	},
+}
+
+// askedNames returns the name asked about in every result.
+func askedNames(results []*okaydns.CheckResult) (names []string) {
+	for _, result := range results {
+		for _, q := range result.Questions {
+			if len(q.Question) > 0 {
+				names = append(names, q.Question[0].Name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// EmptyNonTerminal builds a check that validates nameservers answer
+// questions for an empty non-terminal name, like the _tcp in _sip._tcp, with an
+// authoritative NODATA response instead of an NXDOMAIN. Resolvers that do QNAME
+// minimization treat an NXDOMAIN as proof that nothing exists below a name.
+//
+// The name is relative to the domain being checked.
+//
+// See:
+// - https://tools.ietf.org/html/rfc8020
+// - https://tools.ietf.org/html/rfc7816
+func EmptyNonTerminal(name string) okaydns.Check {
+	return negativeResponseCheck("ent-"+name, "Empty non-terminal "+name, dns.RcodeSuccess, func(fqdn string) *dns.Msg {
+		return okaydns.NonRecursiveQuestion(name+"."+fqdn, dns.TypeA)
+	})
+}
+
+// negativeResponseCheck builds a two step check. It first asks every nameserver
+// for the zone's SOA, and then asks the question built by question and
+// validates that every nameserver gives an authoritative negative response with
+// the given rcode. The TTL of the SOA in each negative response must be the
+// minimum of the SOA's TTL and its MINIMUM field.
+func negativeResponseCheck(id, name string, rcode int, question func(fqdn string) *dns.Msg) okaydns.Check {
+	return okaydns.Check{
+		ID:       id,
+		Name:     name,
+		Requires: []string{CheckSOA.ID},
+		Question: func(fqdn string) *dns.Msg {
+			return okaydns.NonRecursiveQuestion(fqdn, dns.TypeSOA)
+		},
+		Steps: []okaydns.Step{
+			negativeResponseStep("Negative response", rcode, question),
+		},
+	}
+}
+
+// negativeResponseStep builds a step that asks the question built by question
+// and validates every nameserver gives an authoritative negative response with
+// the given rcode. It must follow a question for the zone's SOA.
+func negativeResponseStep(name string, rcode int, question func(fqdn string) *dns.Msg) okaydns.Step {
+	return okaydns.Step{
+		Name: name,
+		Questions: func(fqdn string, _ []*okaydns.CheckResult) []*dns.Msg {
+			return []*dns.Msg{question(fqdn)}
+		},
+		Validators: negativeResponseValidators(rcode),
+		StepValidators: []okaydns.StepValidator{
+			okaycheck.NegativeTTLMatchesSOA,
+		},
+	}
+}
+
+func negativeResponseValidators(rcode int) []okaydns.RequestResponseValidator {
+	return []okaydns.RequestResponseValidator{
+		okaycheck.EachNameserver(
+			okaycheck.AuthoritativeResponse,
+			okaycheck.ResponseCode(rcode),
+			okaycheck.AnswerIsEmpty,
+			okaycheck.NegativeResponseSOA,
+		),
+	}
+}
+
+// Validates that every MX target in the zone has an A or AAAA record on the
+// same nameservers, and isn't an alias. Targets outside of the zone aren't
+// checked.
+var CheckMXTargets = okaydns.Check{
+	ID:       "mx-targets",
+	Name:     "MX targets have addresses",
+	Requires: []string{CheckSOA.ID},
+	Question: func(fqdn string) *dns.Msg {
+		return okaydns.NonRecursiveQuestion(fqdn, dns.TypeMX)
+	},
+	Validators: []okaydns.RequestResponseValidator{
+		okaycheck.EachNameserver(okaycheck.RRsetIntegrity),
+	},
+	Steps: []okaydns.Step{
+		{
+			Name: "MX target",
+			Questions: func(fqdn string, previous []*okaydns.CheckResult) (questions []*dns.Msg) {
+				for _, rr := range okaycheck.Records(previous, dns.TypeMX) {
+					target := rr.(*dns.MX).Mx
+					if target == "." || !dns.IsSubDomain(fqdn, target) {
+						continue
+					}
+					questions = append(questions,
+						okaydns.NonRecursiveQuestion(target, dns.TypeA),
+						okaydns.NonRecursiveQuestion(target, dns.TypeAAAA),
+					)
+				}
+				return questions
+			},
+			Validators: []okaydns.RequestResponseValidator{
+				okaycheck.EachNameserver(
+					okaycheck.AuthoritativeResponse,
+					okaycheck.ResponseCode(dns.RcodeSuccess),
+				),
+			},
+			StepValidators: []okaydns.StepValidator{
+				okaycheck.EveryNameHasAddress,
+				okaycheck.TargetsAreNotAliases,
+			},
+		},
+	},
+}
+
+// Validates that nameservers don't act as open resolvers by sending a recursive
+// question for a random name under .invalid, which is reserved and can't be in
+// any zone a nameserver is authoritative for. Asking about a name next to the
+// domain being checked would be answered authoritatively by nameservers that
+// also host the parent zone.
+//
+// See:
+// - https://tools.ietf.org/html/rfc6761#section-6.4
+var CheckRecursionOutOfZone = openResolverCheck("recursion-out-of-zone", "Refuses recursion (out-of-zone)", func(_ string) string {
+	return okaydns.RandomLabel() + ".invalid."
+})
+
+// Validates that nameservers don't act as open resolvers or serve cached data
+// by asking about www.example.com, a reserved name that resolves everywhere.
+// Use RecursionWellKnown to ask about a different name.
+var CheckRecursionWellKnown = RecursionWellKnown("www.example.com.")
+
+// RecursionWellKnown builds a check that validates nameservers don't act as
+// open resolvers by sending a recursive question for name, and then don't
+// serve cached data by sending a non-recursive question for it. name should be
+// a name that's likely to be in the cache of any resolver the nameservers
+// might share.
+//
+// The check's ID is recursion-well-known-<name>, so that checks for different
+// names can be registered together.
+//
+// See:
+// - https://tools.ietf.org/html/rfc2606#section-3
+func RecursionWellKnown(name string) okaydns.Check {
+	name = dns.Fqdn(strings.ToLower(name))
+
+	check := openResolverCheck("recursion-well-known-"+strings.TrimSuffix(name, "."), "Refuses recursion ("+name+")", func(_ string) string {
+		return name
+	})
+	check.Steps = []okaydns.Step{{
+		Name: "Doesn't serve cached data",
+		Questions: func(_ string, _ []*okaydns.CheckResult) []*dns.Msg {
+			return []*dns.Msg{okaydns.NonRecursiveQuestion(name, dns.TypeA)}
+		},
+		Validators: []okaydns.RequestResponseValidator{
+			okaycheck.EachNameserver(
+				okaycheck.AnswerIsEmpty,
+			),
+		},
+	}}
+	return check
+}
+
+// openResolverCheck builds a check that sends a recursive question for the name
+// returned by qname and validates that nameservers don't recurse or answer from
+// a cache.
+func openResolverCheck(id, name string, qname func(fqdn string) string) okaydns.Check {
+	return okaydns.Check{
+		ID:   id,
+		Name: name,
+		Question: func(fqdn string) *dns.Msg {
+			return new(dns.Msg).SetQuestion(qname(fqdn), dns.TypeA)
+		},
+		Validators: []okaydns.RequestResponseValidator{
+			okaycheck.EachNameserver(
+				okaycheck.RecursionNotAvailable,
+				okaycheck.RefusedOrRootReferral,
+			),
+		},
+	}
+}
+
+// Validates that nameservers give a minimal response to an ANY question over
+// UDP and reports how much larger than the question each response is. The
+// question advertises a large EDNS buffer, the way a reflection attack would.
+//
+// See:
+// - https://tools.ietf.org/html/rfc8482
+var CheckANY = okaydns.Check{
+	ID:       "any-minimal",
+	Name:     "Minimal ANY responses",
+	Requires: []string{CheckSOA.ID},
+	Question: anyQuestion,
+	Validators: []okaydns.RequestResponseValidator{
+		okaycheck.EachNameserver(
+			okaycheck.MinimalANYResponse,
+		),
+	},
+	ExchangeObservers: []okaydns.ExchangeObserver{
+		okaycheck.ResponseSizes,
+	},
+}
+
+// Reports how large responses to an ANY question are over TCP. Large responses
+// are fine over TCP, so this doesn't validate the response.
+var CheckANYOverTCP = okaydns.Check{
+	ID:                   "any-size-tcp",
+	Name:                 "ANY response size (TCP)",
+	Requires:             []string{CheckSOA.ID},
+	Question:             anyQuestion,
+	ConfigureNameservers: overTCP,
+	ExchangeObservers: []okaydns.ExchangeObserver{
+		okaycheck.ResponseSizes,
+	},
+}
+
+func anyQuestion(fqdn string) *dns.Msg {
+	return okaydns.NonRecursiveQuestion(fqdn, dns.TypeANY).SetEdns0(4096, false)
+}
+
+// Validates that nameservers reject a query with the reserved Z bit set with
+// FORMERR, and don't set the bit in their reply. Any other response, including
+// an answer that ignores the bit, fails.
+//
+// See:
+// - https://tools.ietf.org/html/rfc1035#section-4.1.1
+var CheckZBit = okaydns.Check{
+	ID:       "z-bit",
+	Name:     "Handles the reserved Z bit",
+	Requires: []string{CheckSOA.ID},
+	Question: func(fqdn string) *dns.Msg {
+		q := okaydns.NonRecursiveQuestion(fqdn, dns.TypeA)
+		q.Zero = true
+		return q
+	},
+	Validators: []okaydns.RequestResponseValidator{
+		okaycheck.EachNameserver(
+			okaycheck.ResponseCode(dns.RcodeFormatError),
+			okaycheck.ZeroZ,
+		),
+	},
+}
+
+// Validates that nameservers answer a query with an unassigned opcode with
+// NOTIMP.
+//
+// See:
+// - https://tools.ietf.org/html/rfc1035#section-4.1.1
+var CheckUnknownOpcode = okaydns.Check{
+	ID:       "unknown-opcode",
+	Name:     "Handles unknown opcodes",
+	Requires: []string{CheckSOA.ID},
+	Question: func(fqdn string) *dns.Msg {
+		q := okaydns.NonRecursiveQuestion(fqdn, dns.TypeA)
+		q.Opcode = opcodeUnassigned
+		return q
+	},
+	Validators: []okaydns.RequestResponseValidator{
+		okaycheck.EachNameserver(
+			okaycheck.IsResponse,
+			okaycheck.ResponseCode(dns.RcodeNotImplemented),
+		),
+	},
+}
+
+const opcodeUnassigned = 15
+
+// ChaosIdentity builds a check that sends a CHAOS class TXT question for
+// one of the well-known server identity names and reports what every
+// nameserver discloses. Nothing about a response can fail this check; whether a
+// nameserver should disclose its software or identity is a policy decision.
+//
+// See:
+// - https://tools.ietf.org/html/rfc4892
+func ChaosIdentity(name string) okaydns.Check {
+	return okaydns.Check{
+		ID:   "chaos-" + strings.Replace(strings.TrimSuffix(name, "."), ".", "-", -1),
+		Name: "Discloses " + strings.TrimSuffix(name, "."),
+		Question: func(_ string) *dns.Msg {
+			return okaydns.NonRecursiveClassQuestion(name, dns.TypeTXT, dns.ClassCHAOS)
+		},
+		Observers: []okaydns.Observer{
+			okaycheck.AnswerTXT(strings.TrimSuffix(name, ".")),
+		},
+	}
+}
@@ -0,0 +1,158 @@
+package stdchecks_test
+
+import (
+	"testing"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/okaycheck"
+	"github.com/blinsay/okaydns/okaytest"
+	"github.com/blinsay/okaydns/stdchecks"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+const testZone = okaytest.ExampleZone + `
+_sip._tcp     IN SRV   10 10 5060 sip
+sip           IN A     192.0.2.5
+`
+
+const wildcardZone = testZone + `
+*             IN A     192.0.2.1
+`
+
+func TestCheckNXDOMAINBelowNXDOMAIN(t *testing.T) {
+	s := okaytest.Start(t, testZone, "example.org.")
+	result := okaydns.DoCheck(&stdchecks.CheckNXDOMAINBelowNXDOMAIN, s.Origin(), s.Nameservers())
+	assert.True(t, result.Success(), "expected success, got %v", result.Steps)
+	if assert.Len(t, result.Steps, 2) {
+		below := result.Steps[1].Results[0].Questions[s.Nameservers()[0]].Question[0].Name
+		above := result.Steps[0].Results[0].Questions[s.Nameservers()[0]].Question[0].Name
+		assert.True(t, below != above && dns.IsSubDomain(above, below), "%s should be below %s", below, above)
+	}
+
+	// a wildcard makes every name exist, so the first step should fail
+	s = okaytest.Start(t, wildcardZone, "example.org.")
+	result = okaydns.DoCheck(&stdchecks.CheckNXDOMAINBelowNXDOMAIN, s.Origin(), s.Nameservers())
+	assert.False(t, result.Success())
+	if assert.Len(t, result.Steps, 2) {
+		assert.NotEmpty(t, result.Steps[0].Results[0].Failures, "the nonexistent name should fail")
+	}
+}
+
+func TestEmptyNonTerminal(t *testing.T) {
+	s := okaytest.Start(t, testZone, "example.org.")
+
+	tcs := []struct {
+		name    string
+		success bool
+	}{
+		{"_tcp", true},
+		{"_udp", false},
+		{"sip", false},
+	}
+
+	for _, tc := range tcs {
+		check := stdchecks.EmptyNonTerminal(tc.name)
+		result := okaydns.DoCheck(&check, s.Origin(), s.Nameservers())
+		assert.Equal(t, tc.success, result.Success(), "%s: %v", tc.name, result.Steps)
+	}
+}
+
+func TestARRsets(t *testing.T) {
+	// a nameserver whose A RRset has records with different TTLs.
+	s := okaytest.StartHandler(t, dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		reply := new(dns.Msg).SetReply(r)
+		reply.Authoritative = true
+		reply.Answer = okaycheck.MustParseRecords(
+			r.Question[0].Name+" 300 IN A 192.0.2.1",
+			r.Question[0].Name+" 60 IN A 192.0.2.2",
+		)
+		w.WriteMsg(reply)
+	}))
+
+	result := okaydns.DoCheck(&stdchecks.CheckA, "example.org.", s.Nameservers())
+	assert.True(t, result.Success(), "CheckA should not check RRsets: %v", result.Failures)
+
+	result = okaydns.DoCheck(&stdchecks.CheckARRsets, "example.org.", s.Nameservers())
+	assert.False(t, result.Success(), "mismatched TTLs should fail")
+}
+
+func TestOpenResolverChecks(t *testing.T) {
+	s := okaytest.Start(t, testZone, "example.org.")
+
+	for _, check := range []okaydns.Check{stdchecks.CheckRecursionOutOfZone, stdchecks.CheckRecursionWellKnown, stdchecks.RecursionWellKnown("example.net")} {
+		result := okaydns.DoCheck(&check, s.Origin(), s.Nameservers())
+		assert.True(t, result.Success(), "%s: expected success, got %v", check.Name, result.Failures)
+
+		q := result.Questions[s.Nameservers()[0]]
+		assert.False(t, dns.IsSubDomain(s.Origin(), q.Question[0].Name), "%s: %s should be outside the zone", check.Name, q.Question[0].Name)
+		assert.True(t, q.RecursionDesired, check.Name)
+	}
+
+	wellKnown := stdchecks.RecursionWellKnown("www.example.com")
+	assert.Equal(t, "recursion-well-known-www.example.com", wellKnown.ID)
+	assert.NotEqual(t, wellKnown.ID, stdchecks.RecursionWellKnown("example.net.").ID)
+
+	// a nameserver that refuses to recurse, but answers from its cache.
+	cached := okaytest.StartHandler(t, dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.RecursionDesired {
+			w.WriteMsg(new(dns.Msg).SetRcode(r, dns.RcodeRefused))
+			return
+		}
+		reply := new(dns.Msg).SetReply(r)
+		reply.Answer = okaycheck.MustParseRecords(r.Question[0].Name + " 42 IN A 192.0.2.80")
+		w.WriteMsg(reply)
+	}))
+	result := okaydns.DoCheck(&wellKnown, "example.org.", cached.Nameservers())
+	assert.False(t, result.Success(), "a nameserver that serves cached data should fail")
+	if assert.Len(t, result.Steps, 1) && assert.Len(t, result.Steps[0].Results, 1) {
+		step := result.Steps[0].Results[0]
+		assert.False(t, step.Questions[cached.Nameservers()[0]].RecursionDesired)
+		assert.NotEmpty(t, step.Failures)
+	}
+}
+
+func TestCheckZBit(t *testing.T) {
+	serve := func(reply func(r *dns.Msg) *dns.Msg) *okaytest.Server {
+		return okaytest.StartHandler(t, dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			w.WriteMsg(reply(r))
+		}))
+	}
+
+	lame := okaytest.Start(t, testZone, "example.org.")
+	lame.Set(okaytest.Lame)
+
+	tcs := []struct {
+		name    string
+		server  *okaytest.Server
+		success bool
+		codes   []string
+	}{
+		{"rejects the bit", serve(func(r *dns.Msg) *dns.Msg {
+			return new(dns.Msg).SetRcode(r, dns.RcodeFormatError)
+		}), true, nil},
+		{"ignores the bit", okaytest.Start(t, testZone, "example.org."), false, []string{okaycheck.CodeResponseCode}},
+		{"refuses", lame, false, []string{okaycheck.CodeResponseCode}},
+		{"empty answer", serve(func(r *dns.Msg) *dns.Msg {
+			reply := new(dns.Msg).SetReply(r)
+			reply.Authoritative = true
+			return reply
+		}), false, []string{okaycheck.CodeResponseCode}},
+		{"echoes the bit", serve(func(r *dns.Msg) *dns.Msg {
+			reply := new(dns.Msg).SetRcode(r, dns.RcodeFormatError)
+			reply.Zero = true
+			return reply
+		}), true, []string{okaycheck.CodeZBitSet}},
+	}
+
+	for _, tc := range tcs {
+		result := okaydns.DoCheck(&stdchecks.CheckZBit, "example.org.", tc.server.Nameservers())
+		assert.Equal(t, tc.success, result.Success(), "%s: %v", tc.name, result.Failures)
+
+		var codes []string
+		for _, failure := range result.Failures {
+			codes = append(codes, failure.Code)
+		}
+		assert.Equal(t, tc.codes, codes, tc.name)
+	}
+}
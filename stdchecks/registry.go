@@ -0,0 +1,292 @@
+package stdchecks
+
+import (
+	"regexp"
+
+	"github.com/blinsay/okaydns"
+	"github.com/pkg/errors"
+)
+
+// Tags used by the standard checks.
+const (
+	TagBasic    = "basic"
+	TagMail     = "mail"
+	TagEDNS     = "edns"
+	TagSecurity = "security"
+	TagTCP      = "tcp"
+	TagProtocol = "protocol"
+	TagNegative = "negative"
+	TagIdentity = "identity"
+)
+
+// An Entry is a Check in a Registry. Entries are described by a short
+// Description and any number of Tags. Default entries are selected when no tags
+// are selected explicitly.
+type Entry struct {
+	Check       okaydns.Check
+	Description string
+	Tags        []string
+	Default     bool
+}
+
+// HasTag returns true if an Entry has the given tag.
+func (e *Entry) HasTag(tag string) bool {
+	for _, t := range e.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// A Registry is an ordered collection of checks, indexed by ID. The zero value
+// is an empty Registry ready to use.
+type Registry struct {
+	entries []Entry
+	byID    map[string]int
+}
+
+// NewRegistry returns a Registry containing the given entries. It returns an
+// error if any entry can't be registered.
+func NewRegistry(entries ...Entry) (*Registry, error) {
+	r := new(Registry)
+	for _, entry := range entries {
+		if err := r.Register(entry); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register adds an entry to a Registry. Every registered Check must have an ID
+// that isn't already registered.
+func (r *Registry) Register(entry Entry) error {
+	id := entry.Check.ID
+	if id == "" {
+		return errors.Errorf("check %q has no ID", entry.Check.Name)
+	}
+	if _, ok := r.byID[id]; ok {
+		return errors.Errorf("a check with ID %q is already registered", id)
+	}
+
+	if r.byID == nil {
+		r.byID = make(map[string]int)
+	}
+	r.byID[id] = len(r.entries)
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+// Lookup returns the entry for the check with the given ID.
+func (r *Registry) Lookup(id string) (Entry, bool) {
+	i, ok := r.byID[id]
+	if !ok {
+		return Entry{}, false
+	}
+	return r.entries[i], true
+}
+
+// Entries returns every entry in a Registry in the order they were registered.
+func (r *Registry) Entries() []Entry {
+	entries := make([]Entry, len(r.entries))
+	copy(entries, r.entries)
+	return entries
+}
+
+// A Selector picks checks out of a Registry.
+//
+// If any Tags are given, every check with at least one of those tags is
+// selected. Otherwise, default checks are selected, or every check if All is
+// set. If Pattern is not nil, only checks with an ID or Name that matches the
+// Pattern are kept.
+type Selector struct {
+	Tags    []string
+	All     bool
+	Pattern *regexp.Regexp
+}
+
+func (s *Selector) matches(entry *Entry) bool {
+	if len(s.Tags) > 0 {
+		tagged := false
+		for _, tag := range s.Tags {
+			if entry.HasTag(tag) {
+				tagged = true
+				break
+			}
+		}
+		if !tagged {
+			return false
+		}
+	} else if !s.All && !entry.Default {
+		return false
+	}
+
+	if s.Pattern != nil {
+		return s.Pattern.MatchString(entry.Check.ID) || s.Pattern.MatchString(entry.Check.Name)
+	}
+	return true
+}
+
+// Select returns the checks picked by a Selector, in registry order. The
+// prerequisites of every selected check are always included so that the result
+// can be passed directly to okaydns.DoChecks.
+func (r *Registry) Select(s Selector) []okaydns.Check {
+	selected := make(map[string]bool)
+	var selectWithPrerequisites func(id string)
+	selectWithPrerequisites = func(id string) {
+		if selected[id] {
+			return
+		}
+		selected[id] = true
+		if entry, ok := r.Lookup(id); ok {
+			for _, prerequisite := range entry.Check.Requires {
+				selectWithPrerequisites(prerequisite)
+			}
+		}
+	}
+	for i := range r.entries {
+		if s.matches(&r.entries[i]) {
+			selectWithPrerequisites(r.entries[i].Check.ID)
+		}
+	}
+
+	var checks []okaydns.Check
+	for _, entry := range r.entries {
+		if selected[entry.Check.ID] {
+			checks = append(checks, entry.Check)
+		}
+	}
+	return checks
+}
+
+// Standard returns a new Registry containing every standard check. Callers may
+// register their own checks in the returned Registry.
+func Standard() *Registry {
+	r, err := NewRegistry(
+		Entry{
+			Check:       CheckA,
+			Description: "every nameserver gives an authoritative A record, and no CNAME, for the domain",
+			Tags:        []string{TagBasic},
+			Default:     true,
+		},
+		Entry{
+			Check:       CheckAEchoesQuery,
+			Description: "the reply to the A record question echoes the query header and question",
+			Tags:        []string{TagProtocol},
+		},
+		Entry{
+			Check:       CheckARRsets,
+			Description: "the A record answer is made of valid RRsets on the CNAME chain from the domain",
+			Tags:        []string{TagProtocol},
+		},
+		Entry{
+			Check:       CheckAOverTCP,
+			Description: "every nameserver answers the A record question over TCP",
+			Tags:        []string{TagBasic, TagTCP},
+			Default:     true,
+		},
+		Entry{
+			Check:       CheckNoCNAMEAtRoot,
+			Description: "the domain is not a CNAME",
+			Tags:        []string{TagBasic},
+			Default:     true,
+		},
+		Entry{
+			Check:       Check0x20,
+			Description: "every nameserver preserves the case of the question name",
+			Tags:        []string{TagProtocol},
+			Default:     true,
+		},
+		Entry{
+			Check:       CheckUnknownQuestion,
+			Description: "every nameserver answers questions for unknown record types",
+			Tags:        []string{TagProtocol},
+			Default:     true,
+		},
+		Entry{
+			Check:       CheckSOA,
+			Description: "every nameserver serves the SOA record with the same serial",
+			Tags:        []string{TagBasic},
+			Default:     true,
+		},
+		Entry{
+			Check:       CheckNXDOMAIN,
+			Description: "nonexistent names get an authoritative NXDOMAIN with a valid SOA",
+			Tags:        []string{TagBasic, TagNegative},
+			Default:     true,
+		},
+		Entry{
+			Check:       CheckNODATA,
+			Description: "missing record types get an authoritative NODATA with a valid SOA",
+			Tags:        []string{TagBasic, TagNegative},
+			Default:     true,
+		},
+		Entry{
+			Check:       CheckNXDOMAINBelowNXDOMAIN,
+			Description: "names below nonexistent names get an NXDOMAIN",
+			Tags:        []string{TagNegative, TagProtocol},
+			Default:     true,
+		},
+		Entry{
+			Check:       CheckMXTargets,
+			Description: "every MX target has an address",
+			Tags:        []string{TagMail},
+			Default:     true,
+		},
+		Entry{
+			Check:       CheckRecursionOutOfZone,
+			Description: "nameservers refuse recursive questions for a name outside the zone",
+			Tags:        []string{TagSecurity},
+			Default:     true,
+		},
+		Entry{
+			Check:       CheckRecursionWellKnown,
+			Description: "nameservers refuse recursive questions for a well-known name and don't serve it from a cache",
+			Tags:        []string{TagSecurity},
+			Default:     true,
+		},
+		Entry{
+			Check:       CheckANY,
+			Description: "ANY questions get a minimal answer",
+			Tags:        []string{TagSecurity, TagEDNS},
+			Default:     true,
+		},
+		Entry{
+			Check:       CheckANYOverTCP,
+			Description: "reports how large ANY answers are over TCP",
+			Tags:        []string{TagSecurity, TagTCP},
+			Default:     true,
+		},
+		Entry{
+			Check:       CheckZBit,
+			Description: "nameservers reply FORMERR to queries with the reserved Z bit set",
+			Tags:        []string{TagProtocol},
+			Default:     true,
+		},
+		Entry{
+			Check:       CheckUnknownOpcode,
+			Description: "nameservers reply NOTIMP to unknown opcodes",
+			Tags:        []string{TagProtocol},
+			Default:     true,
+		},
+		chaosEntry("version.bind."),
+		chaosEntry("version.server."),
+		chaosEntry("hostname.bind."),
+		chaosEntry("id.server."),
+	)
+	if err != nil {
+		panic("stdchecks: invalid standard registry: " + err.Error())
+	}
+	return r
+}
+
+// chaosEntry builds an entry for a CHAOS identity check. They only report
+// what nameservers disclose and never fail, so they're only run when selected
+// with a tag.
+func chaosEntry(name string) Entry {
+	return Entry{
+		Check:       ChaosIdentity(name),
+		Description: "reports what nameservers disclose for the CHAOS TXT name " + name,
+		Tags:        []string{TagSecurity, TagIdentity},
+	}
+}
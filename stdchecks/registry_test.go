@@ -0,0 +1,139 @@
+package stdchecks_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/stdchecks"
+	"github.com/stretchr/testify/assert"
+)
+
+func entry(id string, isDefault bool, tags []string, requires ...string) stdchecks.Entry {
+	return stdchecks.Entry{
+		Check: okaydns.Check{
+			ID:       id,
+			Name:     "Check " + id,
+			Requires: requires,
+		},
+		Tags:    tags,
+		Default: isDefault,
+	}
+}
+
+func ids(checks []okaydns.Check) (ids []string) {
+	for _, check := range checks {
+		ids = append(ids, check.ID)
+	}
+	return
+}
+
+func TestRegister(t *testing.T) {
+	r, err := stdchecks.NewRegistry(entry("a", true, nil))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Error(t, r.Register(entry("a", false, nil)), "duplicate IDs should be rejected")
+	assert.Error(t, r.Register(stdchecks.Entry{Check: okaydns.Check{Name: "no id"}}), "checks without an ID should be rejected")
+	assert.NoError(t, r.Register(entry("b", false, nil)))
+
+	found, ok := r.Lookup("b")
+	assert.True(t, ok)
+	assert.Equal(t, "Check b", found.Check.Name)
+
+	_, ok = r.Lookup("c")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"a", "b"}, ids(r.Select(stdchecks.Selector{All: true})))
+}
+
+func TestSelect(t *testing.T) {
+	r, err := stdchecks.NewRegistry(
+		entry("soa", true, []string{stdchecks.TagBasic}),
+		entry("a", true, []string{stdchecks.TagBasic}, "soa"),
+		entry("mx", true, []string{stdchecks.TagMail}, "a"),
+		entry("version", false, []string{stdchecks.TagIdentity}, "soa"),
+		entry("any", true, []string{stdchecks.TagSecurity, stdchecks.TagEDNS}),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	testCases := []struct {
+		name     string
+		selector stdchecks.Selector
+		expected []string
+	}{
+		{
+			name:     "defaults",
+			selector: stdchecks.Selector{},
+			expected: []string{"soa", "a", "mx", "any"},
+		},
+		{
+			name:     "all",
+			selector: stdchecks.Selector{All: true},
+			expected: []string{"soa", "a", "mx", "version", "any"},
+		},
+		{
+			name:     "tag",
+			selector: stdchecks.Selector{Tags: []string{stdchecks.TagSecurity}},
+			expected: []string{"any"},
+		},
+		{
+			name:     "tags select non-default checks",
+			selector: stdchecks.Selector{Tags: []string{stdchecks.TagIdentity, stdchecks.TagEDNS}},
+			expected: []string{"soa", "version", "any"},
+		},
+		{
+			name:     "prerequisites are selected transitively",
+			selector: stdchecks.Selector{Tags: []string{stdchecks.TagMail}},
+			expected: []string{"soa", "a", "mx"},
+		},
+		{
+			name:     "pattern matches IDs",
+			selector: stdchecks.Selector{Pattern: regexp.MustCompile("^any$")},
+			expected: []string{"any"},
+		},
+		{
+			name:     "pattern matches names",
+			selector: stdchecks.Selector{Pattern: regexp.MustCompile("Check a")},
+			expected: []string{"soa", "a", "any"},
+		},
+		{
+			name:     "pattern and tags",
+			selector: stdchecks.Selector{Tags: []string{stdchecks.TagBasic}, Pattern: regexp.MustCompile("soa")},
+			expected: []string{"soa"},
+		},
+		{
+			name:     "nothing selected",
+			selector: stdchecks.Selector{Tags: []string{"nope"}},
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, ids(r.Select(tc.selector)), tc.name)
+	}
+}
+
+func TestStandard(t *testing.T) {
+	r := stdchecks.Standard()
+
+	for _, entry := range r.Entries() {
+		assert.NotEmpty(t, entry.Description, entry.Check.ID)
+		assert.NotEmpty(t, entry.Tags, entry.Check.ID)
+		if entry.HasTag(stdchecks.TagIdentity) {
+			assert.False(t, entry.Default, "%s: identity checks should only run when selected", entry.Check.ID)
+		}
+		for _, prerequisite := range entry.Check.Requires {
+			_, ok := r.Lookup(prerequisite)
+			assert.True(t, ok, "%s requires unregistered check %s", entry.Check.ID, prerequisite)
+		}
+	}
+
+	// registries are independent
+	assert.NoError(t, r.Register(entry("custom", true, nil)))
+	_, ok := stdchecks.Standard().Lookup("custom")
+	assert.False(t, ok)
+}
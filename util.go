@@ -2,10 +2,12 @@ package okaydns
 
 import (
 	"math/rand"
+	"strconv"
 	"strings"
 	"unicode"
 
 	"github.com/miekg/dns"
+	"github.com/pkg/errors"
 )
 
 // RandomizeCase copies a string and randomizes the case of all unicode
@@ -31,6 +33,21 @@ func RandomizeCase(s string) string {
 	}
 }
 
+// RandomLabel returns a random, lowercase DNS label that is very unlikely to
+// exist in any zone. Useful for building questions that should result in a
+// negative answer.
+func RandomLabel() string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+	label := make([]byte, randomLabelLength)
+	for i := range label {
+		label[i] = alphabet[rand.Intn(len(alphabet))]
+	}
+	return "okaydns-" + string(label)
+}
+
+const randomLabelLength = 12
+
 // NonRecursiveQuestion is a util function for constructing a non-recursive
 // DNS query.
 //
@@ -46,3 +63,64 @@ func NonRecursiveQuestion(fqdn string, qtype uint16) *dns.Msg {
 	q.RecursionDesired = false
 	return q
 }
+
+// NonRecursiveClassQuestion is a util function for constructing a non-recursive
+// DNS query in a class other than IN, like a CHAOS query for version.bind.
+func NonRecursiveClassQuestion(fqdn string, qtype, qclass uint16) *dns.Msg {
+	q := NonRecursiveQuestion(fqdn, qtype)
+	q.Question[0].Qclass = qclass
+	return q
+}
+
+// RelativeName returns name relative to fqdn, the way names are written in a
+// zone file. An empty name or "@" is fqdn itself, and a name that ends in a dot
+// is already fully qualified.
+func RelativeName(name, fqdn string) string {
+	switch {
+	case name == "" || name == "@":
+		return fqdn
+	case dns.IsFqdn(name):
+		return name
+	default:
+		return name + "." + fqdn
+	}
+}
+
+// ParseType parses a record type from its mnemonic, like "AAAA", or from the
+// generic TYPE### syntax of RFC 3597. Types are case-insensitive.
+func ParseType(s string) (uint16, error) {
+	if t, ok := dns.StringToType[strings.ToUpper(s)]; ok {
+		return t, nil
+	}
+	if t, ok := parseGeneric(s, "TYPE"); ok {
+		return t, nil
+	}
+	return 0, errors.Errorf("unknown type: %q", s)
+}
+
+// ParseClass parses a class from its mnemonic, like "CH", or from the generic
+// CLASS### syntax of RFC 3597. Classes are case-insensitive.
+func ParseClass(s string) (uint16, error) {
+	if c, ok := dns.StringToClass[strings.ToUpper(s)]; ok {
+		return c, nil
+	}
+	if c, ok := parseGeneric(s, "CLASS"); ok {
+		return c, nil
+	}
+	return 0, errors.Errorf("unknown class: %q", s)
+}
+
+// parseGeneric parses the number in the RFC 3597 syntax for an unknown type or
+// class, like TYPE666, where prefix is TYPE or CLASS. Anything other than a
+// 16-bit decimal number after the prefix is invalid.
+func parseGeneric(s, prefix string) (uint16, bool) {
+	s = strings.ToUpper(s)
+	if !strings.HasPrefix(s, prefix) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(strings.TrimPrefix(s, prefix), 10, 16)
+	if err != nil {
+		return 0, false
+	}
+	return uint16(n), true
+}
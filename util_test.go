@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/miekg/dns"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,3 +25,68 @@ func TestRandomizeCase(t *testing.T) {
 		assert.Equal(t, strings.ToLower(tc), strings.ToLower(randomized), "RandomizeCase should not alter the input string")
 	}
 }
+
+func TestRandomLabel(t *testing.T) {
+	seen := make(map[string]struct{})
+
+	for i := 0; i < 100; i++ {
+		label := RandomLabel()
+		assert.Equal(t, strings.ToLower(label), label, "RandomLabel should be lowercase")
+		assert.True(t, len(label) < 64, "RandomLabel should be a legal label")
+		assert.NotContains(t, label, ".", "RandomLabel should be a single label")
+
+		_, dup := seen[label]
+		assert.False(t, dup, "RandomLabel should not repeat itself")
+		seen[label] = struct{}{}
+	}
+}
+
+func TestNonRecursiveClassQuestion(t *testing.T) {
+	q := NonRecursiveClassQuestion("version.bind.", dns.TypeTXT, dns.ClassCHAOS)
+
+	assert.False(t, q.RecursionDesired, "question should not be recursive")
+	if assert.Len(t, q.Question, 1) {
+		assert.Equal(t, dns.Question{Name: "version.bind.", Qtype: dns.TypeTXT, Qclass: dns.ClassCHAOS}, q.Question[0])
+	}
+}
+
+func TestRelativeName(t *testing.T) {
+	assert.Equal(t, "example.com.", RelativeName("", "example.com."))
+	assert.Equal(t, "example.com.", RelativeName("@", "example.com."))
+	assert.Equal(t, "www.example.com.", RelativeName("www", "example.com."))
+	assert.Equal(t, "www.example.net.", RelativeName("www.example.net.", "example.com."))
+}
+
+func TestParseTypeAndClass(t *testing.T) {
+	tcs := []struct {
+		s     string
+		parse func(string) (uint16, error)
+		value uint16
+		ok    bool
+	}{
+		{"AAAA", ParseType, dns.TypeAAAA, true},
+		{"mx", ParseType, dns.TypeMX, true},
+		{"TYPE666", ParseType, 666, true},
+		{"NOPE", ParseType, 0, false},
+		{"TYPE1x", ParseType, 0, false},
+		{"TYPE", ParseType, 0, false},
+		{"TYPE+1", ParseType, 0, false},
+		{"TYPE65536", ParseType, 0, false},
+		{"CH", ParseClass, dns.ClassCHAOS, true},
+		{"in", ParseClass, dns.ClassINET, true},
+		{"CLASS42", ParseClass, 42, true},
+		{"NOPE", ParseClass, 0, false},
+		{"CLASS1foo", ParseClass, 0, false},
+		{"CLASS 1", ParseClass, 0, false},
+	}
+
+	for _, tc := range tcs {
+		value, err := tc.parse(tc.s)
+		if tc.ok {
+			assert.NoError(t, err, tc.s)
+			assert.Equal(t, tc.value, value, tc.s)
+		} else {
+			assert.Error(t, err, tc.s)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package zone
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/okaycheck"
+	"github.com/miekg/dns"
+)
+
+// Codes for the failures returned by drift checks.
+const (
+	CodeMissingRecord = "missing-record"
+	CodeExtraRecord   = "extra-record"
+	CodeTTLDrift      = "ttl-drift"
+)
+
+// DriftChecks builds a Check for every authoritative RRset in a zone that asks
+// every nameserver for that RRset and compares the answer to the zone.
+//
+// Records at or below a delegation point are skipped, since nameservers
+// answer those with a referral instead of an authoritative answer.
+func DriftChecks(z *Zone) []okaydns.Check {
+	var questions []dns.Question
+	for _, rrset := range z.RRsets() {
+		if z.delegated(rrset.Name) {
+			continue
+		}
+		questions = append(questions, dns.Question{Name: rrset.Name, Qtype: rrset.Type, Qclass: rrset.Class})
+	}
+
+	return okaydns.Generate(z.driftCheck, questions)
+}
+
+func (z *Zone) driftCheck(q dns.Question) okaydns.Check {
+	return okaydns.Check{
+		Name: fmt.Sprintf("%s %s", q.Name, dns.TypeToString[q.Qtype]),
+		Question: func(_ string) *dns.Msg {
+			return okaydns.NonRecursiveClassQuestion(q.Name, q.Qtype, q.Qclass)
+		},
+		Validators: []okaydns.RequestResponseValidator{
+			okaycheck.EachNameserver(
+				okaycheck.AuthoritativeResponse,
+				okaycheck.ResponseCode(dns.RcodeSuccess),
+				MatchesZone(z.lookupClass(q.Name, q.Qtype, q.Qclass)),
+			),
+		},
+	}
+}
+
+// MatchesZone builds a MessageValidator that compares the records in the
+// Answer section of a message with the same owner name, type and class as the
+// expected records. Records missing from the answer, extra records in the
+// answer, and records with a different TTL are all failures.
+//
+// The expected records must all be part of the same RRset.
+func MatchesZone(expected []dns.RR) okaydns.MessageValidator {
+	return func(m *dns.Msg) (failures []okaydns.Failure) {
+		if len(expected) == 0 {
+			return nil
+		}
+		hdr := expected[0].Header()
+
+		want := make(map[string]dns.RR, len(expected))
+		for _, rr := range expected {
+			want[okaycheck.Canonical(rr)] = rr
+		}
+
+		got := make(map[string]dns.RR)
+		for _, rr := range m.Answer {
+			answerHdr := rr.Header()
+			if answerHdr.Rrtype == hdr.Rrtype && answerHdr.Class == hdr.Class && strings.EqualFold(answerHdr.Name, hdr.Name) {
+				got[okaycheck.Canonical(rr)] = rr
+			}
+		}
+
+		for _, rr := range expected {
+			answer, ok := got[okaycheck.Canonical(rr)]
+			if !ok {
+				failures = append(failures, okaydns.Failure{Message: fmt.Sprintf("missing record: %s", rr), Code: CodeMissingRecord})
+				continue
+			}
+			if answer.Header().Ttl != rr.Header().Ttl {
+				failures = append(failures, okaydns.Failure{
+					Message:  fmt.Sprintf("TTL is %d but the zone has %d: %s", answer.Header().Ttl, rr.Header().Ttl, rr),
+					Severity: okaydns.SeverityWarning,
+					Code:     CodeTTLDrift,
+				})
+			}
+		}
+
+		// walk the answer instead of got so that extra records are reported in the
+		// order they were answered, and only once.
+		for _, rr := range m.Answer {
+			if key := okaycheck.Canonical(rr); got[key] == rr {
+				if _, ok := want[key]; !ok {
+					failures = append(failures, okaydns.Failure{Message: fmt.Sprintf("extra record: %s", rr), Code: CodeExtraRecord})
+				}
+			}
+		}
+
+		return failures
+	}
+}
+
+// delegated returns true if name is at or below a delegation point in the
+// zone. The apex of the zone is never delegated.
+func (z *Zone) delegated(name string) bool {
+	for _, rr := range z.Records {
+		cut := rr.Header().Name
+		if rr.Header().Rrtype != dns.TypeNS || strings.EqualFold(cut, z.Origin) {
+			continue
+		}
+		if dns.IsSubDomain(cut, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (z *Zone) lookupClass(name string, rrtype, class uint16) (found []dns.RR) {
+	for _, rr := range z.Lookup(name, rrtype) {
+		if rr.Header().Class == class {
+			found = append(found, rr)
+		}
+	}
+	return found
+}
+
+// AbsenceChecks builds a Check for every combination of name and type that
+// isn't in the zone, asserting that no nameserver answers with records of that
+// type. Names at or below a delegation point are skipped, and names that are
+// listed more than once, in any case, are only checked once.
+//
+// AbsenceChecks find records that exist on a nameserver but were removed from
+// a zone, which DriftChecks can't see.
+func AbsenceChecks(z *Zone, names []string, types []uint16) []okaydns.Check {
+	var questions []dns.Question
+	seen := make(map[string]struct{})
+	for _, name := range names {
+		if z.delegated(name) || !dns.IsSubDomain(z.Origin, name) {
+			continue
+		}
+		if _, ok := seen[strings.ToLower(name)]; ok {
+			continue
+		}
+		seen[strings.ToLower(name)] = struct{}{}
+
+		for _, rrtype := range types {
+			if len(z.Lookup(name, rrtype)) == 0 {
+				questions = append(questions, dns.Question{Name: name, Qtype: rrtype, Qclass: dns.ClassINET})
+			}
+		}
+	}
+
+	return okaydns.Generate(absenceCheck, questions)
+}
+
+func absenceCheck(q dns.Question) okaydns.Check {
+	return okaydns.Check{
+		Name: fmt.Sprintf("%s %s", q.Name, dns.TypeToString[q.Qtype]),
+		Question: func(_ string) *dns.Msg {
+			return okaydns.NonRecursiveClassQuestion(q.Name, q.Qtype, q.Qclass)
+		},
+		Validators: []okaydns.RequestResponseValidator{
+			okaycheck.EachNameserver(
+				Absent(q),
+			),
+		},
+	}
+}
+
+// Absent builds a MessageValidator that asserts the Answer section of a
+// message doesn't contain any records with the name, type and class of q.
+func Absent(q dns.Question) okaydns.MessageValidator {
+	return func(m *dns.Msg) (failures []okaydns.Failure) {
+		for _, rr := range m.Answer {
+			hdr := rr.Header()
+			if hdr.Rrtype == q.Qtype && hdr.Class == q.Qclass && strings.EqualFold(hdr.Name, q.Name) {
+				failures = append(failures, okaydns.Failure{Message: fmt.Sprintf("extra record: %s", rr), Code: CodeExtraRecord})
+			}
+		}
+		return failures
+	}
+}
+
+// Names returns every owner name in the zone, in the order they first appear.
+func (z *Zone) Names() []string {
+	var names []string
+	seen := make(map[string]struct{})
+	for _, rr := range z.Records {
+		name := strings.ToLower(rr.Header().Name)
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			names = append(names, rr.Header().Name)
+		}
+	}
+	return names
+}
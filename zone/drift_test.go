@@ -0,0 +1,138 @@
+package zone
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriftChecks(t *testing.T) {
+	z, err := ParseString(testZone+`
+sub     IN NS  ns.sub
+ns.sub  IN A   192.0.2.54
+`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, check := range DriftChecks(z) {
+		names = append(names, check.Name)
+	}
+	assert.Equal(t, []string{
+		"example.com. SOA",
+		"example.com. NS",
+		"example.com. A",
+		"ns1.example.com. A",
+		"WWW.example.com. CNAME",
+	}, names, "delegated names should be skipped")
+}
+
+func TestMatchesZone(t *testing.T) {
+	rr := func(s string) dns.RR {
+		r, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	answer := func(rrs ...dns.RR) *dns.Msg {
+		return &dns.Msg{Answer: rrs}
+	}
+
+	expected := []dns.RR{
+		rr("example.com. 300 IN A 192.0.2.1"),
+		rr("example.com. 300 IN A 192.0.2.2"),
+	}
+	validator := MatchesZone(expected)
+
+	tcs := []struct {
+		name     string
+		msg      *dns.Msg
+		failures int
+	}{
+		{
+			"exact match",
+			answer(rr("example.com. 300 IN A 192.0.2.2"), rr("example.com. 300 IN A 192.0.2.1")),
+			0,
+		},
+		{
+			"case-insensitive names",
+			answer(rr("EXAMPLE.com. 300 IN A 192.0.2.2"), rr("example.COM. 300 IN A 192.0.2.1")),
+			0,
+		},
+		{
+			"ignores other records",
+			answer(rr("example.com. 300 IN A 192.0.2.2"), rr("example.com. 300 IN A 192.0.2.1"), rr("www.example.com. 300 IN A 192.0.2.3")),
+			0,
+		},
+		{
+			"missing record",
+			answer(rr("example.com. 300 IN A 192.0.2.1")),
+			1,
+		},
+		{
+			"extra record",
+			answer(rr("example.com. 300 IN A 192.0.2.2"), rr("example.com. 300 IN A 192.0.2.1"), rr("example.com. 300 IN A 192.0.2.3")),
+			1,
+		},
+		{
+			"different record",
+			answer(rr("example.com. 300 IN A 192.0.2.1"), rr("example.com. 300 IN A 192.0.2.3")),
+			2,
+		},
+		{
+			"different TTLs",
+			answer(rr("example.com. 60 IN A 192.0.2.2"), rr("example.com. 60 IN A 192.0.2.1")),
+			2,
+		},
+		{
+			"empty answer",
+			answer(),
+			2,
+		},
+	}
+
+	for _, tc := range tcs {
+		failures := validator(tc.msg)
+		assert.Len(t, failures, tc.failures, "%s: unexpected failures: %v", tc.name, failures)
+	}
+}
+
+func TestAbsenceChecks(t *testing.T) {
+	z, err := ParseString(testZone, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := append(z.Names(), "old.example.com.", "www.example.net.", "OLD.example.com.", "www.example.com.")
+	var checks []string
+	for _, check := range AbsenceChecks(z, names, []uint16{dns.TypeA, dns.TypeMX}) {
+		checks = append(checks, check.Name)
+	}
+	assert.Equal(t, []string{
+		"example.com. MX",
+		"ns1.example.com. MX",
+		"WWW.example.com. A",
+		"WWW.example.com. MX",
+		"old.example.com. A",
+		"old.example.com. MX",
+	}, checks)
+}
+
+func TestAbsent(t *testing.T) {
+	q := dns.Question{Name: "www.example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+	a, err := dns.NewRR("WWW.example.com. 300 IN A 192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aaaa, err := dns.NewRR("www.example.com. 300 IN AAAA 2001:db8::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, Absent(q)(&dns.Msg{}))
+	assert.Empty(t, Absent(q)(&dns.Msg{Answer: []dns.RR{aaaa}}))
+	assert.Len(t, Absent(q)(&dns.Msg{Answer: []dns.RR{a, aaaa}}), 1)
+}
@@ -0,0 +1,97 @@
+package zone
+
+import (
+	"fmt"
+
+	"github.com/blinsay/okaydns"
+	"github.com/blinsay/okaydns/okaycheck"
+	"github.com/miekg/dns"
+)
+
+// Codes for the failures returned by the Linters in this package. The RRset
+// linters share their rules, and codes, with the RRset validators in
+// okaycheck.
+const (
+	CodeOutOfZone   = "out-of-zone"
+	CodeMissingGlue = "missing-glue"
+)
+
+// A Linter is a static check for a zone.
+type Linter func(*Zone) []okaydns.Failure
+
+// DefaultLinters are the checks run by Lint.
+var DefaultLinters = []Linter{
+	LintOutOfZone,
+	LintCNAMEAndOtherData,
+	LintTargetIsCNAME,
+	LintMissingGlue,
+	LintDuplicates,
+	LintRRsetTTLs,
+}
+
+// Lint runs all of the DefaultLinters against a zone.
+func Lint(z *Zone) (failures []okaydns.Failure) {
+	for _, linter := range DefaultLinters {
+		failures = append(failures, linter(z)...)
+	}
+	return failures
+}
+
+// LintOutOfZone finds records that aren't at or below the origin of the zone.
+func LintOutOfZone(z *Zone) (failures []okaydns.Failure) {
+	for _, rr := range z.Records {
+		if !dns.IsSubDomain(z.Origin, rr.Header().Name) {
+			failures = append(failures, okaydns.Failure{
+				Message:  fmt.Sprintf("%s: out-of-zone record: %s", rr.Header().Name, rr),
+				Severity: okaydns.SeverityWarning,
+				Code:     CodeOutOfZone,
+			})
+		}
+	}
+	return failures
+}
+
+// LintCNAMEAndOtherData finds names that have a CNAME record and any other
+// data, including a second CNAME. See okaycheck.CNAMEFailures.
+func LintCNAMEAndOtherData(z *Zone) []okaydns.Failure {
+	return okaycheck.CNAMEFailures(z.Records)
+}
+
+// LintTargetIsCNAME finds NS and MX records whose targets are names with a
+// CNAME record in the zone. See okaycheck.AliasTargetFailures.
+func LintTargetIsCNAME(z *Zone) []okaydns.Failure {
+	return okaycheck.AliasTargetFailures(z.Records)
+}
+
+// LintMissingGlue finds NS records with in-bailiwick targets that don't have
+// any address records in the zone.
+func LintMissingGlue(z *Zone) (failures []okaydns.Failure) {
+	for _, rr := range z.Records {
+		ns, ok := rr.(*dns.NS)
+		if !ok || !dns.IsSubDomain(z.Origin, ns.Ns) {
+			continue
+		}
+
+		if len(z.Lookup(ns.Ns, dns.TypeA)) == 0 && len(z.Lookup(ns.Ns, dns.TypeAAAA)) == 0 {
+			failures = append(failures, okaydns.Failure{
+				Message:   fmt.Sprintf("%s: NS target %s is in-bailiwick but has no A or AAAA glue", ns.Hdr.Name, ns.Ns),
+				Severity:  okaydns.SeverityCritical,
+				Code:      CodeMissingGlue,
+				Reference: "https://tools.ietf.org/html/rfc1034#section-4.2.1",
+			})
+		}
+	}
+	return failures
+}
+
+// LintDuplicates finds records that appear in the zone more than once. See
+// okaycheck.DuplicateRecordFailures.
+func LintDuplicates(z *Zone) []okaydns.Failure {
+	return okaycheck.DuplicateRecordFailures(z.Records)
+}
+
+// LintRRsetTTLs finds RRsets whose records don't all have the same TTL. See
+// okaycheck.RRsetTTLFailures.
+func LintRRsetTTLs(z *Zone) []okaydns.Failure {
+	return okaycheck.RRsetTTLFailures(z.Records)
+}
@@ -0,0 +1,120 @@
+package zone
+
+import (
+	"testing"
+
+	"github.com/blinsay/okaydns/okaycheck"
+	"github.com/stretchr/testify/assert"
+)
+
+const lintHeader = `
+$ORIGIN example.com.
+$TTL 300
+@       IN SOA ns1 hostmaster 2018010101 3600 600 86400 300
+@       IN NS  ns1
+ns1     IN A   192.0.2.53
+`
+
+type lintTestCase struct {
+	name     string
+	linter   Linter
+	zone     string
+	failures int
+}
+
+func lintTests(t *testing.T, tcs []lintTestCase) {
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			z, err := ParseString(lintHeader+tc.zone, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			failures := tc.linter(z)
+			assert.Len(t, failures, tc.failures, "unexpected failures: %v", failures)
+		})
+	}
+}
+
+func TestLint(t *testing.T) {
+	lintTests(t, []lintTestCase{
+		{"a clean zone has no failures", Lint, "www IN A 192.0.2.1\n", 0},
+	})
+}
+
+func TestLintOutOfZone(t *testing.T) {
+	lintTests(t, []lintTestCase{
+		{"in-zone names pass", LintOutOfZone, "www IN A 192.0.2.1\n", 0},
+		{"out-of-zone names fail", LintOutOfZone, "www.example.net. IN A 192.0.2.1\n", 1},
+		{"names that share a suffix fail", LintOutOfZone, "notexample.com. IN A 192.0.2.1\n", 1},
+	})
+}
+
+func TestLintCNAMEAndOtherData(t *testing.T) {
+	lintTests(t, []lintTestCase{
+		{"a lone CNAME passes", LintCNAMEAndOtherData, "www IN CNAME example.net.\n", 0},
+		{"a CNAME with an A fails", LintCNAMEAndOtherData, "www IN CNAME example.net.\nwww IN A 192.0.2.1\n", 1},
+		{"a CNAME with a TXT in a different case fails", LintCNAMEAndOtherData, "www IN CNAME example.net.\nWWW IN TXT \"hi\"\n", 1},
+		{"two CNAMEs fail", LintCNAMEAndOtherData, "www IN CNAME example.net.\nwww IN CNAME example.org.\n", 1},
+		{"a CNAME at the apex fails", LintCNAMEAndOtherData, "@ IN CNAME example.net.\n", 1},
+	})
+}
+
+func TestLintTargetIsCNAME(t *testing.T) {
+	lintTests(t, []lintTestCase{
+		{"MX to an A passes", LintTargetIsCNAME, "@ IN MX 10 mail\nmail IN A 192.0.2.25\n", 0},
+		{"MX to a CNAME fails", LintTargetIsCNAME, "@ IN MX 10 mail\nmail IN CNAME example.net.\n", 1},
+		{"NS to a CNAME fails", LintTargetIsCNAME, "sub IN NS ns\nns IN CNAME ns1\n", 1},
+	})
+}
+
+func TestLintMissingGlue(t *testing.T) {
+	lintTests(t, []lintTestCase{
+		{"out-of-bailiwick NS passes", LintMissingGlue, "@ IN NS ns.example.net.\n", 0},
+		{"in-bailiwick NS with glue passes", LintMissingGlue, "sub IN NS ns.sub\nns.sub IN AAAA 2001:db8::53\n", 0},
+		{"in-bailiwick NS without glue fails", LintMissingGlue, "sub IN NS ns.sub\n", 1},
+	})
+}
+
+func TestLintDuplicates(t *testing.T) {
+	lintTests(t, []lintTestCase{
+		{"distinct records pass", LintDuplicates, "www IN A 192.0.2.1\nwww IN A 192.0.2.2\n", 0},
+		{"duplicates fail", LintDuplicates, "www IN A 192.0.2.1\nwww IN A 192.0.2.1\n", 1},
+		{"duplicates with different TTLs and case fail once", LintDuplicates, "www 60 IN A 192.0.2.1\nWWW IN A 192.0.2.1\nwww IN A 192.0.2.1\n", 1},
+	})
+}
+
+func TestLintRRsetTTLs(t *testing.T) {
+	lintTests(t, []lintTestCase{
+		{"consistent TTLs pass", LintRRsetTTLs, "www 60 IN A 192.0.2.1\nwww 60 IN A 192.0.2.2\n", 0},
+		{"inconsistent TTLs fail", LintRRsetTTLs, "www 60 IN A 192.0.2.1\nwww 120 IN A 192.0.2.2\n", 1},
+		{"different types can have different TTLs", LintRRsetTTLs, "www 60 IN A 192.0.2.1\nwww 120 IN AAAA 2001:db8::1\n", 0},
+	})
+}
+
+func TestLintCodes(t *testing.T) {
+	z, err := ParseString(lintHeader+`
+www     IN CNAME example.net.
+www     IN TXT   "hi"
+@       IN MX    10 www
+mail 60 IN A     192.0.2.25
+mail    IN A     192.0.2.26
+mail    IN A     192.0.2.26
+`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var codes []string
+	for _, failure := range Lint(z) {
+		codes = append(codes, failure.Code)
+	}
+	assert.Equal(t, []string{
+		okaycheck.CodeCNAMEAndOtherData,
+		okaycheck.CodeTargetIsAlias,
+		okaycheck.CodeDuplicateRecord,
+		okaycheck.CodeRRsetTTLMismatch,
+	}, codes, "the RRset linters should use the okaycheck codes")
+}
@@ -0,0 +1,115 @@
+// Package zone loads BIND-format zone files and checks them for problems
+// without talking to any nameservers.
+package zone
+
+import (
+	"io"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// A Zone is the set of records in a zone file.
+type Zone struct {
+	// Origin is the fully qualified name of the zone.
+	Origin string
+
+	// Records are the records in the zone, in the order they were parsed.
+	Records []dns.RR
+}
+
+// Parse reads a zone file from r. The filename is only used in error messages.
+// If origin is empty, the origin is the owner of the first SOA record in the
+// zone, which means the file must either contain an $ORIGIN directive or only
+// use fully qualified names.
+func Parse(r io.Reader, origin, filename string) (*Zone, error) {
+	if origin != "" {
+		origin = dns.Fqdn(origin)
+	}
+	z := &Zone{Origin: origin}
+
+	tokens := dns.ParseZone(r, origin, filename)
+	for token := range tokens {
+		if token.Error != nil {
+			// drain the channel so the parser exits
+			for range tokens {
+			}
+			return nil, token.Error
+		}
+		z.Records = append(z.Records, token.RR)
+	}
+
+	if z.Origin == "" {
+		soa := z.SOA()
+		if soa == nil {
+			return nil, errors.New("no origin given and no SOA record found")
+		}
+		z.Origin = soa.Hdr.Name
+	}
+
+	return z, nil
+}
+
+// ParseString parses the zone file in s with the given origin.
+func ParseString(s, origin string) (*Zone, error) {
+	return Parse(strings.NewReader(s), origin, "")
+}
+
+// SOA returns the first SOA record in the zone, or nil if the zone doesn't have
+// one.
+func (z *Zone) SOA() *dns.SOA {
+	for _, rr := range z.Records {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa
+		}
+	}
+	return nil
+}
+
+// An RRset is every record in a zone with the same owner name, type and class.
+type RRset struct {
+	Name    string
+	Type    uint16
+	Class   uint16
+	Records []dns.RR
+}
+
+// RRsets groups the records in the zone into RRsets. RRsets are returned in
+// the order their first record appears in the zone, and owner names are
+// compared case-insensitively.
+func (z *Zone) RRsets() []*RRset {
+	type key struct {
+		name   string
+		rrtype uint16
+		class  uint16
+	}
+
+	var rrsets []*RRset
+	index := make(map[key]*RRset)
+	for _, rr := range z.Records {
+		hdr := rr.Header()
+		k := key{strings.ToLower(hdr.Name), hdr.Rrtype, hdr.Class}
+
+		rrset, ok := index[k]
+		if !ok {
+			rrset = &RRset{Name: hdr.Name, Type: hdr.Rrtype, Class: hdr.Class}
+			index[k] = rrset
+			rrsets = append(rrsets, rrset)
+		}
+		rrset.Records = append(rrset.Records, rr)
+	}
+	return rrsets
+}
+
+// Lookup returns all of the records in the zone with the given owner name and
+// type. Names are compared case-insensitively.
+func (z *Zone) Lookup(name string, rrtype uint16) []dns.RR {
+	var found []dns.RR
+	for _, rr := range z.Records {
+		if hdr := rr.Header(); hdr.Rrtype == rrtype && strings.EqualFold(hdr.Name, name) {
+			found = append(found, rr)
+		}
+	}
+	return found
+}
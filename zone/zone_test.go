@@ -0,0 +1,80 @@
+package zone
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+const testZone = `
+$ORIGIN example.com.
+$TTL 300
+@       IN SOA ns1 hostmaster 2018010101 3600 600 86400 300
+@       IN NS  ns1
+@       IN NS  ns2.example.net.
+@       IN A   192.0.2.1
+@       IN A   192.0.2.2
+ns1     IN A   192.0.2.53
+WWW     IN CNAME example.com.
+`
+
+func TestParse(t *testing.T) {
+	z, err := ParseString(testZone, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "example.com.", z.Origin, "origin should come from the SOA")
+	assert.Len(t, z.Records, 7)
+	if assert.NotNil(t, z.SOA()) {
+		assert.Equal(t, uint32(2018010101), z.SOA().Serial)
+	}
+
+	assert.Len(t, z.Lookup("example.com.", dns.TypeA), 2)
+	assert.Len(t, z.Lookup("www.EXAMPLE.com.", dns.TypeCNAME), 1)
+	assert.Empty(t, z.Lookup("www.example.com.", dns.TypeA))
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := ParseString("@ IN A 192.0.2.1\n", "")
+	assert.Error(t, err, "a zone without a SOA or an origin should be an error")
+
+	_, err = ParseString("@ IN A not-an-ip\n", "example.com")
+	assert.Error(t, err, "a zone with a bad record should be an error")
+
+	z, err := ParseString("@ IN A 192.0.2.1\n", "example.com")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "example.com.", z.Origin, "origin should be made fully qualified")
+	}
+}
+
+func TestRRsets(t *testing.T) {
+	z, err := ParseString(testZone, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var summary []string
+	for _, rrset := range z.RRsets() {
+		summary = append(summary, rrset.Name+" "+dns.TypeToString[rrset.Type])
+		if rrset.Type == dns.TypeA && rrset.Name == "example.com." {
+			assert.Len(t, rrset.Records, 2)
+		}
+	}
+	assert.Equal(t, []string{
+		"example.com. SOA",
+		"example.com. NS",
+		"example.com. A",
+		"ns1.example.com. A",
+		"WWW.example.com. CNAME",
+	}, summary)
+}
+
+func TestNames(t *testing.T) {
+	z, err := ParseString(testZone+"www IN A 192.0.2.3\n", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"example.com.", "ns1.example.com.", "WWW.example.com."}, z.Names())
+}